@@ -0,0 +1,217 @@
+// Package address cleans up the addresses on retailer orders before they are forwarded to a supplier: country names
+// become ISO codes, state and province names become their codes, postal codes are formatted the way each country
+// writes them, and whitespace and casing are tidied. Addresses that don't look deliverable are reported as problems.
+package address
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Address is a shipping or billing address, as the API sends it
+type Address struct {
+	Name       string `json:"name"`
+	AddressOne string `json:"addressOne"`
+	AddressTwo string `json:"addressTwo"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	Country    string `json:"country"`
+	Zip        string `json:"zip"`
+	Company    string `json:"company"`
+}
+
+// IsZero is true for an address without any field set (ex. an order without a billing address)
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// postalCode is how a country writes its postal codes: the pattern a code matches once uppercased and stripped of
+// spaces, and how it is formatted back (ex. "$1 $2" to put a space between the groups)
+type postalCode struct {
+	pattern *regexp.Regexp
+	format  string
+}
+
+// postalCodes are the countries whose postal codes are checked. Addresses in these countries need one; in other
+// countries a postal code is optional and only cleaned up.
+var postalCodes = map[string]postalCode{
+	"AT": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"AU": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"BE": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"BR": {regexp.MustCompile(`^(\d{5})-?(\d{3})$`), "$1-$2"},
+	"CA": {regexp.MustCompile(`^([ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z])(\d[ABCEGHJ-NPRSTV-Z]\d)$`), "$1 $2"},
+	"CH": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"DE": {regexp.MustCompile(`^(\d{5})$`), "$1"},
+	"DK": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"ES": {regexp.MustCompile(`^(\d{5})$`), "$1"},
+	"FI": {regexp.MustCompile(`^(\d{5})$`), "$1"},
+	"FR": {regexp.MustCompile(`^(\d{5})$`), "$1"},
+	"GB": {regexp.MustCompile(`^([A-Z]{1,2}\d[A-Z\d]?)(\d[A-Z]{2})$`), "$1 $2"},
+	"IN": {regexp.MustCompile(`^(\d{6})$`), "$1"},
+	"IT": {regexp.MustCompile(`^(\d{5})$`), "$1"},
+	"JP": {regexp.MustCompile(`^(\d{3})-?(\d{4})$`), "$1-$2"},
+	"MX": {regexp.MustCompile(`^(\d{5})$`), "$1"},
+	"NL": {regexp.MustCompile(`^(\d{4})([A-Z]{2})$`), "$1 $2"},
+	"NO": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"NZ": {regexp.MustCompile(`^(\d{4})$`), "$1"},
+	"PL": {regexp.MustCompile(`^(\d{2})-?(\d{3})$`), "$1-$2"},
+	"PT": {regexp.MustCompile(`^(\d{4})-?(\d{3})$`), "$1-$2"},
+	"SE": {regexp.MustCompile(`^(\d{3})(\d{2})$`), "$1 $2"},
+	"US": {regexp.MustCompile(`^(\d{5})(?:-?(\d{4}))?$`), "$1-$2"},
+}
+
+var (
+	countryCodes = map[string]string{}
+	spaces       = regexp.MustCompile(`\s+`)
+)
+
+func init() {
+	for _, c := range countries {
+		countryCodes[key(c.code)] = c.code
+		countryCodes[key(c.code3)] = c.code
+		for _, name := range c.names {
+			countryCodes[key(name)] = c.code
+		}
+	}
+	for alias, code := range countryAliases {
+		countryCodes[key(alias)] = code
+	}
+}
+
+// Normalize returns the address cleaned up, and the problems that make it look undeliverable (ex. "unknown country
+// \"Narnia\""). Fields that can't be normalized are kept as they were, only tidied.
+func Normalize(a Address) (Address, []string) {
+	a = Address{
+		Name:       tidy(a.Name),
+		AddressOne: tidy(a.AddressOne),
+		AddressTwo: tidy(a.AddressTwo),
+		City:       tidy(a.City),
+		State:      clean(a.State),
+		Country:    clean(a.Country),
+		Zip:        strings.ToUpper(clean(a.Zip)),
+		Company:    tidy(a.Company),
+	}
+	problems := []string{}
+	if a.Name == "" && a.Company == "" {
+		problems = append(problems, "missing name")
+	}
+	if a.AddressOne == "" {
+		problems = append(problems, "missing street address")
+	}
+	if a.City == "" {
+		problems = append(problems, "missing city")
+	}
+
+	if a.Country == "" {
+		return a, append(problems, "missing country")
+	}
+	code, ok := Country(a.Country)
+	if !ok {
+		return a, append(problems, fmt.Sprintf("unknown country %q", a.Country))
+	}
+	a.Country = code
+
+	if _, ok := subdivisions[code]; ok {
+		state, ok := Subdivision(code, a.State)
+		switch {
+		case a.State == "":
+			problems = append(problems, "missing state or province")
+		case !ok:
+			problems = append(problems, fmt.Sprintf("unknown state or province %q in %s", a.State, code))
+		default:
+			a.State = state
+		}
+	}
+
+	if format, ok := postalCodes[code]; ok {
+		compact := strings.ReplaceAll(a.Zip, " ", "")
+		switch {
+		case a.Zip == "":
+			problems = append(problems, "missing postal code")
+		case !format.pattern.MatchString(compact):
+			problems = append(problems, fmt.Sprintf("invalid postal code %q for %s", a.Zip, code))
+		default:
+			a.Zip = strings.TrimSuffix(format.pattern.ReplaceAllString(compact, format.format), "-")
+		}
+	}
+	return a, problems
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of a country's alpha-2 or alpha-3 code, English name or common alias
+// (ex. "USA", "United States" and "us" are all "US")
+func Country(name string) (string, bool) {
+	code, ok := countryCodes[key(name)]
+	return code, ok
+}
+
+// Subdivision returns the code of a state, province or territory code or name in the country (ex. "Ontario" is "ON"
+// in CA). Only the countries whose addresses need one are known.
+func Subdivision(country string, name string) (string, bool) {
+	states, ok := subdivisions[country]
+	if !ok {
+		return "", false
+	}
+	k := key(name)
+	for code, stateName := range states {
+		if k == key(code) || k == key(stateName) {
+			return code, true
+		}
+	}
+	if code, ok := subdivisionAliases[country][k]; ok {
+		return code, true
+	}
+	return "", false
+}
+
+// clean trims the value and collapses runs of whitespace into single spaces
+func clean(value string) string {
+	return spaces.ReplaceAllString(strings.TrimSpace(value), " ")
+}
+
+// tidy cleans the value and fixes its casing when it is written all in upper or all in lower case (ex. "123 MAIN ST"
+// is "123 Main St"). Mixed case is left alone, it is most likely intended.
+func tidy(value string) string {
+	value = clean(value)
+	if value != strings.ToUpper(value) && value != strings.ToLower(value) {
+		return value
+	}
+	runes := []rune(strings.ToLower(value))
+	for i, r := range runes {
+		if i == 0 || runes[i-1] == ' ' || runes[i-1] == '-' {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// key is how names are compared: lower case, without accents or punctuation (ex. "Québec" and "quebec", "U.S.A." and
+// "usa")
+func key(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if folded, ok := accents[r]; ok {
+			r = folded
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == ',':
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// accents are the accented letters seen in country and province names
+var accents = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ç': 'c', 'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ñ': 'n', 'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ù': 'u',
+	'ú': 'u', 'û': 'u', 'ü': 'u', 'ý': 'y',
+}
@@ -0,0 +1,77 @@
+package address
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in       Address
+		want     Address
+		problems []string
+	}{
+		{
+			in:   Address{Name: " JANE   DOE ", AddressOne: "123 main st", City: "WATERLOO", State: "ontario", Country: "Canada", Zip: "n2l3g1"},
+			want: Address{Name: "Jane Doe", AddressOne: "123 Main St", City: "Waterloo", State: "ON", Country: "CA", Zip: "N2L 3G1"},
+		},
+		{
+			in:   Address{Name: "Jean-Luc McAllister", AddressOne: "1 Rue de la Paix", City: "Montréal", State: "Québec", Country: "can", Zip: "H2X 1Y4"},
+			want: Address{Name: "Jean-Luc McAllister", AddressOne: "1 Rue de la Paix", City: "Montréal", State: "QC", Country: "CA", Zip: "H2X 1Y4"},
+		},
+		{
+			in:   Address{Company: "Acme", AddressOne: "1 Market St", City: "San Francisco", State: "Calif.", Country: "U.S.A.", Zip: "941051234"},
+			want: Address{Company: "Acme", AddressOne: "1 Market St", City: "San Francisco", State: "CA", Country: "US", Zip: "94105-1234"},
+		},
+		{
+			in:   Address{Name: "A", AddressOne: "10 Downing St", City: "London", Country: "United Kingdom", Zip: "sw1a2aa"},
+			want: Address{Name: "A", AddressOne: "10 Downing St", City: "London", Country: "GB", Zip: "SW1A 2AA"},
+		},
+		{
+			// No postal code format or states known, only tidied
+			in:   Address{Name: "A", AddressOne: "1 Queen's Rd", City: "Hong Kong", Country: "hong kong", Zip: ""},
+			want: Address{Name: "A", AddressOne: "1 Queen's Rd", City: "Hong Kong", Country: "HK"},
+		},
+		{
+			in:       Address{Name: "A", AddressOne: "1 Main St", City: "Toronto", State: "Ontari", Country: "CA", Zip: "12345"},
+			want:     Address{Name: "A", AddressOne: "1 Main St", City: "Toronto", State: "Ontari", Country: "CA", Zip: "12345"},
+			problems: []string{`unknown state or province "Ontari" in CA`, `invalid postal code "12345" for CA`},
+		},
+		{
+			in:       Address{Country: "Narnia"},
+			want:     Address{Country: "Narnia"},
+			problems: []string{"missing name", "missing street address", "missing city", `unknown country "Narnia"`},
+		},
+		{
+			in:       Address{Name: "A", AddressOne: "1 Main St", City: "Austin", Country: "US"},
+			want:     Address{Name: "A", AddressOne: "1 Main St", City: "Austin", Country: "US"},
+			problems: []string{"missing state or province", "missing postal code"},
+		},
+	}
+	for _, test := range tests {
+		got, problems := Normalize(test.in)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%+v: %s", test.in, diff)
+		}
+		if len(problems) == 0 {
+			problems = nil
+		}
+		if !cmp.Equal(problems, test.problems) {
+			t.Errorf("%+v: problems %q, expected %q", test.in, problems, test.problems)
+		}
+	}
+}
+
+func TestCountry(t *testing.T) {
+	for name, want := range map[string]string{
+		"de": "DE", "DEU": "DE", "germany": "DE", "Korea, Republic of": "KR", "South Korea": "KR", "the Netherlands": "NL",
+		"Côte d'Ivoire": "CI", "England": "GB",
+	} {
+		if got, ok := Country(name); !ok || got != want {
+			t.Errorf("%q: got %q, expected %q", name, got, want)
+		}
+	}
+	if _, ok := Country("Atlantis"); ok {
+		t.Errorf("Atlantis should not be a country")
+	}
+}
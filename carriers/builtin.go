@@ -0,0 +1,84 @@
+package carriers
+
+// builtin are the carriers known without a carriers file
+var builtin = []Carrier{
+	{
+		Code:        "ups",
+		Name:        "UPS",
+		Aliases:     []string{"United Parcel", "United Parcel Service", "U.P.S."},
+		Tracking:    []string{`1Z[0-9A-Z]{16}`, `T\d{10}`, `\d{9}`, `\d{26}`},
+		TrackingURL: "https://www.ups.com/track?tracknum={tracking}",
+	},
+	{
+		Code:        "fedex",
+		Name:        "FedEx",
+		Aliases:     []string{"Federal Express", "Fed Ex"},
+		Tracking:    []string{`\d{12}`, `\d{15}`, `\d{20}`, `\d{22}`, `\d{34}`},
+		TrackingURL: "https://www.fedex.com/fedextrack/?trknbr={tracking}",
+	},
+	{
+		Code:        "usps",
+		Name:        "USPS",
+		Aliases:     []string{"US Postal Service", "United States Postal Service", "US Mail", "U.S.P.S."},
+		Tracking:    []string{`9[1-5]\d{20}`, `\d{20}`, `[A-Z]{2}\d{9}US`, `420\d{5}(?:\d{4})?9\d{21}`},
+		TrackingURL: "https://tools.usps.com/go/TrackConfirmAction?tLabels={tracking}",
+	},
+	{
+		Code:        "dhl_express",
+		Name:        "DHL Express",
+		Aliases:     []string{"DHL"},
+		Tracking:    []string{`\d{10,11}`, `JJD\d{18,20}`},
+		TrackingURL: "https://www.dhl.com/en/express/tracking.html?AWB={tracking}",
+	},
+	{
+		Code:        "canada_post",
+		Name:        "Canada Post",
+		Aliases:     []string{"Postes Canada", "CanadaPost", "Canada Post Expedited", "Postes Canada Colis"},
+		Tracking:    []string{`\d{16}`, `[A-Z]{2}\d{9}CA`},
+		TrackingURL: "https://www.canadapost-postescanada.ca/track-reperage/en#/search?searchFor={tracking}",
+	},
+	{
+		Code:        "purolator",
+		Name:        "Purolator",
+		Tracking:    []string{`\d{12}`, `[A-Z]{3}\d{9}`},
+		TrackingURL: "https://www.purolator.com/en/shipping/tracker?pin={tracking}",
+	},
+	{
+		Code:        "canpar",
+		Name:        "Canpar",
+		Aliases:     []string{"Canpar Express"},
+		TrackingURL: "https://www.canpar.com/en/tracking/track.htm?barcode={tracking}",
+	},
+	{
+		Code:        "ontrac",
+		Name:        "OnTrac",
+		Tracking:    []string{`[CD]\d{14}`},
+		TrackingURL: "https://www.ontrac.com/tracking/?number={tracking}",
+	},
+	{
+		Code:        "royal_mail",
+		Name:        "Royal Mail",
+		Tracking:    []string{`[A-Z]{2}\d{9}GB`},
+		TrackingURL: "https://www.royalmail.com/track-your-item#/tracking-results/{tracking}",
+	},
+	{
+		Code:        "australia_post",
+		Name:        "Australia Post",
+		Aliases:     []string{"AusPost"},
+		Tracking:    []string{`[A-Z]{2}\d{9}AU`, `[0-9A-Z]{12,23}`},
+		TrackingURL: "https://auspost.com.au/mypost/track/#/details/{tracking}",
+	},
+	{
+		Code:        "dpd",
+		Name:        "DPD",
+		Tracking:    []string{`\d{14}`},
+		TrackingURL: "https://tracking.dpd.de/status/en_US/parcel/{tracking}",
+	},
+	{
+		Code:        "amazon_logistics",
+		Name:        "Amazon Logistics",
+		Aliases:     []string{"Amazon", "Amazon Shipping"},
+		Tracking:    []string{`TBA\d{12}`},
+		TrackingURL: "https://track.amazon.com/tracking/{tracking}",
+	},
+}
@@ -0,0 +1,273 @@
+// Package carriers normalizes the carrier names suppliers write on their fulfillments ("UPS", "ups ground", "United
+// Parcel") to canonical codes, checks tracking numbers against each carrier's formats and builds tracking URLs.
+package carriers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// TrackingPlaceholder is replaced with the tracking number in tracking URL templates
+const TrackingPlaceholder = "{tracking}"
+
+// Carrier is a shipping carrier and how to recognize it
+type Carrier struct {
+	Code    string   `json:"code"` // Canonical code, ex. ups
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"` // Other ways the carrier is written, the code and name are always recognized
+	// Tracking are the formats of the carrier's tracking numbers as regular expressions. Any tracking number is valid
+	// when there are none.
+	Tracking    []string `json:"tracking"`
+	TrackingURL string   `json:"trackingUrl"` // Template with {tracking}
+
+	patterns []*regexp.Regexp
+}
+
+// ValidTracking is true when the tracking number matches one of the carrier's formats, or it has none
+func (c Carrier) ValidTracking(tracking string) bool {
+	if len(c.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(tracking) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the carrier's tracking URL for the tracking number, or empty without a template
+func (c Carrier) URL(tracking string) string {
+	if c.TrackingURL == "" || tracking == "" {
+		return ""
+	}
+	return strings.Replace(c.TrackingURL, TrackingPlaceholder, url.QueryEscape(tracking), -1)
+}
+
+// Registry finds carriers by the names they are written with
+type Registry struct {
+	carriers map[string]Carrier // By code
+	names    map[string]string  // Folded name -> code
+	prefixes []string           // Folded names, longest first
+}
+
+// File is a carriers file: carriers added to the built in ones, or changes to them by code (ex. more aliases or
+// another tracking URL)
+type File struct {
+	Carriers []Carrier `json:"carriers"`
+}
+
+type cachedRegistry struct {
+	modified time.Time
+	registry Registry
+}
+
+var (
+	loadMu sync.Mutex
+	loaded = map[string]cachedRegistry{}
+)
+
+// Default returns the registry of the built in carriers
+func Default() Registry {
+	registry, err := build(builtin, nil)
+	if err != nil {
+		// The built in carriers are tested
+		panic(err)
+	}
+	return registry
+}
+
+// Load returns the built in carriers with the ones in the carriers file, or only the built in ones without a file. The
+// file is only read again when it changes.
+func Load(path string) (Registry, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Registry{}, fmt.Errorf("failed to read the carriers file: %w", err)
+	}
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	if cached, ok := loaded[path]; ok && cached.modified.Equal(info.ModTime()) {
+		return cached.registry, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Registry{}, fmt.Errorf("failed to read the carriers file: %w", err)
+	}
+	var file File
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return Registry{}, fmt.Errorf("failed to decode the carriers file %s: %w", path, err)
+	}
+	registry, err := build(builtin, file.Carriers)
+	if err != nil {
+		return Registry{}, fmt.Errorf("invalid carriers file %s: %w", path, err)
+	}
+	loaded[path] = cachedRegistry{modified: info.ModTime(), registry: registry}
+	return registry, nil
+}
+
+// WithTrackingURLs returns the registry with other tracking URL templates, by carrier code. New carriers are added in
+// the carriers file.
+func (r Registry) WithTrackingURLs(templates map[string]string) (Registry, error) {
+	if len(templates) == 0 {
+		return r, nil
+	}
+	changes := []Carrier{}
+	for code, template := range templates {
+		if _, ok := r.carriers[code]; !ok {
+			return Registry{}, fmt.Errorf("unknown carrier %q", code)
+		}
+		changes = append(changes, Carrier{Code: code, TrackingURL: template})
+	}
+	list := []Carrier{}
+	for _, carrier := range r.carriers {
+		list = append(list, carrier)
+	}
+	return build(list, changes)
+}
+
+// Find returns the carrier written with the name, ex. "UPS Ground" is ups. Names are matched ignoring case, accents
+// and punctuation, and a known name followed by a service level (ex. "FedEx 2Day") is the known carrier.
+func (r Registry) Find(name string) (Carrier, bool) {
+	key := fold(name)
+	if key == "" {
+		return Carrier{}, false
+	}
+	if code, ok := r.names[key]; ok {
+		return r.carriers[code], true
+	}
+	if code, ok := r.names[strings.Replace(key, " ", "", -1)]; ok {
+		return r.carriers[code], true
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(key, prefix+" ") {
+			return r.carriers[r.names[prefix]], true
+		}
+	}
+	return Carrier{}, false
+}
+
+// Get returns the carrier with the code
+func (r Registry) Get(code string) (Carrier, bool) {
+	carrier, ok := r.carriers[code]
+	return carrier, ok
+}
+
+// Codes returns the code of every carrier, sorted
+func (r Registry) Codes() []string {
+	codes := []string{}
+	for code := range r.carriers {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// build returns a registry of the carriers with the changes applied. A change to a known code replaces the fields it
+// sets and adds its aliases and tracking formats, an unknown code is a new carrier.
+func build(carriers []Carrier, changes []Carrier) (Registry, error) {
+	byCode := map[string]Carrier{}
+	for _, carrier := range carriers {
+		byCode[carrier.Code] = carrier
+	}
+	for _, change := range changes {
+		if change.Code == "" {
+			return Registry{}, fmt.Errorf("a carrier has no code")
+		}
+		carrier, ok := byCode[change.Code]
+		if !ok {
+			carrier = Carrier{Code: change.Code}
+		}
+		if change.Name != "" {
+			carrier.Name = change.Name
+		}
+		if change.TrackingURL != "" {
+			carrier.TrackingURL = change.TrackingURL
+		}
+		carrier.Aliases = append(append([]string{}, carrier.Aliases...), change.Aliases...)
+		carrier.Tracking = append(append([]string{}, carrier.Tracking...), change.Tracking...)
+		byCode[change.Code] = carrier
+	}
+
+	registry := Registry{carriers: map[string]Carrier{}, names: map[string]string{}}
+	for code, carrier := range byCode {
+		if carrier.TrackingURL != "" && !strings.Contains(carrier.TrackingURL, TrackingPlaceholder) {
+			return Registry{}, fmt.Errorf("the tracking URL of %s has no %s", code, TrackingPlaceholder)
+		}
+		carrier.patterns = nil
+		for _, format := range carrier.Tracking {
+			pattern, err := regexp.Compile("^(?:" + format + ")$")
+			if err != nil {
+				return Registry{}, fmt.Errorf("invalid tracking format of %s: %w", code, err)
+			}
+			carrier.patterns = append(carrier.patterns, pattern)
+		}
+		registry.carriers[code] = carrier
+		for _, name := range append([]string{code, carrier.Name}, carrier.Aliases...) {
+			key := fold(name)
+			if key == "" {
+				continue
+			}
+			if other, ok := registry.names[key]; ok && other != code {
+				return Registry{}, fmt.Errorf("%q is a name of both %s and %s", name, other, code)
+			}
+			registry.names[key] = code
+		}
+	}
+	for name := range registry.names {
+		registry.prefixes = append(registry.prefixes, name)
+	}
+	sort.Slice(registry.prefixes, func(i, j int) bool {
+		if len(registry.prefixes[i]) != len(registry.prefixes[j]) {
+			return len(registry.prefixes[i]) > len(registry.prefixes[j])
+		}
+		return registry.prefixes[i] < registry.prefixes[j]
+	})
+	return registry, nil
+}
+
+// fold returns the name in lower case with anything but letters and digits as single spaces, ex. "U.P.S. - Ground" is
+// "u p s ground"
+func fold(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) || unicode.IsPunct(r) {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// NormalizeTracking returns the tracking number without spaces, in upper case
+func NormalizeTracking(tracking string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(tracking) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package carriers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	registry := Default()
+	for name, want := range map[string]string{
+		"UPS":                   "ups",
+		"ups ground":            "ups",
+		"United Parcel":         "ups",
+		"U.P.S. - Next Day Air": "ups",
+		"Fed Ex":                "fedex",
+		"FedEx 2Day":            "fedex",
+		"federal express":       "fedex",
+		"USPS Priority Mail":    "usps",
+		"Postes Canada":         "canada_post",
+		"canada_post":           "canada_post",
+		"DHL":                   "dhl_express",
+	} {
+		carrier, ok := registry.Find(name)
+		if !ok || carrier.Code != want {
+			t.Errorf("Find(%q) = %q, %v, expected %q", name, carrier.Code, ok, want)
+		}
+	}
+	for _, name := range []string{"", "Upstate Couriers", "Local pickup"} {
+		if carrier, ok := registry.Find(name); ok {
+			t.Errorf("Find(%q) = %q, expected no carrier", name, carrier.Code)
+		}
+	}
+}
+
+func TestTracking(t *testing.T) {
+	ups, _ := Default().Get("ups")
+	tracking := NormalizeTracking(" 1z999aa1 0123456784")
+	if !ups.ValidTracking(tracking) {
+		t.Errorf("%s should be a UPS tracking number", tracking)
+	}
+	if ups.ValidTracking("TRACK-1") {
+		t.Errorf("TRACK-1 shouldn't be a UPS tracking number")
+	}
+	if url := ups.URL(tracking); url != "https://www.ups.com/track?tracknum=1Z999AA10123456784" {
+		t.Errorf("unexpected tracking URL: %s", url)
+	}
+	canpar, _ := Default().Get("canpar")
+	if !canpar.ValidTracking("anything") {
+		t.Errorf("any tracking number should be valid for a carrier without formats")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carriers-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "carriers.json")
+	err = ioutil.WriteFile(path, []byte(`{"carriers": [
+		{"code": "ups", "aliases": ["Brown"]},
+		{"code": "speedy", "name": "Speedy Freight", "tracking": ["SF\\d{8}"], "trackingUrl": "https://speedy.example.com/{tracking}"}
+	]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if carrier, _ := registry.Find("brown"); carrier.Code != "ups" {
+		t.Errorf("the file's aliases should be added to the built in carrier")
+	}
+	speedy, ok := registry.Find("Speedy Freight LTL")
+	if !ok || !speedy.ValidTracking("SF12345678") || speedy.URL("SF12345678") != "https://speedy.example.com/SF12345678" {
+		t.Errorf("unexpected carrier from the file: %+v", speedy)
+	}
+
+	registry, err = registry.WithTrackingURLs(map[string]string{"ups": "https://track.example.com/?n={tracking}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ups, _ := registry.Get("ups"); ups.URL("1Z999AA10123456784") != "https://track.example.com/?n=1Z999AA10123456784" {
+		t.Errorf("the template should replace the carrier's own: %s", ups.TrackingURL)
+	}
+	if _, err := registry.WithTrackingURLs(map[string]string{"nope": "https://example.com/{tracking}"}); err == nil {
+		t.Errorf("a template for an unknown carrier should be rejected")
+	}
+
+	err = ioutil.WriteFile(path, []byte(`{"carriers": [{"code": "other", "aliases": ["UPS"]}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time changes
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if _, err := Load(path); err == nil {
+		t.Errorf("a name used by two carriers should be rejected")
+	}
+}
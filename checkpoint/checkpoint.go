@@ -0,0 +1,152 @@
+package checkpoint
+
+import (
+	"crypto/rand"
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/store"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const fileName = "checkpoints.json"
+
+// Checkpoint is how far an unfinished sweep got
+type Checkpoint struct {
+	Job        string    `json:"job"`
+	RunID      string    `json:"runId"`
+	Page       int       `json:"page"` // Next page to process
+	LastEntity string    `json:"lastEntity"`
+	Since      time.Time `json:"since"` // Zero for a full sweep
+	Started    time.Time `json:"started"`
+	Updated    time.Time `json:"updated"`
+}
+
+var (
+	mu          sync.Mutex
+	forceResume bool
+)
+
+// SetForceResume makes every sweep continue from its checkpoint no matter how old it is (the --resume flag)
+func SetForceResume(resume bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	forceResume = resume
+}
+
+// Sweep checkpoints a single paged sweep of a bridge's job
+type Sweep struct {
+	bridge     *env.Bridge
+	checkpoint Checkpoint
+}
+
+// Begin starts a sweep of entities updated since the given time (zero for everything). If the last sweep of the job
+// didn't finish, it continues from its checkpoint (and that sweep's since time, so the pages line up) when --resume was
+// given or the checkpoint is within the resume window. Otherwise it starts again from the first page.
+func Begin(bridge *env.Bridge, job string, since time.Time) *Sweep {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	checkpoints := load(bridge)
+
+	existing, ok := checkpoints[job]
+	if ok && (forceResume || now.Sub(existing.Updated) <= bridge.GetResumeWindow()) {
+		logger.Info(fmt.Sprintf("Resuming %s from page %d (Run %s, last entity %s)", bridge.Label(job), existing.Page, existing.RunID, existing.LastEntity))
+		return &Sweep{bridge: bridge, checkpoint: existing}
+	}
+	if ok {
+		logger.Info(fmt.Sprintf("Checkpoint for %s is older than the resume window, starting from the first page", bridge.Label(job)))
+	}
+
+	sweep := &Sweep{bridge: bridge, checkpoint: Checkpoint{Job: job, RunID: newRunID(), Since: since, Started: now, Updated: now}}
+	checkpoints[job] = sweep.checkpoint
+	save(bridge, checkpoints)
+	return sweep
+}
+
+// StartPage returns the page the sweep starts from
+func (s *Sweep) StartPage() int {
+	return s.checkpoint.Page
+}
+
+// Since returns the Updated time the sweep started from, zero for a full sweep
+func (s *Sweep) Since() time.Time {
+	return s.checkpoint.Since
+}
+
+// Started returns when the sweep first started, before any restarts
+func (s *Sweep) Started() time.Time {
+	return s.checkpoint.Started
+}
+
+// RunID identifies the sweep across restarts
+func (s *Sweep) RunID() string {
+	return s.checkpoint.RunID
+}
+
+// PageDone records that every entity up to and including the page has been processed
+func (s *Sweep) PageDone(page int, lastEntity string) {
+	mu.Lock()
+	defer mu.Unlock()
+	s.checkpoint.Page = page + 1
+	s.checkpoint.LastEntity = lastEntity
+	s.checkpoint.Updated = time.Now()
+	checkpoints := load(s.bridge)
+	checkpoints[s.checkpoint.Job] = s.checkpoint
+	save(s.bridge, checkpoints)
+}
+
+// Complete removes the checkpoint once the sweep has reached the last page
+func (s *Sweep) Complete() {
+	mu.Lock()
+	defer mu.Unlock()
+	checkpoints := load(s.bridge)
+	delete(checkpoints, s.checkpoint.Job)
+	save(s.bridge, checkpoints)
+}
+
+// Finish completes the sweep if it ran to the end (err is nil), otherwise keeps the checkpoint for the next run
+func (s *Sweep) Finish(err error) {
+	if err == nil {
+		s.Complete()
+		return
+	}
+	logger.Info(fmt.Sprintf("%s stopped before the last page, the next run can resume from page %d", s.bridge.Label(s.checkpoint.Job), s.checkpoint.Page))
+}
+
+// All returns every unfinished sweep of the bridge
+func All(bridge *env.Bridge) map[string]Checkpoint {
+	mu.Lock()
+	defer mu.Unlock()
+	return load(bridge)
+}
+
+// load reads the bridge's checkpoints. Caller must hold the lock.
+func load(bridge *env.Bridge) map[string]Checkpoint {
+	checkpoints := map[string]Checkpoint{}
+	err := store.Load(bridge.GetStateDir(), fileName, &checkpoints)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to load the sync checkpoints of %s", bridge.Name), err)
+		return map[string]Checkpoint{}
+	}
+	return checkpoints
+}
+
+// save writes the bridge's checkpoints. Caller must hold the lock.
+func save(bridge *env.Bridge, checkpoints map[string]Checkpoint) {
+	err := store.Save(bridge.GetStateDir(), fileName, checkpoints)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to save the sync checkpoints of %s", bridge.Name), err)
+	}
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
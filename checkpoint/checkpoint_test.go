@@ -0,0 +1,60 @@
+package checkpoint
+
+import (
+	"distribution-bridge/env/envtest"
+	"distribution-bridge/store"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResume(t *testing.T) {
+	bridge := envtest.NewBridge(t, "jobs.resumeWindow=1h")
+	since := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// The run stops after two pages
+	sweep := Begin(bridge, "products", since)
+	if sweep.StartPage() != 0 {
+		t.Fatalf("a new sweep started from page %d", sweep.StartPage())
+	}
+	sweep.PageDone(0, "product_250")
+	sweep.PageDone(1, "product_500")
+	sweep.Finish(errors.New("error: api error :: 500"))
+
+	// The next run continues the same sweep, even with a newer watermark
+	resumed := Begin(bridge, "products", since.Add(time.Hour))
+	if resumed.StartPage() != 2 || resumed.RunID() != sweep.RunID() || !resumed.Since().Equal(since) || !resumed.Started().Equal(sweep.Started()) {
+		t.Errorf("unexpected resumed sweep: %+v", resumed.checkpoint)
+	}
+	if checkpoint := All(bridge)["products"]; checkpoint.LastEntity != "product_500" {
+		t.Errorf("unexpected checkpoint: %+v", checkpoint)
+	}
+
+	// Once it reaches the last page the next run starts over
+	resumed.PageDone(2, "product_600")
+	resumed.Finish(nil)
+	if len(All(bridge)) != 0 {
+		t.Errorf("expected no checkpoints, got %+v", All(bridge))
+	}
+	if next := Begin(bridge, "products", time.Time{}); next.StartPage() != 0 || next.RunID() == sweep.RunID() {
+		t.Errorf("unexpected sweep after a finished one: %+v", next.checkpoint)
+	}
+}
+
+func TestResumeWindow(t *testing.T) {
+	bridge := envtest.NewBridge(t, "jobs.resumeWindow=1h")
+	old := Checkpoint{Job: "orders", RunID: "run_1", Page: 40, Updated: time.Now().Add(-2 * time.Hour)}
+	if err := store.Save(bridge.GetStateDir(), fileName, map[string]Checkpoint{"orders": old}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Older than the resume window, unless --resume was given
+	SetForceResume(true)
+	if sweep := Begin(bridge, "orders", time.Time{}); sweep.StartPage() != 40 || sweep.RunID() != "run_1" {
+		t.Errorf("expected --resume to continue from page 40, got %+v", sweep.checkpoint)
+	}
+	SetForceResume(false)
+	if sweep := Begin(bridge, "orders", time.Time{}); sweep.StartPage() != 0 || sweep.RunID() == "run_1" {
+		t.Errorf("expected an old checkpoint to start over, got %+v", sweep.checkpoint)
+	}
+}
@@ -0,0 +1,58 @@
+// Command fakeapi runs the fake Convictional API on its own, ex. to run the bridge locally without real accounts:
+//
+//	go run ./cmd/fakeapi --seed docs/fakeapi.seed.json
+package main
+
+import (
+	"distribution-bridge/fakeapi"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "Address to listen on")
+	seedPath := flag.String("seed", "", "Path to a JSON seed file with the accounts, products and orders")
+	latency := flag.Duration("latency", 0, "Latency added to every request (Overrides the seed file)")
+	rateLimitRate := flag.Float64("rate-limit-rate", 0, "Share of requests (0-1) answered with a 429 (Overrides the seed file)")
+	errorRate := flag.Float64("error-rate", 0, "Share of requests (0-1) answered with a 500 (Overrides the seed file)")
+	flag.Parse()
+
+	server := fakeapi.New()
+	seed := fakeapi.Seed{}
+	if *seedPath != "" {
+		var err error
+		seed, err = fakeapi.LoadSeed(*seedPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	if *latency > 0 {
+		seed.Faults.Latency = fakeapi.Duration(*latency)
+	}
+	if *rateLimitRate > 0 {
+		seed.Faults.RateLimitRate = *rateLimitRate
+	}
+	if *errorRate > 0 {
+		seed.Faults.ErrorRate = *errorRate
+	}
+	err := server.Seed(seed)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	for _, account := range seed.Accounts {
+		fmt.Printf("Account %s :: %s\n", account.Name, account.APIKey)
+	}
+	fmt.Printf("Fake Convictional API listening on %s (state at /_fake/state)\n", *addr)
+	srv := &http.Server{Addr: *addr, Handler: server, ReadTimeout: time.Second * 10}
+	err = srv.ListenAndServe()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/secrets"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const configUsage = `Usage:
+  config print [--show-secrets]  Show the effective config and where each value came from, secrets are masked unless
+                                 --show-secrets is given
+  config validate                Check the config, the exit code is 2 when it is invalid`
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// configCommand prints or validates the effective config. Returns the exit code.
+func configCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(configUsage)
+		return 2
+	}
+	switch args[0] {
+	case "print":
+		flags := flag.NewFlagSet("config print", flag.ExitOnError)
+		redacted := flags.Bool("redacted", true, "Mask the API keys and secrets")
+		showSecrets := flags.Bool("show-secrets", false, "Print the API keys and secrets in clear")
+		flags.Parse(args[1:])
+		printConfig(*redacted && !*showSecrets)
+		return 0
+	case "validate":
+		// The config is validated before any command runs
+		fmt.Println("The config is valid")
+		return 0
+	}
+	fmt.Println(configUsage)
+	return 2
+}
+
+// printConfig prints the effective config. When redacted, secrets are masked but references to them
+// (ex. file:/run/secrets/key) are printed.
+func printConfig(redacted bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tENV\tVALUE\tSOURCE")
+	for _, value := range env.Effective() {
+		shown := value.Value
+		if redacted && value.Secret && shown != "" && !secrets.IsReference(shown) {
+			shown = "[REDACTED]"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", value.Key, value.Env, shown, value.Source)
+	}
+	w.Flush()
+}
@@ -0,0 +1,417 @@
+package env
+
+import (
+	"distribution-bridge/address"
+	"distribution-bridge/logger"
+	"distribution-bridge/secrets"
+	"distribution-bridge/units"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Order fulfillment modes
+const (
+	ModeDropShip = "dropship" // Retailer orders are forwarded to the suppliers, who ship them
+	ModeStock    = "stock"    // Retailer orders are shipped from the distributor's own stock
+)
+
+// DefaultBridge is the name of the bridge configured with the top level settings when no bridges are named
+const DefaultBridge = "default"
+
+const bridgesPrefix = "bridges."
+
+var bridgeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Bridge is a seller/buyer account pair with its own keys, jobs, rules, state and metrics labels. Bridges are named
+// under "bridges" in the config file (ex. {"bridges": {"brand-a": {"api": {"sellerKey": "..."}}}}) or with
+// --set bridges.<name>.<key>=value, and inherit any setting they don't set from the top level. BRIDGE_<NAME>_<ENV>
+// variables (ex. BRIDGE_BRAND_A_SELLER_API_KEY) set a value for a single bridge.
+type Bridge struct {
+	Name     string
+	implicit bool
+	values   map[string]Value
+}
+
+// Bridges returns every configured bridge, sorted by name
+func Bridges() []*Bridge {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	_, all := current()
+	return all
+}
+
+// GetBridge returns the bridge with the name
+func GetBridge(name string) (*Bridge, error) {
+	for _, bridge := range Bridges() {
+		if bridge.Name == name {
+			return bridge, nil
+		}
+	}
+	return nil, fmt.Errorf("error: no bridge named %q", name)
+}
+
+// Named is false for the default bridge, configured with the top level settings because no bridges are named
+func (b *Bridge) Named() bool {
+	return !b.implicit
+}
+
+// Label returns the name prefixed with the bridge name, or the name alone for the default bridge
+func (b *Bridge) Label(name string) string {
+	if b.implicit {
+		return name
+	}
+	return b.Name + "/" + name
+}
+
+// Valid is true when the bridge has both API keys
+func (b *Bridge) Valid() bool {
+	if b.GetSellerAPIKey() == "" {
+		logger.Info(fmt.Sprintf("No SELLER_API_KEY set for %s", b.Label("bridge")))
+		return false
+	}
+	if b.GetBuyerAPIKey() == "" {
+		logger.Info(fmt.Sprintf("No BUYER_API_KEY set for %s", b.Label("bridge")))
+		return false
+	}
+	return true
+}
+
+// GetSellerAPIKey returns the seller account's API key, read from its provider when it is a secret reference
+func (b *Bridge) GetSellerAPIKey() string {
+	return secrets.Resolve(b.get("api.sellerKey"))
+}
+
+// GetBuyerAPIKey returns the buyer account's API key, read from its provider when it is a secret reference
+func (b *Bridge) GetBuyerAPIKey() string {
+	return secrets.Resolve(b.get("api.buyerKey"))
+}
+
+func (b *Bridge) DropShippingEnabled() bool {
+	return parseBool("jobs.orders.enabled", b.get("jobs.orders.enabled"))
+}
+
+// ProductSyncEnabled is true when products should be synced from the buyer account to the seller account
+func (b *Bridge) ProductSyncEnabled() bool {
+	return parseBool("jobs.products.enabled", b.get("jobs.products.enabled"))
+}
+
+// ForwardNewOrders is true when new retailer orders should be forwarded to the supplier (buyer) account
+func (b *Bridge) ForwardNewOrders() bool {
+	return parseBool("jobs.orders.forwardNew", b.get("jobs.orders.forwardNew"))
+}
+
+// StockModeEnabled is true when retailer orders are fulfilled from the distributor's stock instead of being forwarded to
+// the suppliers
+func (b *Bridge) StockModeEnabled() bool {
+	return b.get("jobs.orders.mode") == ModeStock
+}
+
+// GetReorderPoint returns the available stock of a variant at or below which more is ordered from its supplier, unless
+// the variant has its own
+func (b *Bridge) GetReorderPoint() int {
+	return parseInt("stock.reorderPoint", b.get("stock.reorderPoint"))
+}
+
+// GetReorderQuantity returns how many of a variant are ordered from its supplier when it reaches the reorder point,
+// unless the variant has its own. 0 never orders more.
+func (b *Bridge) GetReorderQuantity() int {
+	return parseInt("stock.reorderQuantity", b.get("stock.reorderQuantity"))
+}
+
+// GetWarehouseAddress returns where the suppliers ship the distributor's purchase orders
+func (b *Bridge) GetWarehouseAddress() address.Address {
+	return address.Address{
+		Name:       b.get("stock.warehouse.name"),
+		Company:    b.get("stock.warehouse.company"),
+		AddressOne: b.get("stock.warehouse.addressOne"),
+		AddressTwo: b.get("stock.warehouse.addressTwo"),
+		City:       b.get("stock.warehouse.city"),
+		State:      b.get("stock.warehouse.state"),
+		Country:    b.get("stock.warehouse.country"),
+		Zip:        b.get("stock.warehouse.zip"),
+	}
+}
+
+// GetWebhookSecret returns the shared secret used to verify inbound webhooks. Webhooks are disabled when it is empty.
+func (b *Bridge) GetWebhookSecret() string {
+	return secrets.Resolve(b.get("webhooks.secret"))
+}
+
+func (b *Bridge) ProductUpdatesToInActive() bool {
+	return parseBool("policies.productUpdatesToInactive", b.get("policies.productUpdatesToInactive"))
+}
+
+func (b *Bridge) NewProductToInActive() bool {
+	return parseBool("policies.newProductToInactive", b.get("policies.newProductToInactive"))
+}
+
+// HoldUndeliverableOrders is true when retailer orders whose shipping address doesn't look deliverable are held for
+// review instead of being forwarded to the supplier
+func (b *Bridge) HoldUndeliverableOrders() bool {
+	return parseBool("policies.holdUndeliverableOrders", b.get("policies.holdUndeliverableOrders"))
+}
+
+// GetHoldOrderValueOver returns the order value (in the order's currency) above which retailer orders are held for
+// review. 0 doesn't hold any.
+func (b *Bridge) GetHoldOrderValueOver() float64 {
+	return parseFloat("holds.orderValueOver", b.get("holds.orderValueOver"))
+}
+
+// GetHoldCountries returns the ISO codes of the countries retailer orders shipping to are held for review
+func (b *Bridge) GetHoldCountries() []string {
+	countries := []string{}
+	for _, country := range parseList(b.get("holds.countries")) {
+		if code, ok := address.Country(country); ok {
+			countries = append(countries, code)
+		}
+	}
+	return countries
+}
+
+// HoldNewRetailers is true when the first order from a retailer the bridge hasn't forwarded an order for is held for
+// review
+func (b *Bridge) HoldNewRetailers() bool {
+	return parseBool("holds.newRetailers", b.get("holds.newRetailers"))
+}
+
+// GetHoldSKUs returns the SKUs or variant codes flagged for manual review, orders with any of them are held
+func (b *Bridge) GetHoldSKUs() []string {
+	return parseList(b.get("holds.skus"))
+}
+
+// HoldCurrencyMismatch is true when retailer orders in another currency than the seller account's are held for review
+func (b *Bridge) HoldCurrencyMismatch() bool {
+	return parseBool("holds.currencyMismatch", b.get("holds.currencyMismatch"))
+}
+
+// RejectInvalidTracking is true when supplier fulfillments whose tracking number doesn't match the carrier's formats
+// are not copied to the retailer's order until the supplier fixes them. They are copied with a warning otherwise.
+func (b *Bridge) RejectInvalidTracking() bool {
+	return parseBool("policies.rejectInvalidTracking", b.get("policies.rejectInvalidTracking"))
+}
+
+// GetCarrierTrackingURLs returns the tracking URL templates that replace the carriers' own, by carrier code
+func (b *Bridge) GetCarrierTrackingURLs() map[string]string {
+	templates, _ := parseTrackingURLs(b.get("carriers.trackingUrls"))
+	return templates
+}
+
+// SLAEnabled is true when the supplier orders on the buyer account are checked against their ship-by deadline
+func (b *Bridge) SLAEnabled() bool {
+	return parseBool("sla.enabled", b.get("sla.enabled"))
+}
+
+// GetSLAShipWithin returns how long after an order is placed suppliers are expected to ship it, unless the supplier
+// has its own. 0 is no deadline.
+func (b *Bridge) GetSLAShipWithin() time.Duration {
+	return parseDuration("sla.shipWithin", b.get("sla.shipWithin"))
+}
+
+// GetSupplierSLAs returns how long each supplier is expected to take to ship an order, by supplier company ID
+func (b *Bridge) GetSupplierSLAs() map[string]time.Duration {
+	slas, _ := parseSupplierSLAs(b.get("sla.suppliers"))
+	return slas
+}
+
+// GetNotifyWebhookURL returns the URL notifications are posted to as JSON, or empty for none
+func (b *Bridge) GetNotifyWebhookURL() string {
+	return secrets.Resolve(b.get("notify.webhook.url"))
+}
+
+// GetNotifySMTPServer returns the host:port of the SMTP server notifications are emailed through, or empty for none
+func (b *Bridge) GetNotifySMTPServer() string {
+	return b.get("notify.email.server")
+}
+
+// GetNotifySMTPUsername returns the user to sign in to the SMTP server with, or empty to not sign in
+func (b *Bridge) GetNotifySMTPUsername() string {
+	return b.get("notify.email.username")
+}
+
+// GetNotifySMTPPassword returns the password of the SMTP user
+func (b *Bridge) GetNotifySMTPPassword() string {
+	return secrets.Resolve(b.get("notify.email.password"))
+}
+
+// GetNotifyEmailFrom returns the address notification emails are sent from
+func (b *Bridge) GetNotifyEmailFrom() string {
+	return b.get("notify.email.from")
+}
+
+// GetNotifyEmailTo returns the addresses notification emails are sent to
+func (b *Bridge) GetNotifyEmailTo() []string {
+	return parseList(b.get("notify.email.to"))
+}
+
+// GetNotifyFile returns the outbox file notifications are appended to as JSON lines, or empty for none
+func (b *Bridge) GetNotifyFile() string {
+	return b.get("notify.file")
+}
+
+// GetNotifyRoutes returns the channels each kind of event is sent to, by event kind (* for the others). Events without
+// a route go to every configured channel.
+func (b *Bridge) GetNotifyRoutes() map[string][]string {
+	routes, _ := parseNotifyRoutes(b.get("notify.routes"))
+	return routes
+}
+
+// GetNotifyDedupWindow returns how long the same event (ex. an auth failure) isn't sent again. 0 sends every event.
+func (b *Bridge) GetNotifyDedupWindow() time.Duration {
+	return parseDuration("notify.dedupWindow", b.get("notify.dedupWindow"))
+}
+
+// GetNotifyDigestInterval returns how often events are sent together as a digest. 0 sends each event right away.
+func (b *Bridge) GetNotifyDigestInterval() time.Duration {
+	return parseDuration("notify.digestInterval", b.get("notify.digestInterval"))
+}
+
+// GetAdminToken returns the bearer token the held order endpoints require. The endpoints are disabled when it is empty.
+func (b *Bridge) GetAdminToken() string {
+	return secrets.Resolve(b.get("server.adminToken"))
+}
+
+// GetProductTags returns the tags a product needs (any of) to be synced. Empty syncs every product.
+func (b *Bridge) GetProductTags() []string {
+	return parseList(b.get("filters.productTags"))
+}
+
+// GetRetailMarkupPercent returns the markup added to variant retail prices when products are synced to the seller
+// account
+func (b *Bridge) GetRetailMarkupPercent() float64 {
+	return parseFloat("pricing.retailMarkupPercent", b.get("pricing.retailMarkupPercent"))
+}
+
+// GetSellerCurrency returns the currency of the seller account's prices. When only one account's currency is set both
+// use it, when neither is the prices have no currency.
+func (b *Bridge) GetSellerCurrency() string {
+	if currency := b.get("pricing.sellerCurrency"); currency != "" {
+		return currency
+	}
+	return b.get("pricing.buyerCurrency")
+}
+
+// GetBuyerCurrency returns the currency of the buyer account's prices, see GetSellerCurrency
+func (b *Bridge) GetBuyerCurrency() string {
+	if currency := b.get("pricing.buyerCurrency"); currency != "" {
+		return currency
+	}
+	return b.get("pricing.sellerCurrency")
+}
+
+// GetSellerWeightUnit returns the unit product weights are converted to for the seller account, or empty to keep the
+// buyer account's
+func (b *Bridge) GetSellerWeightUnit() units.WeightUnit {
+	unit, _ := units.ParseWeightUnit(b.get("units.sellerWeight"))
+	return unit
+}
+
+// GetSellerLengthUnit returns the unit product dimensions are converted to for the seller account, or empty to keep
+// the buyer account's
+func (b *Bridge) GetSellerLengthUnit() units.LengthUnit {
+	unit, _ := units.ParseLengthUnit(b.get("units.sellerLength"))
+	return unit
+}
+
+// GetStateDir returns the directory the bridge's local state (ex. the retry queue) is kept in: the state directory for
+// the default bridge, a directory per bridge inside it otherwise
+func (b *Bridge) GetStateDir() string {
+	if b.implicit {
+		return GetStateDir()
+	}
+	return filepath.Join(GetStateDir(), b.Name)
+}
+
+// GetResumeWindow returns how recent an unfinished sweep's checkpoint must be for the next run to continue from it
+func (b *Bridge) GetResumeWindow() time.Duration {
+	return parseDuration("jobs.resumeWindow", b.get("jobs.resumeWindow"))
+}
+
+// GetWatermarkOverlap returns how far before the watermark incremental syncs start, to catch late writes
+func (b *Bridge) GetWatermarkOverlap() time.Duration {
+	return parseDuration("jobs.watermarkOverlap", b.get("jobs.watermarkOverlap"))
+}
+
+// GetFullSweepInterval returns how often a sync ignores the watermark and goes through everything
+func (b *Bridge) GetFullSweepInterval() time.Duration {
+	return parseDuration("jobs.fullSweepInterval", b.get("jobs.fullSweepInterval"))
+}
+
+// GetRetryMaxAttempts returns how many times a failed entity is attempted before it moves to the dead letter list
+func (b *Bridge) GetRetryMaxAttempts() int {
+	return parseInt("retries.maxAttempts", b.get("retries.maxAttempts"))
+}
+
+// GetRetryBaseDelay returns the wait before the first retry of a failed entity, doubling with each attempt
+func (b *Bridge) GetRetryBaseDelay() time.Duration {
+	return parseDuration("retries.baseDelay", b.get("retries.baseDelay"))
+}
+
+// GetSyncErrorBudget returns how many entities may fail in a single run before the run is aborted
+func (b *Bridge) GetSyncErrorBudget() int {
+	return parseInt("jobs.errorBudget", b.get("jobs.errorBudget"))
+}
+
+// GetSyncConcurrency returns how many products or orders are processed at once
+func (b *Bridge) GetSyncConcurrency() int {
+	return parseInt("jobs.concurrency", b.get("jobs.concurrency"))
+}
+
+// GetSyncInterval returns how often the sync jobs run in serve mode
+func (b *Bridge) GetSyncInterval() time.Duration {
+	return parseDuration("jobs.interval", b.get("jobs.interval"))
+}
+
+// GetReadyMaxSyncAge returns how long ago a job may have last succeeded before the bridge is reported as not ready
+func (b *Bridge) GetReadyMaxSyncAge() time.Duration {
+	if b.get("server.readyMaxSyncAge") == "" {
+		// Default
+		return b.GetSyncInterval() * 3
+	}
+	return parseDuration("server.readyMaxSyncAge", b.get("server.readyMaxSyncAge"))
+}
+
+// get returns the bridge's value for a setting. The values don't change once loaded.
+func (b *Bridge) get(key string) string {
+	return b.values[key].Value
+}
+
+// bridgeNames returns the names used in bridges.<name>.<key> keys, sorted
+func bridgeNames(layers []layer) []string {
+	found := map[string]bool{}
+	for _, l := range layers {
+		for key := range l.values {
+			if strings.HasPrefix(key, bridgesPrefix) {
+				name, _ := splitBridgeKey(key)
+				found[name] = true
+			}
+		}
+	}
+	names := []string{}
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitBridgeKey splits bridges.<name>.<key> into the name and key
+func splitBridgeKey(key string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(key, bridgesPrefix), ".", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// bridgeEnv returns the variable that sets a setting for a single bridge, ex. BRIDGE_BRAND_A_SELLER_API_KEY
+func bridgeEnv(name string, env string) string {
+	return "BRIDGE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_" + env
+}
+
+func validBridgeName(name string) bool {
+	return bridgeNamePattern.MatchString(name)
+}
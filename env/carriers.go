@@ -0,0 +1,44 @@
+package env
+
+import (
+	"distribution-bridge/carriers"
+	"fmt"
+	"strings"
+)
+
+// parseTrackingURLs parses code=template pairs, ex. ups=https://track.example.com/{tracking}
+func parseTrackingURLs(value string) (map[string]string, error) {
+	templates := map[string]string{}
+	for _, pair := range parseList(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%q is not carrier=URL", pair)
+		}
+		if err := validURL(parts[1]); err != nil {
+			return nil, err
+		}
+		if !strings.Contains(parts[1], carriers.TrackingPlaceholder) {
+			return nil, fmt.Errorf("%q has no %s", parts[1], carriers.TrackingPlaceholder)
+		}
+		templates[strings.TrimSpace(parts[0])] = parts[1]
+	}
+	return templates, nil
+}
+
+// checkCarriers checks the carriers file and that every bridge's tracking URLs are for known carriers
+func checkCarriers(shared map[string]Value, all []*Bridge) []string {
+	file := shared["carriers.file"]
+	registry, err := carriers.Load(file.Value)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid %s (%s %s): %s", file.Key, file.Env, file.Source, err)}
+	}
+	problems := []string{}
+	for _, bridge := range all {
+		_, err := registry.WithTrackingURLs(bridge.GetCarrierTrackingURLs())
+		if err != nil {
+			templates := bridge.values["carriers.trackingUrls"]
+			problems = append(problems, fmt.Sprintf("invalid %s (%s %s) for %s: %s", templates.Key, templates.Env, templates.Source, bridge.Label("bridge"), err))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,638 @@
+package env
+
+import (
+	"distribution-bridge/address"
+	"distribution-bridge/logger"
+	"distribution-bridge/money"
+	"distribution-bridge/units"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of setting values
+const (
+	kindString   = "string"
+	kindBool     = "bool"
+	kindInt      = "int"
+	kindFloat    = "float"
+	kindDuration = "duration"
+	kindList     = "list"
+)
+
+// Sources of an effective setting value, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// setting is a single configurable value. It can be set by its key in the config file (nested objects are joined
+// with dots, ex. {"api": {"url": "..."}} is api.url), its environment variable, or a --set key=value flag. Bridge
+// settings can also be set for a single bridge, see Bridge.
+type setting struct {
+	key      string
+	env      string
+	kind     string
+	def      string
+	secret   bool
+	bridge   bool
+	validate func(value string) error
+}
+
+var settings = []setting{
+	{key: "api.url", env: "CONVICTIONAL_API_URL", kind: kindString, def: "https://api.convictional.com", validate: validURL},
+	{key: "api.sellerKey", env: "SELLER_API_KEY", kind: kindString, secret: true, bridge: true},
+	{key: "api.buyerKey", env: "BUYER_API_KEY", kind: kindString, secret: true, bridge: true},
+	{key: "api.rateLimit", env: "API_RATE_LIMIT", kind: kindFloat, def: "4", validate: notNegative},
+	{key: "api.recordFile", env: "API_RECORD_FILE", kind: kindString},
+	{key: "jobs.products.enabled", env: "PRODUCT_SYNC_ENABLED", kind: kindBool, def: "false", bridge: true},
+	{key: "jobs.orders.enabled", env: "DROP_SHIPPING_ENABLED", kind: kindBool, def: "true", bridge: true},
+	{key: "jobs.orders.forwardNew", env: "FORWARD_NEW_ORDERS", kind: kindBool, def: "false", bridge: true},
+	{key: "jobs.orders.mode", env: "ORDER_FULFILLMENT_MODE", kind: kindString, def: ModeDropShip, bridge: true, validate: validFulfillmentMode},
+	{key: "jobs.interval", env: "SYNC_INTERVAL", kind: kindDuration, def: "15m", bridge: true, validate: positive},
+	{key: "jobs.concurrency", env: "SYNC_CONCURRENCY", kind: kindInt, def: "4", bridge: true, validate: positive},
+	{key: "jobs.errorBudget", env: "SYNC_ERROR_BUDGET", kind: kindInt, def: "50", bridge: true, validate: notNegative},
+	{key: "jobs.resumeWindow", env: "RESUME_WINDOW", kind: kindDuration, def: "1h", bridge: true, validate: notNegative},
+	{key: "jobs.watermarkOverlap", env: "WATERMARK_OVERLAP", kind: kindDuration, def: "10m", bridge: true, validate: notNegative},
+	{key: "jobs.fullSweepInterval", env: "FULL_SWEEP_INTERVAL", kind: kindDuration, def: "24h", bridge: true, validate: positive},
+	{key: "retries.maxAttempts", env: "RETRY_MAX_ATTEMPTS", kind: kindInt, def: "5", bridge: true, validate: positive},
+	{key: "retries.baseDelay", env: "RETRY_BASE_DELAY", kind: kindDuration, def: "5m", bridge: true, validate: positive},
+	{key: "filters.productTags", env: "PRODUCT_TAGS", kind: kindList, bridge: true},
+	{key: "pricing.retailMarkupPercent", env: "RETAIL_PRICE_MARKUP_PERCENT", kind: kindFloat, def: "0", bridge: true, validate: notNegative},
+	{key: "pricing.sellerCurrency", env: "SELLER_CURRENCY", kind: kindString, bridge: true, validate: validCurrency},
+	{key: "pricing.buyerCurrency", env: "BUYER_CURRENCY", kind: kindString, bridge: true, validate: validCurrency},
+	{key: "pricing.ratesFile", env: "CURRENCY_RATES_FILE", kind: kindString},
+	{key: "carriers.file", env: "CARRIERS_FILE", kind: kindString},
+	{key: "carriers.trackingUrls", env: "CARRIER_TRACKING_URLS", kind: kindList, bridge: true, validate: validTrackingURLs},
+	{key: "units.sellerWeight", env: "SELLER_WEIGHT_UNIT", kind: kindString, bridge: true, validate: validWeightUnit},
+	{key: "units.sellerLength", env: "SELLER_LENGTH_UNIT", kind: kindString, bridge: true, validate: validLengthUnit},
+	{key: "policies.productUpdatesToInactive", env: "PRODUCT_UPDATES_TO_INACTIVE", kind: kindBool, def: "false", bridge: true},
+	{key: "policies.newProductToInactive", env: "NEW_PRODUCT_TO_INACTIVE", kind: kindBool, def: "true", bridge: true},
+	{key: "policies.holdUndeliverableOrders", env: "HOLD_UNDELIVERABLE_ORDERS", kind: kindBool, def: "true", bridge: true},
+	{key: "policies.rejectInvalidTracking", env: "REJECT_INVALID_TRACKING", kind: kindBool, def: "false", bridge: true},
+	{key: "holds.orderValueOver", env: "HOLD_ORDER_VALUE_OVER", kind: kindFloat, def: "0", bridge: true, validate: notNegative},
+	{key: "holds.countries", env: "HOLD_COUNTRIES", kind: kindList, bridge: true, validate: validCountries},
+	{key: "holds.newRetailers", env: "HOLD_NEW_RETAILERS", kind: kindBool, def: "false", bridge: true},
+	{key: "holds.skus", env: "HOLD_SKUS", kind: kindList, bridge: true},
+	{key: "holds.currencyMismatch", env: "HOLD_CURRENCY_MISMATCH", kind: kindBool, def: "false", bridge: true},
+	{key: "sla.enabled", env: "SLA_ENABLED", kind: kindBool, def: "false", bridge: true},
+	{key: "sla.shipWithin", env: "SLA_SHIP_WITHIN", kind: kindDuration, def: "0", bridge: true, validate: notNegative},
+	{key: "sla.suppliers", env: "SLA_SUPPLIERS", kind: kindList, bridge: true, validate: validSupplierSLAs},
+	{key: "stock.reorderPoint", env: "STOCK_REORDER_POINT", kind: kindInt, def: "0", bridge: true, validate: notNegative},
+	{key: "stock.reorderQuantity", env: "STOCK_REORDER_QUANTITY", kind: kindInt, def: "0", bridge: true, validate: notNegative},
+	{key: "stock.warehouse.name", env: "WAREHOUSE_NAME", kind: kindString, bridge: true},
+	{key: "stock.warehouse.company", env: "WAREHOUSE_COMPANY", kind: kindString, bridge: true},
+	{key: "stock.warehouse.addressOne", env: "WAREHOUSE_ADDRESS_ONE", kind: kindString, bridge: true},
+	{key: "stock.warehouse.addressTwo", env: "WAREHOUSE_ADDRESS_TWO", kind: kindString, bridge: true},
+	{key: "stock.warehouse.city", env: "WAREHOUSE_CITY", kind: kindString, bridge: true},
+	{key: "stock.warehouse.state", env: "WAREHOUSE_STATE", kind: kindString, bridge: true},
+	{key: "stock.warehouse.country", env: "WAREHOUSE_COUNTRY", kind: kindString, bridge: true, validate: validCountries},
+	{key: "stock.warehouse.zip", env: "WAREHOUSE_ZIP", kind: kindString, bridge: true},
+	{key: "notify.webhook.url", env: "NOTIFY_WEBHOOK_URL", kind: kindString, secret: true, bridge: true},
+	{key: "notify.email.server", env: "NOTIFY_SMTP_SERVER", kind: kindString, bridge: true, validate: validHostPort},
+	{key: "notify.email.username", env: "NOTIFY_SMTP_USERNAME", kind: kindString, bridge: true},
+	{key: "notify.email.password", env: "NOTIFY_SMTP_PASSWORD", kind: kindString, secret: true, bridge: true},
+	{key: "notify.email.from", env: "NOTIFY_EMAIL_FROM", kind: kindString, bridge: true},
+	{key: "notify.email.to", env: "NOTIFY_EMAIL_TO", kind: kindList, bridge: true},
+	{key: "notify.file", env: "NOTIFY_FILE", kind: kindString, bridge: true},
+	{key: "notify.routes", env: "NOTIFY_ROUTES", kind: kindList, bridge: true, validate: validNotifyRoutes},
+	{key: "notify.dedupWindow", env: "NOTIFY_DEDUP_WINDOW", kind: kindDuration, def: "1h", bridge: true, validate: notNegative},
+	{key: "notify.digestInterval", env: "NOTIFY_DIGEST_INTERVAL", kind: kindDuration, def: "0", bridge: true, validate: notNegative},
+	{key: "server.port", env: "PORT", kind: kindInt, def: "8080", validate: validPort},
+	{key: "server.readyMaxSyncAge", env: "READY_MAX_SYNC_AGE", kind: kindDuration, bridge: true, validate: positive},
+	{key: "server.adminToken", env: "ADMIN_TOKEN", kind: kindString, secret: true, bridge: true},
+	{key: "webhooks.secret", env: "WEBHOOK_SECRET", kind: kindString, secret: true, bridge: true},
+	{key: "state.dir", env: "STATE_DIR", kind: kindString, def: "state"},
+	{key: "secrets.keyfile", env: "SECRETS_KEYFILE", kind: kindString},
+	{key: "secrets.passphrase", env: "SECRETS_PASSPHRASE", kind: kindString, secret: true, validate: notKeyfile},
+	{key: "secrets.refreshInterval", env: "SECRETS_REFRESH_INTERVAL", kind: kindDuration, def: "30s", validate: positive},
+	{key: "logging.redactionAllowlist", env: "LOG_REDACTION_ALLOWLIST", kind: kindList, validate: validCategories},
+}
+
+// Value is the effective value of a setting and where it came from
+type Value struct {
+	Key    string
+	Env    string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// layer is the values from one source, keyed by setting key (or bridges.<name>.<key>)
+type layer struct {
+	source string
+	values map[string]string
+}
+
+var (
+	configMu sync.RWMutex
+	values   map[string]Value
+	bridges  []*Bridge
+)
+
+// Load builds the effective config from the defaults, the config file (path, or CONFIG_FILE when empty), the
+// environment and the key=value overrides from flags, each overriding the one before. Unknown keys and invalid
+// values are all reported in the returned error.
+func Load(path string, overrides []string) error {
+	problems := []string{}
+	layers := []layer{}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		fileValues, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer{source: SourceFile, values: fileValues})
+	}
+
+	flagValues := map[string]string{}
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("invalid flag --set %q (expected key=value)", override))
+			continue
+		}
+		flagValues[strings.TrimSpace(parts[0])] = parts[1]
+	}
+
+	// Bridges are named in the file or flags, the environment can only set values for them
+	names := bridgeNames(append(layers, layer{values: flagValues}))
+	layers = append(layers, layer{source: SourceEnv, values: envValues(names)})
+	layers = append(layers, layer{source: SourceFlag, values: flagValues})
+
+	loaded, loadedBridges, buildProblems := build(layers, names)
+	problems = append(problems, buildProblems...)
+	if len(buildProblems) == 0 {
+		problems = append(problems, checkCurrencies(loaded, loadedBridges)...)
+		problems = append(problems, checkCarriers(loaded, loadedBridges)...)
+		problems = append(problems, checkNotifications(loadedBridges)...)
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	values = loaded
+	bridges = loadedBridges
+	useSecrets(loaded)
+	return nil
+}
+
+// build applies the layers over the defaults. Returns the shared values, the bridges and any problems.
+func build(layers []layer, names []string) (map[string]Value, []*Bridge, []string) {
+	problems := []string{}
+	shared := map[string]Value{}
+	for _, s := range settings {
+		shared[s.key] = Value{Key: s.key, Env: s.env, Value: s.def, Source: SourceDefault, Secret: s.secret}
+	}
+	named := map[string]*Bridge{}
+	for _, name := range names {
+		named[name] = &Bridge{Name: name, values: map[string]Value{}}
+	}
+
+	for _, l := range layers {
+		for _, key := range sortedKeys(l.values) {
+			value := l.values[key]
+			if !strings.HasPrefix(key, bridgesPrefix) {
+				if _, ok := findSetting(key); !ok {
+					problems = append(problems, fmt.Sprintf("unknown key %q (%s)", key, l.source))
+					continue
+				}
+				if value != "" {
+					// Empty is unset, the same as an empty environment variable
+					shared[key] = withValue(shared[key], value, l.source)
+				}
+				continue
+			}
+
+			name, settingKey := splitBridgeKey(key)
+			s, ok := findSetting(settingKey)
+			if !ok || !s.bridge {
+				problems = append(problems, fmt.Sprintf("unknown bridge key %q (%s)", key, l.source))
+				continue
+			}
+			bridge := named[name]
+			if value != "" {
+				bridge.values[settingKey] = Value{Key: key, Env: bridgeEnv(name, s.env), Value: value, Source: l.source, Secret: s.secret}
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if err := validateValue(s, shared[s.key].Value); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s (%s %s): %s", s.key, s.env, shared[s.key].Source, err))
+		}
+	}
+
+	if len(names) == 0 {
+		// A single bridge configured with the top level settings
+		bridge := &Bridge{Name: DefaultBridge, implicit: true, values: map[string]Value{}}
+		for _, s := range settings {
+			if s.bridge {
+				bridge.values[s.key] = shared[s.key]
+			}
+		}
+		return shared, []*Bridge{bridge}, problems
+	}
+
+	all := []*Bridge{}
+	for _, name := range names {
+		if !validBridgeName(name) {
+			problems = append(problems, fmt.Sprintf("invalid bridge name %q (lowercase letters, digits, - and _)", name))
+		}
+		bridge := named[name]
+		for _, s := range settings {
+			if !s.bridge {
+				continue
+			}
+			value, ok := bridge.values[s.key]
+			if !ok {
+				// Inherit the top level value
+				value = shared[s.key]
+				value.Key = bridgesPrefix + name + "." + s.key
+				value.Env = bridgeEnv(name, s.env)
+				bridge.values[s.key] = value
+				continue
+			}
+			if err := validateValue(s, value.Value); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid %s (%s %s): %s", value.Key, value.Env, value.Source, err))
+			}
+		}
+		all = append(all, bridge)
+	}
+	return shared, all, problems
+}
+
+// Effective returns every setting's effective value, the shared settings first then each bridge's, sorted by key
+func Effective() []Value {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	shared, all := current()
+	list := []Value{}
+	for _, s := range settings {
+		if !s.bridge || all[0].implicit {
+			list = append(list, shared[s.key])
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	for _, bridge := range all {
+		if bridge.implicit {
+			continue
+		}
+		bridgeValues := []Value{}
+		for _, value := range bridge.values {
+			bridgeValues = append(bridgeValues, value)
+		}
+		sort.Slice(bridgeValues, func(i, j int) bool { return bridgeValues[i].Key < bridgeValues[j].Key })
+		list = append(list, bridgeValues...)
+	}
+	return list
+}
+
+// current returns the loaded values and bridges. Falls back to the defaults and environment when Load has not been
+// called, ex. from a package that is used on its own. Caller must hold the read lock.
+func current() (map[string]Value, []*Bridge) {
+	if values != nil {
+		return values, bridges
+	}
+	shared, all, _ := build([]layer{{source: SourceEnv, values: envValues(nil)}}, nil)
+	return shared, all
+}
+
+// envValues returns the settings set in the environment, including the BRIDGE_<NAME>_ variables of each bridge
+func envValues(names []string) map[string]string {
+	found := map[string]string{}
+	for _, s := range settings {
+		if value := envValue(s, s.env); value != "" {
+			found[s.key] = value
+		}
+		if !s.bridge {
+			continue
+		}
+		for _, name := range names {
+			if value := envValue(s, bridgeEnv(name, s.env)); value != "" {
+				found[bridgesPrefix+name+"."+s.key] = value
+			}
+		}
+	}
+	return found
+}
+
+// envValue returns the variable's value. Secrets can instead be read from the file named by <ENV>_FILE (ex.
+// SELLER_API_KEY_FILE=/run/secrets/seller_api_key), which is the same as a file: reference.
+func envValue(s setting, env string) string {
+	if value := os.Getenv(env); value != "" || !s.secret {
+		return value
+	}
+	if path := os.Getenv(env + "_FILE"); path != "" {
+		return "file:" + path
+	}
+	return ""
+}
+
+func findSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func withValue(value Value, str string, source string) Value {
+	value.Value = str
+	value.Source = source
+	return value
+}
+
+// readConfigFile reads a JSON config file into flat key/value strings
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config file: %w", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var raw map[string]interface{}
+	err = decoder.Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the config file %s: %w", path, err)
+	}
+	flat := map[string]string{}
+	err = flatten("", raw, flat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return flat, nil
+}
+
+// flatten joins nested objects into dotted keys and converts the values to the strings the environment would hold
+func flatten(prefix string, raw map[string]interface{}, flat map[string]string) error {
+	for name, value := range raw {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			err := flatten(key, v, flat)
+			if err != nil {
+				return err
+			}
+		case []interface{}:
+			items := []string{}
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%s must be a list of strings", key)
+				}
+				items = append(items, str)
+			}
+			flat[key] = strings.Join(items, ",")
+		case string:
+			flat[key] = v
+		case json.Number:
+			flat[key] = v.String()
+		case bool:
+			flat[key] = strconv.FormatBool(v)
+		case nil:
+			flat[key] = ""
+		}
+	}
+	return nil
+}
+
+func validateValue(s setting, value string) error {
+	if value == "" {
+		return nil
+	}
+	var err error
+	switch s.kind {
+	case kindBool:
+		_, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case kindInt:
+		_, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+	case kindFloat:
+		_, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case kindDuration:
+		_, err = time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration (ex. 15m, 1h)", value)
+		}
+	}
+	if s.validate != nil {
+		return s.validate(value)
+	}
+	return nil
+}
+
+func validURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
+
+func notNegative(value string) error {
+	if strings.HasPrefix(strings.TrimSpace(value), "-") {
+		return fmt.Errorf("%q must not be negative", value)
+	}
+	return nil
+}
+
+func positive(value string) error {
+	if err := notNegative(value); err != nil {
+		return err
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration == 0 {
+		return fmt.Errorf("%q must be more than zero", value)
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil && number == 0 {
+		return fmt.Errorf("%q must be more than zero", value)
+	}
+	return nil
+}
+
+// notKeyfile stops the keyfile passphrase from being kept in the keyfile it unlocks
+func notKeyfile(value string) error {
+	if strings.HasPrefix(value, "keyfile:") {
+		return errors.New("the keyfile passphrase can't be kept in the keyfile")
+	}
+	return nil
+}
+
+func validCurrency(value string) error {
+	if !money.ValidCurrency(value) {
+		return fmt.Errorf("%q is not a currency code (ex. USD)", value)
+	}
+	return nil
+}
+
+func validWeightUnit(value string) error {
+	_, err := units.ParseWeightUnit(value)
+	return err
+}
+
+func validLengthUnit(value string) error {
+	_, err := units.ParseLengthUnit(value)
+	return err
+}
+
+func validFulfillmentMode(value string) error {
+	if value != ModeDropShip && value != ModeStock {
+		return fmt.Errorf("%q is not a fulfillment mode (%s or %s)", value, ModeDropShip, ModeStock)
+	}
+	return nil
+}
+
+func validTrackingURLs(value string) error {
+	_, err := parseTrackingURLs(value)
+	return err
+}
+
+func validSupplierSLAs(value string) error {
+	_, err := parseSupplierSLAs(value)
+	return err
+}
+
+// parseSupplierSLAs parses supplier=duration pairs, ex. 5f1e0c9a=48h
+func parseSupplierSLAs(value string) (map[string]time.Duration, error) {
+	slas := map[string]time.Duration{}
+	for _, pair := range parseList(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%q is not supplier=duration", pair)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("%q is not a positive duration (ex. 48h)", parts[1])
+		}
+		slas[strings.TrimSpace(parts[0])] = duration
+	}
+	return slas, nil
+}
+
+func validPort(value string) error {
+	port, _ := strconv.Atoi(value)
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%q is not a port (1-65535)", value)
+	}
+	return nil
+}
+
+func validCountries(value string) error {
+	for _, country := range parseList(value) {
+		if _, ok := address.Country(country); !ok {
+			return fmt.Errorf("unknown country %q", country)
+		}
+	}
+	return nil
+}
+
+func validCategories(value string) error {
+	known := map[string]bool{
+		logger.CategoryAPIKey:        true,
+		logger.CategoryAuthorization: true,
+		logger.CategoryEmail:         true,
+		logger.CategoryPhone:         true,
+		logger.CategoryAddress:       true,
+	}
+	for _, category := range strings.Split(value, ",") {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category != "" && !known[category] {
+			return fmt.Errorf("unknown redaction category %q", category)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getString returns a shared setting's effective value
+func getString(key string) string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	shared, _ := current()
+	return shared[key].Value
+}
+
+// settingDefault returns a setting's default value
+func settingDefault(key string) string {
+	s, _ := findSetting(key)
+	return s.def
+}
+
+// The parse functions fall back to the default, values are validated when the config is loaded
+
+func parseBool(key string, str string) bool {
+	value, err := strconv.ParseBool(str)
+	if err != nil {
+		value, _ = strconv.ParseBool(settingDefault(key))
+	}
+	return value
+}
+
+func parseInt(key string, str string) int {
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		value, _ = strconv.Atoi(settingDefault(key))
+	}
+	return value
+}
+
+func parseFloat(key string, str string) float64 {
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		value, _ = strconv.ParseFloat(settingDefault(key), 64)
+	}
+	return value
+}
+
+func parseDuration(key string, str string) time.Duration {
+	value, err := time.ParseDuration(str)
+	if err != nil {
+		value, _ = time.ParseDuration(settingDefault(key))
+	}
+	return value
+}
+
+func parseList(str string) []string {
+	list := []string{}
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
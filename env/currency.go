@@ -0,0 +1,30 @@
+package env
+
+import (
+	"distribution-bridge/money"
+	"fmt"
+)
+
+// checkCurrencies checks that prices can be converted for every bridge whose accounts use different currencies
+func checkCurrencies(shared map[string]Value, all []*Bridge) []string {
+	problems := []string{}
+	ratesFile := shared["pricing.ratesFile"]
+	for _, bridge := range all {
+		seller, buyer := bridge.GetSellerCurrency(), bridge.GetBuyerCurrency()
+		if seller == buyer {
+			continue
+		}
+		if ratesFile.Value == "" {
+			problems = append(problems, fmt.Sprintf("%s sells in %s but buys in %s, set %s (%s) to convert prices", bridge.Label("bridge"), seller, buyer, ratesFile.Key, ratesFile.Env))
+			continue
+		}
+		rates, err := money.LoadRates(ratesFile.Value)
+		if err == nil {
+			_, err = rates.Rate(buyer, seller)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s (%s %s) for %s: %s", ratesFile.Key, ratesFile.Env, ratesFile.Source, bridge.Label("bridge"), err))
+		}
+	}
+	return problems
+}
@@ -1,59 +1,41 @@
 package env
 
-import (
-	"distribution-bridge/logger"
-	"os"
-	"strings"
-)
-
-func ValidEnvVariables() bool {
-	if GetSellerAPIKey() == "" {
-		logger.Info("No SELLER_API_KEY set")
-		return false
-	}
-	if GetBuyerAPIKey() == "" {
-		logger.Info("No BUYER_API_KEY set")
-		return false
-	}
-	return true
+// GetStateDir returns the directory local state (ex. the retry queue) is kept in
+func GetStateDir() string {
+	return getString("state.dir")
 }
 
-func GetSellerAPIKey() string {
-	return os.Getenv("SELLER_API_KEY")
+// GetAPIRateLimit returns the max requests per second for each API key, shared by every job and worker. 0 is unlimited.
+func GetAPIRateLimit() float64 {
+	return parseFloat("api.rateLimit", getString("api.rateLimit"))
 }
 
-func GetBuyerAPIKey() string {
-	return os.Getenv("BUYER_API_KEY")
+// GetPort returns the port the HTTP server listens on in serve mode
+func GetPort() string {
+	return getString("server.port")
 }
 
-func DropShippingEnabled() bool {
-	return getEnvBool("DROP_SHIPPING_ENABLED", true)
+// GetAPIRecordFile returns the fixture file every API request and response is recorded to, or empty to not record
+func GetAPIRecordFile() string {
+	return getString("api.recordFile")
 }
 
-func ProductUpdatesToInActive() bool {
-	return getEnvBool("PRODUCT_UPDATES_TO_INACTIVE", false)
+// GetCurrencyRatesFile returns the exchange rate table prices are converted with when a bridge's accounts use different
+// currencies
+func GetCurrencyRatesFile() string {
+	return getString("pricing.ratesFile")
 }
 
-func NewProductToInActive() bool {
-	return getEnvBool("NEW_PRODUCT_TO_INACTIVE", true)
+// GetCarriersFile returns the file with the carriers added to the built in ones, or empty for only the built in ones
+func GetCarriersFile() string {
+	return getString("carriers.file")
 }
 
-func getEnvBool(key string, def bool) bool {
-	str := os.Getenv(key)
-	if str == "" {
-		// Default
-		return def
-	}
-	if strings.ToLower(str) == "false" {
-		return false
-	}
-	return true
+func GetBaseURL() string {
+	return getString("api.url")
 }
 
-func GetBaseURL() string {
-	baseURL := os.Getenv("CONVICTIONAL_API_URL")
-	if baseURL != "" {
-		return baseURL
-	}
-	return "https://api.convictional.com"
-}
\ No newline at end of file
+// GetLogRedactionAllowlist returns the redaction categories (ex. email,phone) that may be logged in clear
+func GetLogRedactionAllowlist() []string {
+	return parseList(getString("logging.redactionAllowlist"))
+}
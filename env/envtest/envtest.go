@@ -0,0 +1,31 @@
+// Package envtest loads bridges for tests, each with its own state directory.
+package envtest
+
+import (
+	"distribution-bridge/env"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TempDir returns a new directory that is removed when the test finishes
+func TempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bridge-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// NewBridge loads the config of a single bridge with its own state directory and the settings, ex.
+// "server.adminToken=token"
+func NewBridge(t *testing.T, settings ...string) *env.Bridge {
+	t.Helper()
+	err := env.Load("", append([]string{"state.dir=" + TempDir(t)}, settings...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env.Bridges()[0]
+}
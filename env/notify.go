@@ -0,0 +1,92 @@
+package env
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Notification channels and events, the notify package sends them
+var (
+	notifyChannels = []string{"log", "webhook", "email", "file", "none"}
+	notifyEvents   = []string{"product_create_failed", "order_held", "sla_breach", "dead_letter", "auth_failure", "*"}
+)
+
+// parseNotifyRoutes parses event=channel+channel pairs, ex. sla_breach=email+webhook
+func parseNotifyRoutes(value string) (map[string][]string, error) {
+	routes := map[string][]string{}
+	for _, pair := range parseList(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not event=channel+channel", pair)
+		}
+		event := strings.TrimSpace(parts[0])
+		if !contains(notifyEvents, event) {
+			return nil, fmt.Errorf("unknown event %q (%s)", event, strings.Join(notifyEvents, ", "))
+		}
+		channels := []string{}
+		for _, channel := range strings.Split(parts[1], "+") {
+			channel = strings.TrimSpace(channel)
+			if !contains(notifyChannels, channel) {
+				return nil, fmt.Errorf("unknown channel %q (%s)", channel, strings.Join(notifyChannels, ", "))
+			}
+			channels = append(channels, channel)
+		}
+		routes[event] = channels
+	}
+	return routes, nil
+}
+
+func validNotifyRoutes(value string) error {
+	_, err := parseNotifyRoutes(value)
+	return err
+}
+
+func validHostPort(value string) error {
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		return fmt.Errorf("%q is not host:port", value)
+	}
+	return nil
+}
+
+// checkNotifications checks that every bridge's email notifications have a sender and recipients, and that events
+// are only routed to configured channels
+func checkNotifications(all []*Bridge) []string {
+	problems := []string{}
+	for _, bridge := range all {
+		configured := map[string]bool{
+			"log":     true,
+			"none":    true,
+			"webhook": bridge.get("notify.webhook.url") != "",
+			"email":   bridge.GetNotifySMTPServer() != "",
+			"file":    bridge.GetNotifyFile() != "",
+		}
+		if configured["email"] && (bridge.GetNotifyEmailFrom() == "" || len(bridge.GetNotifyEmailTo()) == 0) {
+			problems = append(problems, fmt.Sprintf("%s emails notifications, set notify.email.from (NOTIFY_EMAIL_FROM) and notify.email.to (NOTIFY_EMAIL_TO)", bridge.Label("bridge")))
+		}
+		routes := bridge.GetNotifyRoutes()
+		events := []string{}
+		for event := range routes {
+			events = append(events, event)
+		}
+		sort.Strings(events)
+		for _, event := range events {
+			for _, channel := range routes[event] {
+				if !configured[channel] {
+					problems = append(problems, fmt.Sprintf("%s routes %s to %s, which isn't configured", bridge.Label("bridge"), event, channel))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package env
+
+import (
+	"distribution-bridge/logger"
+	"distribution-bridge/secrets"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// useSecrets points the secrets package at the configured keyfile and refresh interval
+func useSecrets(shared map[string]Value) {
+	secrets.SetRefreshInterval(parseDuration("secrets.refreshInterval", shared["secrets.refreshInterval"].Value))
+	passphrase := shared["secrets.passphrase"].Value
+	if !secrets.IsReference(passphrase) {
+		logger.AddSecrets(passphrase)
+	}
+	secrets.UseKeyfile(shared["secrets.keyfile"].Value, func() (string, error) {
+		if secrets.IsReference(passphrase) {
+			return secrets.Lookup(passphrase)
+		}
+		return passphrase, nil
+	})
+}
+
+// CheckSecrets checks that every secret reference (ex. file:/run/secrets/seller_api_key) can be read, reporting every
+// one that can't
+func CheckSecrets() error {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	shared, all := current()
+	problems := []string{}
+	checked := map[string]bool{}
+	check := func(value Value) {
+		if !value.Secret || !secrets.IsReference(value.Value) || checked[value.Value] {
+			return
+		}
+		checked[value.Value] = true
+		if _, err := secrets.Lookup(value.Value); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s (%s %s): %s", value.Key, value.Env, value.Source, err))
+		}
+	}
+	for _, s := range settings {
+		if !s.bridge || all[0].implicit {
+			check(shared[s.key])
+		}
+	}
+	for _, bridge := range all {
+		for _, s := range settings {
+			if s.bridge {
+				check(bridge.values[s.key])
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Package fakeapi is an in-memory stand in for the Convictional API, for tests and local development. It implements
+// the endpoints the bridge uses for any number of accounts, each keyed by its API key.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Document is a product, order or buyer order as JSON. Fields the fake doesn't know about are kept as they are.
+type Document map[string]interface{}
+
+// Account is a Convictional account with its own products and orders
+type Account struct {
+	Name        string     `json:"name"`
+	APIKey      string     `json:"apiKey"`
+	Products    []Document `json:"products"`
+	Orders      []Document `json:"orders"`
+	BuyerOrders []Document `json:"buyerOrders"`
+}
+
+// Seed is the initial state of a server, ex. loaded from a seed file
+type Seed struct {
+	Accounts []Account `json:"accounts"`
+	Faults   Faults    `json:"faults"`
+}
+
+// Request is a request the server received
+type Request struct {
+	Method  string
+	Path    string
+	Query   string
+	Account string
+	Status  int
+}
+
+// Server is a fake Convictional API. It is an http.Handler, ex. httptest.NewServer(fakeapi.New()).
+type Server struct {
+	mu       sync.Mutex
+	accounts map[string]*Account
+	faults   Faults
+	failures []failure
+	random   *rand.Rand
+	requests []Request
+	lastID   int
+	lastTime time.Time
+}
+
+// New returns a server without any accounts
+func New() *Server {
+	return &Server{
+		accounts: map[string]*Account{},
+		random:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// LoadSeed reads a seed file
+func LoadSeed(path string) (Seed, error) {
+	var seed Seed
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return seed, err
+	}
+	err = json.Unmarshal(data, &seed)
+	if err != nil {
+		return seed, fmt.Errorf("failed to decode the seed file: %w", err)
+	}
+	return seed, nil
+}
+
+// Seed adds the seed's accounts and uses its faults
+func (s *Server) Seed(seed Seed) error {
+	for _, account := range seed.Accounts {
+		if account.APIKey == "" {
+			return fmt.Errorf("error: account %q has no apiKey", account.Name)
+		}
+		s.AddAccount(account.Name, account.APIKey)
+		for _, product := range account.Products {
+			s.AddProduct(account.APIKey, product)
+		}
+		for _, order := range account.Orders {
+			s.AddOrder(account.APIKey, order)
+		}
+	}
+	s.SetFaults(seed.Faults)
+	return nil
+}
+
+// AddAccount adds an empty account that authenticates with the API key
+func (s *Server) AddAccount(name string, apiKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[apiKey] = &Account{Name: name, APIKey: apiKey, Products: []Document{}, Orders: []Document{}, BuyerOrders: []Document{}}
+}
+
+// AddProduct adds a product to the account, filling in the ID and timestamps it doesn't have. Returns the product as
+// stored.
+func (s *Server) AddProduct(apiKey string, product Document) Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.mustAccount(apiKey)
+	product = s.stamp(clone(product), false)
+	for _, variant := range documents(product["variants"]) {
+		if str(variant["_id"]) == "" {
+			variant["_id"] = s.newID()
+		}
+	}
+	account.Products = append(account.Products, product)
+	return clone(product)
+}
+
+// AddOrder adds an order to the account, filling in the IDs and timestamps it doesn't have. Returns the order as stored.
+func (s *Server) AddOrder(apiKey string, order Document) Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.mustAccount(apiKey)
+	order = s.stamp(clone(order), false)
+	for _, item := range documents(order["items"]) {
+		if str(item["_id"]) == "" {
+			item["_id"] = s.newID()
+		}
+	}
+	if _, ok := order["shipped"]; !ok {
+		order["shipped"] = false
+	}
+	account.Orders = append(account.Orders, order)
+	return clone(order)
+}
+
+// UpdateOrder applies the fields to an order (ex. {"shipped": true}) and bumps its updated time
+func (s *Server) UpdateOrder(apiKey string, orderID string, fields Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order := find(s.mustAccount(apiKey).Orders, orderID)
+	if order == nil {
+		return fmt.Errorf("error: no order %q", orderID)
+	}
+	for key, value := range clone(fields) {
+		order[key] = value
+	}
+	s.stamp(order, true)
+	return nil
+}
+
+// UpdateProduct applies the fields to a product (ex. {"title": "New"}) and bumps its updated time
+func (s *Server) UpdateProduct(apiKey string, productID string, fields Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	product := find(s.mustAccount(apiKey).Products, productID)
+	if product == nil {
+		return fmt.Errorf("error: no product %q", productID)
+	}
+	for key, value := range clone(fields) {
+		product[key] = value
+	}
+	s.stamp(product, true)
+	return nil
+}
+
+// Products returns a copy of the account's products
+func (s *Server) Products(apiKey string) []Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneAll(s.mustAccount(apiKey).Products)
+}
+
+// Orders returns a copy of the account's orders
+func (s *Server) Orders(apiKey string) []Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneAll(s.mustAccount(apiKey).Orders)
+}
+
+// BuyerOrders returns a copy of the buyer orders posted to the account
+func (s *Server) BuyerOrders(apiKey string) []Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneAll(s.mustAccount(apiKey).BuyerOrders)
+}
+
+// Requests returns every request received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// mustAccount returns the account for the API key. Panics when there is none, it is only used by the test helpers.
+// Caller must hold the lock.
+func (s *Server) mustAccount(apiKey string) *Account {
+	account, ok := s.accounts[apiKey]
+	if !ok {
+		panic(fmt.Sprintf("fakeapi: no account with the API key %q", apiKey))
+	}
+	return account
+}
+
+// newID returns a new ID shaped like the API's, ex. 000000000000000000000001. Caller must hold the lock.
+func (s *Server) newID() string {
+	s.lastID++
+	return fmt.Sprintf("%024x", s.lastID)
+}
+
+// now returns the current time, always after the last time it returned so updated times are distinct. Caller must hold
+// the lock.
+func (s *Server) now() time.Time {
+	now := time.Now().UTC()
+	if !now.After(s.lastTime) {
+		now = s.lastTime.Add(time.Microsecond)
+	}
+	s.lastTime = now
+	return now
+}
+
+// stamp fills in the ID, created and updated fields. Updated is always set when touch is true. Caller must hold the
+// lock.
+func (s *Server) stamp(doc Document, touch bool) Document {
+	now := s.now().Format(time.RFC3339Nano)
+	if str(doc["_id"]) == "" {
+		doc["_id"] = s.newID()
+	}
+	if str(doc["created"]) == "" {
+		doc["created"] = now
+	}
+	if touch || str(doc["updated"]) == "" {
+		doc["updated"] = now
+	}
+	return doc
+}
+
+func find(docs []Document, id string) Document {
+	for _, doc := range docs {
+		if str(doc["_id"]) == id {
+			return doc
+		}
+	}
+	return nil
+}
+
+// documents returns the objects in a JSON array field
+func documents(value interface{}) []Document {
+	list, _ := value.([]interface{})
+	docs := []Document{}
+	for _, item := range list {
+		switch doc := item.(type) {
+		case map[string]interface{}:
+			docs = append(docs, Document(doc))
+		case Document:
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func str(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// clone deep copies a document through JSON, so callers never share maps with the server
+func clone(doc Document) Document {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic(fmt.Sprintf("fakeapi: document is not JSON: %v", err))
+	}
+	copied := Document{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+func cloneAll(docs []Document) []Document {
+	copied := []Document{}
+	for _, doc := range docs {
+		copied = append(copied, clone(doc))
+	}
+	return copied
+}
@@ -0,0 +1,91 @@
+package fakeapi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Faults are applied to every request at random, to see how the bridge copes with a slow or unreliable API
+type Faults struct {
+	// Latency is added to every request
+	Latency Duration `json:"latency"`
+	// RateLimitRate is the share of requests (0-1) answered with a 429
+	RateLimitRate float64 `json:"rateLimitRate"`
+	// ErrorRate is the share of requests (0-1) answered with a 500
+	ErrorRate float64 `json:"errorRate"`
+	// RetryAfter is sent with 429s, in seconds. Default: 1
+	RetryAfter int `json:"retryAfter"`
+}
+
+// Duration is a time.Duration written as a string in seed files, ex. "250ms"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Duration(d).String() + `"`), nil
+}
+
+// failure answers the next matching requests with a status code
+type failure struct {
+	method string
+	path   string
+	status int
+	times  int
+}
+
+// SetFaults replaces the random faults
+func (s *Server) SetFaults(faults Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = faults
+}
+
+// FailNext answers the next requests whose path starts with the prefix (and method, unless empty) with the status code,
+// ex. FailNext("POST", "/buyer/orders", 500, 1). Failures are used in the order they were added.
+func (s *Server) FailNext(method string, pathPrefix string, status int, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{method: method, path: pathPrefix, status: status, times: times})
+}
+
+// fault returns the status code to fail the request with, or 0, and the latency to add. Caller must hold the lock.
+func (s *Server) fault(r *http.Request) (int, time.Duration) {
+	latency := time.Duration(s.faults.Latency)
+	for i := range s.failures {
+		f := &s.failures[i]
+		if f.times <= 0 || (f.method != "" && f.method != r.Method) || !strings.HasPrefix(r.URL.Path, f.path) {
+			continue
+		}
+		f.times--
+		return f.status, latency
+	}
+	if s.faults.RateLimitRate > 0 && s.random.Float64() < s.faults.RateLimitRate {
+		return http.StatusTooManyRequests, latency
+	}
+	if s.faults.ErrorRate > 0 && s.random.Float64() < s.faults.ErrorRate {
+		return http.StatusInternalServerError, latency
+	}
+	return 0, latency
+}
+
+// retryAfter returns the Retry-After header sent with 429s. Caller must hold the lock.
+func (s *Server) retryAfter() int {
+	if s.faults.RetryAfter > 0 {
+		return s.faults.RetryAfter
+	}
+	return 1
+}
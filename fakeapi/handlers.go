@@ -0,0 +1,360 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLimit is the largest page the API returns
+const maxLimit = 250
+
+// ServeHTTP answers a request to the fake API. Every request needs an account's API key in the Authorization header,
+// except the /_fake/state dump of every account.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status, latency := s.fault(r)
+	retryAfter := s.retryAfter()
+	s.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.accounts[r.Header.Get("Authorization")]
+	recorded := Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery}
+	if account != nil {
+		recorded.Account = account.Name
+	}
+	defer func() {
+		s.requests = append(s.requests, recorded)
+	}()
+
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		recorded.Status = writeError(w, status, "injected fault")
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/_fake/state" {
+		recorded.Status = s.state(w)
+		return
+	}
+	if account == nil {
+		recorded.Status = writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+	recorded.Status = s.route(w, r, account)
+}
+
+// route calls the handler for the endpoint. Returns the status code written.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, account *Account) int {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "products":
+		switch r.Method {
+		case http.MethodGet:
+			return s.listProducts(w, r.URL.Query(), account)
+		case http.MethodPost:
+			return s.createProduct(w, r, account)
+		}
+	case len(parts) == 2 && parts[0] == "products":
+		switch r.Method {
+		case http.MethodGet:
+			return writeDocument(w, find(account.Products, parts[1]))
+		case http.MethodPut:
+			return s.updateProduct(w, r, account, parts[1])
+		}
+	case len(parts) == 1 && parts[0] == "orders":
+		if r.Method == http.MethodGet {
+			return s.listOrders(w, r.URL.Query(), account)
+		}
+	case len(parts) == 2 && parts[0] == "orders":
+		if r.Method == http.MethodGet {
+			return writeDocument(w, find(account.Orders, parts[1]))
+		}
+	case len(parts) == 3 && parts[0] == "orders" && parts[2] == "fulfillments":
+		if r.Method == http.MethodPost {
+			return s.createFulfillment(w, r, account, parts[1])
+		}
+	case len(parts) == 2 && parts[0] == "buyer" && parts[1] == "orders":
+		if r.Method == http.MethodPost {
+			return s.createBuyerOrder(w, r, account)
+		}
+	default:
+		return writeError(w, http.StatusNotFound, "not found")
+	}
+	return writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// listProducts :: GET /products, filtered by productCode and updatedSince
+func (s *Server) listProducts(w http.ResponseWriter, query url.Values, account *Account) int {
+	since, err := updatedSince(query)
+	if err != nil {
+		return writeError(w, http.StatusBadRequest, err.Error())
+	}
+	matches := []Document{}
+	for _, product := range account.Products {
+		if code := query.Get("productCode"); code != "" && str(product["code"]) != code {
+			continue
+		}
+		if !updatedAfter(product, since) {
+			continue
+		}
+		matches = append(matches, product)
+	}
+	return writePage(w, query, matches)
+}
+
+// createProduct :: POST /products. The product gets a new ID, as do its variants.
+func (s *Server) createProduct(w http.ResponseWriter, r *http.Request, account *Account) int {
+	product, err := readDocument(r)
+	if err != nil {
+		return writeError(w, http.StatusBadRequest, err.Error())
+	}
+	if str(product["code"]) == "" {
+		return writeError(w, http.StatusBadRequest, "code is required")
+	}
+	product["_id"] = s.newID()
+	delete(product, "created")
+	delete(product, "updated")
+	for _, variant := range documents(product["variants"]) {
+		variant["_id"] = s.newID()
+	}
+	s.stamp(product, true)
+	account.Products = append(account.Products, product)
+	return writeJSON(w, http.StatusCreated, product)
+}
+
+// updateProduct :: PUT /products/{id}. The product is replaced, keeping its ID and created time.
+func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request, account *Account, id string) int {
+	existing := find(account.Products, id)
+	if existing == nil {
+		return writeError(w, http.StatusNotFound, "product not found")
+	}
+	product, err := readDocument(r)
+	if err != nil {
+		return writeError(w, http.StatusBadRequest, err.Error())
+	}
+	created := existing["created"]
+	for key := range existing {
+		delete(existing, key)
+	}
+	for key, value := range product {
+		existing[key] = value
+	}
+	existing["_id"] = id
+	existing["created"] = created
+	s.stamp(existing, true)
+	return writeJSON(w, http.StatusOK, existing)
+}
+
+// listOrders :: GET /orders, filtered by shipped, sellerOrderCode, buyerOrderCode and updatedSince
+func (s *Server) listOrders(w http.ResponseWriter, query url.Values, account *Account) int {
+	since, err := updatedSince(query)
+	if err != nil {
+		return writeError(w, http.StatusBadRequest, err.Error())
+	}
+	matches := []Document{}
+	for _, order := range account.Orders {
+		if shipped := query.Get("shipped"); shipped != "" && strconv.FormatBool(order["shipped"] == true) != shipped {
+			continue
+		}
+		if code := query.Get("sellerOrderCode"); code != "" && str(order["sellerOrderCode"]) != code {
+			continue
+		}
+		if code := query.Get("buyerOrderCode"); code != "" && str(order["buyerOrderCode"]) != code {
+			continue
+		}
+		if !updatedAfter(order, since) {
+			continue
+		}
+		matches = append(matches, order)
+	}
+	return writePage(w, query, matches)
+}
+
+// createFulfillment :: POST /orders/{id}/fulfillments. The order is marked as shipped.
+func (s *Server) createFulfillment(w http.ResponseWriter, r *http.Request, account *Account, orderID string) int {
+	order := find(account.Orders, orderID)
+	if order == nil {
+		return writeError(w, http.StatusNotFound, "order not found")
+	}
+	fulfillment, err := readDocument(r)
+	if err != nil {
+		return writeError(w, http.StatusBadRequest, err.Error())
+	}
+	fulfillment["_id"] = s.newID()
+	fulfillments, _ := order["fulfillments"].([]interface{})
+	order["fulfillments"] = append(fulfillments, map[string]interface{}(fulfillment))
+	s.stamp(order, true)
+	order["shipped"] = true
+	order["shippedDate"] = order["updated"]
+	return writeJSON(w, http.StatusCreated, order)
+}
+
+// createBuyerOrder :: POST /buyer/orders. The buyer order is kept and split by supplier, the companyId of each item's
+// product: an order with the buyer reference as its buyerOrderCode is added to the account's orders for each supplier,
+// each item pointing at the variant's code. The response lists the orders as sellerOrders.
+func (s *Server) createBuyerOrder(w http.ResponseWriter, r *http.Request, account *Account) int {
+	buyerOrder, err := readDocument(r)
+	if err != nil {
+		return writeError(w, http.StatusBadRequest, err.Error())
+	}
+	if str(buyerOrder["buyerReference"]) == "" {
+		return writeError(w, http.StatusBadRequest, "buyerReference is required")
+	}
+	items := documents(buyerOrder["items"])
+	if len(items) == 0 {
+		return writeError(w, http.StatusBadRequest, "items are required")
+	}
+
+	suppliers := []string{}
+	supplierItems := map[string][]Document{}
+	for _, item := range items {
+		product, variant := findVariant(account.Products, str(item["variantId"]))
+		if variant == nil {
+			return writeError(w, http.StatusBadRequest, "variant not found: "+str(item["variantId"]))
+		}
+		item["id"] = s.newID()
+		supplier := str(product["companyId"])
+		if _, ok := supplierItems[supplier]; !ok {
+			suppliers = append(suppliers, supplier)
+		}
+		supplierItems[supplier] = append(supplierItems[supplier], Document{
+			"_id":               item["id"],
+			"sellerVariantCode": variant["code"],
+			"quantity":          item["quantity"],
+			"variantId":         item["variantId"],
+		})
+	}
+	buyerOrder["id"] = s.newID()
+	now := s.now().Format(time.RFC3339Nano)
+	buyerOrder["created"] = now
+	buyerOrder["updated"] = now
+
+	sellerOrders := []interface{}{}
+	for _, supplier := range suppliers {
+		orderItems := []interface{}{}
+		sellerOrderItems := []interface{}{}
+		for _, item := range supplierItems[supplier] {
+			sellerOrderItems = append(sellerOrderItems, map[string]interface{}{
+				"id": item["_id"], "variantId": item["variantId"], "quantity": item["quantity"],
+			})
+			delete(item, "variantId")
+			orderItems = append(orderItems, map[string]interface{}(item))
+		}
+		order := s.stamp(Document{
+			"buyerOrderCode":  buyerOrder["buyerReference"],
+			"sellerCompanyId": supplier,
+			"shippingAddress": buyerOrder["address"],
+			"items":           orderItems,
+			"posted":          true,
+			"shipped":         false,
+		}, true)
+		account.Orders = append(account.Orders, order)
+		sellerOrders = append(sellerOrders, map[string]interface{}{
+			"id":             order["_id"],
+			"buyerOrderId":   buyerOrder["id"],
+			"buyerReference": buyerOrder["buyerReference"],
+			"companyId":      supplier,
+			"posted":         true,
+			"fulfilled":      false,
+			"items":          sellerOrderItems,
+		})
+	}
+	buyerOrder["sellerOrders"] = sellerOrders
+	account.BuyerOrders = append(account.BuyerOrders, buyerOrder)
+	return writeJSON(w, http.StatusCreated, buyerOrder)
+}
+
+// state :: GET /_fake/state, every account's products and orders for inspection
+func (s *Server) state(w http.ResponseWriter) int {
+	accounts := []*Account{}
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	return writeJSON(w, http.StatusOK, map[string]interface{}{"accounts": accounts})
+}
+
+// findVariant returns the variant with the ID and its product, nil when there is none
+func findVariant(products []Document, variantID string) (Document, Document) {
+	for _, product := range products {
+		for _, variant := range documents(product["variants"]) {
+			if str(variant["_id"]) == variantID {
+				return product, variant
+			}
+		}
+	}
+	return nil, nil
+}
+
+// updatedSince parses the updatedSince filter, the zero time when there is none
+func updatedSince(query url.Values) (time.Time, error) {
+	if query.Get("updatedSince") == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, query.Get("updatedSince"))
+}
+
+func updatedAfter(doc Document, since time.Time) bool {
+	if since.IsZero() {
+		return true
+	}
+	updated, err := time.Parse(time.RFC3339Nano, str(doc["updated"]))
+	return err == nil && !updated.Before(since)
+}
+
+// writePage writes the page of documents asked for with page (from 0) and limit
+func writePage(w http.ResponseWriter, query url.Values, docs []Document) int {
+	page, _ := strconv.Atoi(query.Get("page"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		limit = maxLimit
+	}
+	start := page * limit
+	if page < 0 || start > len(docs) {
+		start = len(docs)
+	}
+	end := start + limit
+	if end > len(docs) {
+		end = len(docs)
+	}
+	return writeJSON(w, http.StatusOK, docs[start:end])
+}
+
+func writeDocument(w http.ResponseWriter, doc Document) int {
+	if doc == nil {
+		return writeError(w, http.StatusNotFound, "not found")
+	}
+	return writeJSON(w, http.StatusOK, doc)
+}
+
+func readDocument(r *http.Request) (Document, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc := Document{}
+	err = json.Unmarshal(body, &doc)
+	return doc, err
+}
+
+func writeError(w http.ResponseWriter, status int, message string) int {
+	return writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) int {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+	return status
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/holds"
+	"distribution-bridge/logger"
+	"distribution-bridge/orders"
+	"distribution-bridge/status"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const holdsUsage = `Usage:
+  holds list [--rejected]        List the orders held instead of being forwarded to the supplier, or the rejected ones
+  holds inspect <id>             Show a single held order
+  holds approve <id>             Approve a held order and forward it now
+  holds reject <id> [note...]    Reject a held order so it is never forwarded
+  holds edit <id> <field>=<value>...
+                                 Edit the shipping address a held order is forwarded with, ex. zip="N2L 3G1"`
+
+// holdsCommand lists, inspects and reviews the orders in a bridge's hold queue. Returns the exit code.
+func holdsCommand(args []string, bridges []*env.Bridge) int {
+	if len(args) == 0 {
+		fmt.Println(holdsUsage)
+		return 2
+	}
+	if len(bridges) != 1 {
+		logger.Info("Several bridges are configured, choose one with --bridge <name>")
+		return 2
+	}
+	bridge := bridges[0]
+	arg := ""
+	if len(args) > 1 {
+		arg = args[1]
+	}
+
+	switch args[0] {
+	case "list":
+		entries, err := holds.List(bridge, arg == "--rejected")
+		if err != nil {
+			logger.Error("failed to list the held orders", err)
+			return 1
+		}
+		printHolds(entries)
+		return 0
+	case "inspect":
+		entry, err := holds.Get(bridge, arg)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to inspect %q", arg), err)
+			return 1
+		}
+		out, _ := json.MarshalIndent(entry, "", "  ")
+		fmt.Println(string(out))
+		return 0
+	case "approve":
+		requireEnvVariables(bridges)
+		_, err := holds.Approve(bridge, arg)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to approve %q", arg), err)
+			return 1
+		}
+		logger.Info(fmt.Sprintf("Approved %s", arg))
+		err = forwardHeld(bridge, arg)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to forward %q, it is forwarded by the next sync", arg), err)
+			return 1
+		}
+		return 0
+	case "reject":
+		note := ""
+		if len(args) > 2 {
+			note = strings.Join(args[2:], " ")
+		}
+		_, err := holds.Reject(bridge, arg, note)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to reject %q", arg), err)
+			return 1
+		}
+		logger.Info(fmt.Sprintf("Rejected %s", arg))
+		return 0
+	case "edit":
+		if len(args) < 3 {
+			fmt.Println(holdsUsage)
+			return 2
+		}
+		changes := map[string]string{}
+		for _, pair := range args[2:] {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				fmt.Println(holdsUsage)
+				return 2
+			}
+			changes[parts[0]] = parts[1]
+		}
+		data, _ := json.Marshal(changes)
+		entry, err := holds.Edit(bridge, arg, data)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to edit %q", arg), err)
+			return 1
+		}
+		out, _ := json.MarshalIndent(entry.Edited, "", "  ")
+		fmt.Println(string(out))
+		return 0
+	}
+	fmt.Println(holdsUsage)
+	return 2
+}
+
+// forwardHeld syncs a held order right away, ex. once it is approved. Orders that are still held stay in the queue.
+func forwardHeld(bridge *env.Bridge, id string) error {
+	mu := bridgeMu(bridge)
+	mu.Lock()
+	defer mu.Unlock()
+	run := status.Start(bridge, "holds")
+	err := orders.SyncNewOrder(run, id)
+	run.Finish(err == nil)
+	return err
+}
+
+// holdsPath returns where a bridge's held orders are reviewed: /holds, or /holds/<name> for named bridges
+func holdsPath(bridge *env.Bridge) string {
+	if bridge.Named() {
+		return "/holds/" + bridge.Name
+	}
+	return "/holds"
+}
+
+func printHolds(entries []holds.Entry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tORDER CODE\tSTATUS\tHELD\tREASONS")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.OrderCode, entry.Status, entry.Held.Format(time.RFC3339), truncate(strings.Join(entry.Reasons, ", "), 80))
+	}
+	w.Flush()
+}
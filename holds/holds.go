@@ -0,0 +1,263 @@
+// Package holds keeps the retailer orders that were held back instead of being forwarded to the supplier, ex. because
+// their shipping address doesn't look deliverable, until someone reviews them.
+package holds
+
+import (
+	"bytes"
+	"distribution-bridge/address"
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const fileName = "holds.json"
+
+// Statuses of a held order
+const (
+	StatusHeld     = "held"     // Waiting for review
+	StatusApproved = "approved" // Forwarded on the next sync, whatever held it
+	StatusRejected = "rejected" // Never forwarded
+)
+
+var (
+	// ErrNotFound is returned when there is no held order with the ID
+	ErrNotFound = errors.New("error: held order not found")
+	// ErrReviewed is returned when changing an order that was already rejected
+	ErrReviewed = errors.New("error: held order was already rejected")
+	// ErrInvalidChanges is returned when address changes aren't a JSON object of address fields
+	ErrInvalidChanges = errors.New("error: invalid address changes")
+)
+
+// Entry is a retailer order held back from the supplier
+type Entry struct {
+	ID        string          `json:"id"` // Seller account order ID
+	OrderCode string          `json:"orderCode"`
+	Status    string          `json:"status"`
+	Reasons   []string        `json:"reasons"`
+	Address   address.Address `json:"address"` // The shipping address, normalized
+	// Edited replaces the order's shipping address when it is forwarded
+	Edited   *address.Address `json:"edited,omitempty"`
+	Held     time.Time        `json:"held"`
+	Updated  time.Time        `json:"updated"`
+	Reviewed time.Time        `json:"reviewed,omitempty"`
+	Note     string           `json:"note,omitempty"` // Why it was rejected
+}
+
+// queue is a bridge's held orders, kept in the bridge's state directory. It is read again for every operation so a
+// review made by another process (ex. the holds command while serve is running) is never overwritten.
+type queue struct {
+	bridge  *env.Bridge
+	entries map[string]*Entry
+}
+
+func newQueue(bridge *env.Bridge, list []*Entry) *queue {
+	q := &queue{bridge: bridge, entries: map[string]*Entry{}}
+	for _, entry := range list {
+		q.entries[entry.ID] = entry
+	}
+	return q
+}
+
+// read returns the bridge's queue as it is on disk
+func read(bridge *env.Bridge) (*queue, error) {
+	list := []*Entry{}
+	err := store.Read(bridge.GetStateDir(), fileName, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the held orders of %s: %w", bridge.Name, err)
+	}
+	q := newQueue(bridge, list)
+	q.updateMetrics()
+	return q, nil
+}
+
+// update reads the bridge's queue, applies the change and saves the queue when the change returns true, holding the
+// queue's lock throughout. An error from the change is returned as is and nothing is saved.
+func update(bridge *env.Bridge, change func(q *queue) (bool, error)) error {
+	list := []*Entry{}
+	var q *queue
+	var changeErr error
+	err := store.Update(bridge.GetStateDir(), fileName, &list, func() (bool, error) {
+		q = newQueue(bridge, list)
+		changed, err := change(q)
+		if err != nil {
+			changeErr = err
+			return false, err
+		}
+		if changed {
+			list = q.list()
+		}
+		return changed, nil
+	})
+	if changeErr != nil {
+		return changeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save the held orders of %s: %w", bridge.Name, err)
+	}
+	q.updateMetrics()
+	return nil
+}
+
+// Hold adds an order to the bridge's held orders, or updates its reasons and address if it is already held. Edits and
+// reviews of an order already held are kept. Returns true when the order wasn't held before.
+func Hold(bridge *env.Bridge, entry Entry) (bool, error) {
+	held := false
+	err := update(bridge, func(q *queue) (bool, error) {
+		now := time.Now()
+		entry.Updated = now
+		entry.Status = StatusHeld
+		var existing *Entry
+		existing, held = q.entries[entry.ID]
+		if held {
+			entry.Status = existing.Status
+			entry.Edited = existing.Edited
+			entry.Held = existing.Held
+			entry.Reviewed = existing.Reviewed
+			entry.Note = existing.Note
+		} else {
+			entry.Held = now
+			logger.Info(fmt.Sprintf("Held order %s :: %v", bridge.Label(entry.ID), entry.Reasons))
+		}
+		q.entries[entry.ID] = &entry
+		return true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return !held, nil
+}
+
+// Approve marks a held order to be forwarded, whatever held it
+func Approve(bridge *env.Bridge, id string) (Entry, error) {
+	return review(bridge, id, func(entry *Entry) error {
+		entry.Status = StatusApproved
+		entry.Reviewed = time.Now()
+		return nil
+	})
+}
+
+// Reject marks a held order to never be forwarded, with the reason (ex. "fraud")
+func Reject(bridge *env.Bridge, id string, note string) (Entry, error) {
+	return review(bridge, id, func(entry *Entry) error {
+		entry.Status = StatusRejected
+		entry.Reviewed = time.Now()
+		entry.Note = note
+		return nil
+	})
+}
+
+// Edit changes fields of the shipping address the held order is forwarded with, given as a JSON object (ex.
+// {"zip": "N2L 3G1"}). The order is checked again with the edited address on the next sync, unless it is approved.
+func Edit(bridge *env.Bridge, id string, changes []byte) (Entry, error) {
+	return review(bridge, id, func(entry *Entry) error {
+		edited := entry.Address
+		if entry.Edited != nil {
+			edited = *entry.Edited
+		}
+		decoder := json.NewDecoder(bytes.NewReader(changes))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&edited)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidChanges, err)
+		}
+		entry.Edited = &edited
+		return nil
+	})
+}
+
+// review changes a held order that wasn't rejected
+func review(bridge *env.Bridge, id string, change func(entry *Entry) error) (Entry, error) {
+	var reviewed Entry
+	err := update(bridge, func(q *queue) (bool, error) {
+		entry, ok := q.entries[id]
+		if !ok {
+			return false, ErrNotFound
+		}
+		reviewed = *entry
+		if entry.Status == StatusRejected {
+			return false, ErrReviewed
+		}
+		err := change(&reviewed)
+		if err != nil {
+			reviewed = *entry
+			return false, err
+		}
+		reviewed.Updated = time.Now()
+		*entry = reviewed
+		return true, nil
+	})
+	return reviewed, err
+}
+
+// Release removes an order from the bridge's held orders, ex. once it no longer needs holding. Orders that aren't
+// held are ignored.
+func Release(bridge *env.Bridge, id string) error {
+	return update(bridge, func(q *queue) (bool, error) {
+		if _, ok := q.entries[id]; !ok {
+			return false, nil
+		}
+		delete(q.entries, id)
+		logger.Info(fmt.Sprintf("Released held order %s", bridge.Label(id)))
+		return true, nil
+	})
+}
+
+// List returns the bridge's held orders waiting for review or approved, or the rejected ones, oldest first
+func List(bridge *env.Bridge, rejected bool) ([]Entry, error) {
+	q, err := read(bridge)
+	if err != nil {
+		return nil, err
+	}
+	list := []Entry{}
+	for _, entry := range q.entries {
+		if (entry.Status == StatusRejected) == rejected {
+			list = append(list, *entry)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Held.Equal(list[j].Held) {
+			return list[i].Held.Before(list[j].Held)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// Get returns a single held order of the bridge's
+func Get(bridge *env.Bridge, id string) (Entry, error) {
+	q, err := read(bridge)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, ok := q.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return *entry, nil
+}
+
+// list returns the entries sorted by ID, as they are saved
+func (q *queue) list() []*Entry {
+	list := []*Entry{}
+	for _, entry := range q.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (q *queue) updateMetrics() {
+	counts := map[string]int{StatusHeld: 0, StatusApproved: 0, StatusRejected: 0}
+	for _, entry := range q.entries {
+		counts[entry.Status]++
+	}
+	for status, count := range counts {
+		metrics.HeldOrders.Set(float64(count), q.bridge.Name, status)
+	}
+}
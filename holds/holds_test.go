@@ -0,0 +1,67 @@
+package holds
+
+import (
+	"distribution-bridge/env/envtest"
+	"distribution-bridge/store"
+	"testing"
+)
+
+func TestChangesFromAnotherProcessAreKept(t *testing.T) {
+	bridge := envtest.NewBridge(t)
+	for _, id := range []string{"order_1", "order_2"} {
+		if _, err := Hold(bridge, Entry{ID: id, Reasons: []string{"missing city"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The holds command approves an order while serve keeps holding and reviewing them
+	list := []*Entry{}
+	if err := store.Load(bridge.GetStateDir(), fileName, &list); err != nil {
+		t.Fatal(err)
+	}
+	list[0].Status = StatusApproved
+	if err := store.Save(bridge.GetStateDir(), fileName, list); err != nil {
+		t.Fatal(err)
+	}
+	isNew, err := Hold(bridge, Entry{ID: "order_1", Reasons: []string{"unknown postal code"}})
+	if err != nil || isNew {
+		t.Fatalf("got %t, %v, expected order_1 to be held already", isNew, err)
+	}
+	if _, err := Reject(bridge, "order_2", "fraud"); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := Get(bridge, "order_1")
+	if err != nil || held.Status != StatusApproved || held.Reasons[0] != "unknown postal code" {
+		t.Errorf("got %+v, %v, expected the approval to be kept", held, err)
+	}
+	if rejected, err := List(bridge, true); err != nil || len(rejected) != 1 || rejected[0].ID != "order_2" {
+		t.Errorf("got %+v, %v, expected order_2 to be rejected", rejected, err)
+	}
+}
+
+func TestRetailersFromAnotherProcessAreKept(t *testing.T) {
+	bridge := envtest.NewBridge(t)
+	if err := AddRetailer(bridge, "company_1"); err != nil {
+		t.Fatal(err)
+	}
+	list := []retailer{}
+	if err := store.Load(bridge.GetStateDir(), retailersFileName, &list); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(bridge.GetStateDir(), retailersFileName, append(list, retailer{ID: "company_2"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddRetailer(bridge, "company_3"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"company_1", "company_2", "company_3"} {
+		if known, err := KnownRetailer(bridge, id); err != nil || !known {
+			t.Errorf("%s: got %t, %v, expected a known retailer", id, known, err)
+		}
+	}
+	if known, _ := KnownRetailer(bridge, "company_4"); known {
+		t.Error("company_4 was never forwarded for")
+	}
+}
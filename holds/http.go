@@ -0,0 +1,139 @@
+package holds
+
+import (
+	"crypto/subtle"
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxBodySize caps the request bodies, they are small JSON objects
+const maxBodySize = 64 << 10
+
+// Handler serves a bridge's held orders under a path prefix (ex. /holds):
+//
+//	GET   <prefix>                the orders waiting for review or approved, ?rejected=true for the rejected ones
+//	GET   <prefix>/<id>           a single held order
+//	POST  <prefix>/<id>/approve   approves the order and forwards it
+//	POST  <prefix>/<id>/reject    rejects the order, with an optional {"note": "..."}
+//	PATCH <prefix>/<id>           edits the shipping address, ex. {"zip": "N2L 3G1"}
+//
+// Every request needs the bridge's admin token as a bearer token.
+type Handler struct {
+	bridge  *env.Bridge
+	prefix  string
+	forward func(id string) error
+}
+
+// NewHandler returns the handler of the bridge's held orders. Approved orders are forwarded with forward, which is
+// given the order ID.
+func NewHandler(bridge *env.Bridge, prefix string, forward func(id string) error) *Handler {
+	return &Handler{bridge: bridge, prefix: strings.TrimSuffix(prefix, "/"), forward: forward}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, h.prefix), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "" && r.Method == http.MethodGet:
+		entries, err := List(h.bridge, r.URL.Query().Get("rejected") == "true")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		entry, err := Get(h.bridge, parts[0])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	case len(parts) == 1 && r.Method == http.MethodPatch:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		entry, err := Edit(h.bridge, parts[0], body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	case len(parts) == 2 && parts[1] == "approve" && r.Method == http.MethodPost:
+		h.approve(w, parts[0])
+	case len(parts) == 2 && parts[1] == "reject" && r.Method == http.MethodPost:
+		var body struct {
+			Note string `json:"note"`
+		}
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err == nil && len(data) > 0 {
+			err = json.Unmarshal(data, &body)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		entry, err := Reject(h.bridge, parts[0], body.Note)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// approve approves the order and forwards it right away. When forwarding fails the order stays approved and is
+// forwarded by the next sync.
+func (h *Handler) approve(w http.ResponseWriter, id string) {
+	_, err := Approve(h.bridge, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = h.forward(id)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"id": id, "forwarded": false, "error": logger.Redact(err.Error()),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "forwarded": true})
+}
+
+// authorized is true when the request has the bridge's admin token. Without a token nothing is authorized.
+func (h *Handler) authorized(r *http.Request) bool {
+	token := h.bridge.GetAdminToken()
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrReviewed):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrInvalidChanges):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		logger.Error("failed to handle a held order request", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,113 @@
+package holds
+
+import (
+	"distribution-bridge/address"
+	"distribution-bridge/env"
+	"distribution-bridge/env/envtest"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const adminToken = "admin-token"
+
+// newHandler returns a test server for the held orders of a bridge with its own state, and the IDs forwarded
+func newHandler(t *testing.T, forwardErr error) (*httptest.Server, *env.Bridge, *[]string) {
+	bridge := envtest.NewBridge(t, "server.adminToken="+adminToken)
+	forwarded := []string{}
+	handler := NewHandler(bridge, "/holds", func(id string) error {
+		forwarded = append(forwarded, id)
+		return forwardErr
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, bridge, &forwarded
+}
+
+func request(t *testing.T, srv *httptest.Server, method string, path string, body string, token string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var decoded interface{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	if object, ok := decoded.(map[string]interface{}); ok {
+		return resp.StatusCode, object
+	}
+	return resp.StatusCode, map[string]interface{}{"list": decoded}
+}
+
+func TestHandlerRequiresTheAdminToken(t *testing.T) {
+	srv, _, _ := newHandler(t, nil)
+	for _, token := range []string{"", "wrong"} {
+		if code, _ := request(t, srv, http.MethodGet, "/holds", "", token); code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, expected 401", token, code)
+		}
+	}
+	if code, _ := request(t, srv, http.MethodGet, "/holds", "", adminToken); code != http.StatusOK {
+		t.Errorf("status = %d, expected 200", code)
+	}
+}
+
+func TestHandlerReviewsHeldOrders(t *testing.T) {
+	srv, bridge, forwarded := newHandler(t, nil)
+	for _, id := range []string{"order_1", "order_2"} {
+		_, err := Hold(bridge, Entry{ID: id, Reasons: []string{"missing city"}, Address: address.Address{Country: "CA"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, body := request(t, srv, http.MethodPatch, "/holds/order_1", `{"city": "Waterloo"}`, adminToken)
+	if code != http.StatusOK || body["edited"].(map[string]interface{})["city"] != "Waterloo" {
+		t.Errorf("unexpected edit response %d: %v", code, body)
+	}
+	if code, _ := request(t, srv, http.MethodPatch, "/holds/order_1", `{"town": "Waterloo"}`, adminToken); code != http.StatusBadRequest {
+		t.Errorf("unknown fields: status = %d, expected 400", code)
+	}
+	code, body = request(t, srv, http.MethodPost, "/holds/order_1/approve", "", adminToken)
+	if code != http.StatusOK || body["forwarded"] != true || len(*forwarded) != 1 {
+		t.Errorf("unexpected approve response %d: %v", code, body)
+	}
+	code, body = request(t, srv, http.MethodPost, "/holds/order_2/reject", `{"note": "fraud"}`, adminToken)
+	if code != http.StatusOK || body["status"] != StatusRejected || body["note"] != "fraud" {
+		t.Errorf("unexpected reject response %d: %v", code, body)
+	}
+	if code, _ := request(t, srv, http.MethodPost, "/holds/order_2/approve", "", adminToken); code != http.StatusConflict {
+		t.Errorf("approving a rejected order: status = %d, expected 409", code)
+	}
+	if code, _ := request(t, srv, http.MethodGet, "/holds/order_3", "", adminToken); code != http.StatusNotFound {
+		t.Errorf("unknown order: status = %d, expected 404", code)
+	}
+
+	_, body = request(t, srv, http.MethodGet, "/holds?rejected=true", "", adminToken)
+	list := body["list"].([]interface{})
+	if len(list) != 1 || list[0].(map[string]interface{})["id"] != "order_2" {
+		t.Errorf("unexpected rejected orders: %v", list)
+	}
+}
+
+func TestHandlerKeepsApprovedOrdersWhenForwardingFails(t *testing.T) {
+	srv, bridge, _ := newHandler(t, errors.New("supplier is down"))
+	if _, err := Hold(bridge, Entry{ID: "order_1", Reasons: []string{"missing city"}}); err != nil {
+		t.Fatal(err)
+	}
+	code, body := request(t, srv, http.MethodPost, "/holds/order_1/approve", "", adminToken)
+	if code != http.StatusBadGateway || body["forwarded"] != false {
+		t.Errorf("unexpected approve response %d: %v", code, body)
+	}
+	if entry, _ := Get(bridge, "order_1"); entry.Status != StatusApproved {
+		t.Errorf("status = %q, expected approved", entry.Status)
+	}
+}
@@ -0,0 +1,55 @@
+package holds
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/store"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const retailersFileName = "retailers.json"
+
+// retailer is a retailer the bridge forwarded an order for
+type retailer struct {
+	ID    string    `json:"id"` // Buyer company ID
+	First time.Time `json:"first"`
+}
+
+// KnownRetailer is true when the bridge has forwarded an order for the retailer before
+func KnownRetailer(bridge *env.Bridge, companyID string) (bool, error) {
+	list := []retailer{}
+	err := store.Read(bridge.GetStateDir(), retailersFileName, &list)
+	if err != nil {
+		return false, fmt.Errorf("failed to load the retailers of %s: %w", bridge.Name, err)
+	}
+	for _, known := range list {
+		if known.ID == companyID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddRetailer records that the bridge forwarded an order for the retailer. The retailers are read again under their
+// lock so one added by another process is kept.
+func AddRetailer(bridge *env.Bridge, companyID string) error {
+	if companyID == "" {
+		return nil
+	}
+	list := []retailer{}
+	err := store.Update(bridge.GetStateDir(), retailersFileName, &list, func() (bool, error) {
+		for _, known := range list {
+			if known.ID == companyID {
+				return false, nil
+			}
+		}
+		list = append(list, retailer{ID: companyID, First: time.Now()})
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the retailers of %s: %w", bridge.Name, err)
+	}
+	return nil
+}
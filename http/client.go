@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"distribution-bridge/env"
 	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -40,6 +46,51 @@ func GetRequest(urlPath string, page int, apiKey string) ([]byte, error) {
 	return resp, nil
 }
 
+// WithUpdatedSince adds the updatedSince filter to a path. The zero time leaves the path as is.
+func WithUpdatedSince(urlPath string, since time.Time) string {
+	if since.IsZero() {
+		return urlPath
+	}
+	separator := "?"
+	if strings.Contains(urlPath, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%supdatedSince=%s", urlPath, separator, url.QueryEscape(since.UTC().Format(time.RFC3339)))
+}
+
+// GetSingleRequest gets a single resource (ex. /orders/{id}), so no paging is added
+func GetSingleRequest(urlPath string, apiKey string) ([]byte, error) {
+	url := fmt.Sprintf("%s%s", env.GetBaseURL(), urlPath)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	// Add headers
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", apiKey)
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return []byte{}, err
+	}
+	return resp, nil
+}
+
+// CheckAuth makes the smallest possible request with the API key to confirm it authenticates
+func CheckAuth(apiKey string) error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/products?page=0&limit=1", env.GetBaseURL()), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", apiKey)
+
+	_, err = sendRequest(req)
+	return err
+}
+
 func PostRequest(urlPath string, apiKey string, jsonPayload []byte) ([]byte, error) {
 	return requestWithBody(urlPath, "POST", apiKey, jsonPayload)
 }
@@ -72,24 +123,99 @@ func requestWithBody(urlPath string, httpMethod string, apiKey string, jsonPaylo
 }
 
 func sendRequest(req *http.Request) ([]byte, error) {
+	endpoint := endpointTemplate(req.URL.Path)
+	limiterFor(req.Header.Get("Authorization")).Wait()
+	start := time.Now()
 	resp, err := httpClient.Do(req)
+	metrics.APIRequestDuration.Observe(time.Since(start).Seconds(), req.Method, endpoint)
 	if err != nil {
+		metrics.APIRequests.Inc(req.Method, endpoint, "error")
 		return nil, err
 	}
 	defer resp.Body.Close()
+	metrics.APIRequests.Inc(req.Method, endpoint, strconv.Itoa(resp.StatusCode))
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 	// 400+ indicates a request error, return code / body
-	fmt.Printf("resp.StatusCode :: %+v\n", resp.StatusCode)
+	logger.Info(fmt.Sprintf("resp.StatusCode :: %+v", resp.StatusCode))
 	if 400 <= resp.StatusCode {
-		return nil, fmt.Errorf("error: api error :: %d :: %q", resp.StatusCode, string(body))
+		// Response bodies can echo back keys, emails and addresses
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: logger.Redact(string(body))}
 	}
 	return body, nil
 }
 
+// APIError is returned for any 400+ response from the API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("error: api error :: %d :: %q", e.StatusCode, e.Body)
+}
+
+// ClassError is an error with an explicit class for reporting
+type ClassError struct {
+	Class string
+	Err   error
+}
+
+func (e *ClassError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass buckets an error for reporting, ex. api_4xx, api_5xx, network or decode
+func ErrorClass(err error) string {
+	var classErr *ClassError
+	if errors.As(err, &classErr) {
+		return classErr.Class
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return "auth"
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case apiErr.StatusCode >= 500:
+			return "api_5xx"
+		}
+		return "api_4xx"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return "network"
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return "decode"
+	}
+	return "other"
+}
+
+// endpointTemplate turns a path into a low cardinality label, ex. /orders/123/fulfillments -> /orders/{id}/fulfillments
+func endpointTemplate(urlPath string) string {
+	segments := strings.Split(strings.SplitN(urlPath, "?", 2)[0], "/")
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "products", "orders", "variants", "fulfillments":
+			if segments[i] != "" {
+				segments[i] = "{id}"
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
 // Parse response
 //var response models.CreateConversationResponse
 //err = json.Unmarshal(resp, &response)
@@ -0,0 +1,302 @@
+package http
+
+import (
+	"bytes"
+	"distribution-bridge/logger"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// unknownKey replaces API keys that have no alias in fixtures
+const unknownKey = "[REDACTED]"
+
+// Fixture is a recorded set of API requests and their responses
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and the response to it
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request without the host, the API key replaced by its alias (ex. seller)
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	APIKey string          `json:"apiKey"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// RecordedResponse is the status and body of a response
+type RecordedResponse struct {
+	Status     int             `json:"status"`
+	RetryAfter string          `json:"retryAfter,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// SetTransport replaces the transport used for every API request, ex. with a Recorder or Replayer. Nil restores the
+// default. Only call it before any requests are made.
+func SetTransport(transport http.RoundTripper) {
+	httpClient.Transport = transport
+}
+
+// Recorder is a transport that records every request and response, with API keys and authorization headers redacted,
+// so a run can be saved as a fixture (ex. for a bug report)
+type Recorder struct {
+	next    http.RoundTripper
+	aliases map[string]string
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder records the requests sent with next (nil for the default transport). API keys in the aliases (ex.
+// {"<seller key>": "seller"}) are recorded by their alias.
+func NewRecorder(next http.RoundTripper, aliases map[string]string) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, aliases: aliases}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		reqBody, _ = ioutil.ReadAll(body)
+		body.Close()
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		// Nothing to replay, the transport error is returned as is
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    fixtureURL(req.URL),
+			APIKey: alias(r.aliases, req.Header.Get("Authorization")),
+			Body:   r.redactBody(reqBody),
+		},
+		Response: RecordedResponse{
+			Status:     resp.StatusCode,
+			RetryAfter: resp.Header.Get("Retry-After"),
+			Body:       r.redactBody(respBody),
+		},
+	}
+	r.mu.Lock()
+	r.fixture.Interactions = append(r.fixture.Interactions, interaction)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Fixture returns what has been recorded so far
+func (r *Recorder) Fixture() Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Fixture{Interactions: append([]Interaction{}, r.fixture.Interactions...)}
+}
+
+// Save writes what has been recorded so far to a fixture file
+func (r *Recorder) Save(path string) error {
+	// Not escaped so the URLs stay readable, ex. ?page=0&limit=250
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(r.Fixture())
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// redactBody replaces the API keys with their aliases and masks any other credentials. Customer details are kept so
+// the orders replay the same way. Bodies that aren't JSON are kept as a JSON string.
+func (r *Recorder) redactBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	str := string(body)
+	for key, name := range r.aliases {
+		if key != "" {
+			str = strings.ReplaceAll(str, key, name)
+		}
+	}
+	str = logger.RedactCredentials(str)
+	if json.Valid([]byte(str)) {
+		return json.RawMessage(str)
+	}
+	quoted, _ := json.Marshal(str)
+	return quoted
+}
+
+// Replayer is a transport that answers requests from a fixture instead of the API. Requests are matched by method, API
+// key alias and URL (ignoring the host and the order of query parameters). Identical requests get the recorded
+// responses in order, and a request with no response left fails.
+type Replayer struct {
+	aliases map[string]string
+
+	mu        sync.Mutex
+	remaining map[string][]int
+	fixture   Fixture
+	used      []bool
+	requests  []RecordedRequest
+	unmatched []string
+}
+
+// NewReplayer replays the fixture. The aliases map the API keys the requests are sent with to the fixture's aliases.
+func NewReplayer(fixture Fixture, aliases map[string]string) *Replayer {
+	r := &Replayer{
+		aliases:   aliases,
+		remaining: map[string][]int{},
+		fixture:   fixture,
+		used:      make([]bool, len(fixture.Interactions)),
+	}
+	for i, interaction := range fixture.Interactions {
+		key := replayKey(interaction.Request.Method, interaction.Request.APIKey, interaction.Request.URL)
+		r.remaining[key] = append(r.remaining[key], i)
+	}
+	return r
+}
+
+// LoadFixture reads a fixture file
+func LoadFixture(path string) (Fixture, error) {
+	var fixture Fixture
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixture, err
+	}
+	err = json.Unmarshal(data, &fixture)
+	if err != nil {
+		return fixture, fmt.Errorf("failed to decode the fixture %s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		reqBody, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	apiKey := alias(r.aliases, req.Header.Get("Authorization"))
+	reqURL := fixtureURL(req.URL)
+	key := replayKey(req.Method, apiKey, reqURL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded := RecordedRequest{Method: req.Method, URL: reqURL, APIKey: apiKey}
+	if len(reqBody) > 0 {
+		recorded.Body = json.RawMessage(reqBody)
+	}
+	r.requests = append(r.requests, recorded)
+
+	queue := r.remaining[key]
+	if len(queue) == 0 {
+		r.unmatched = append(r.unmatched, key)
+		return nil, fmt.Errorf("error: no recorded response for %s", key)
+	}
+	index := queue[0]
+	r.remaining[key] = queue[1:]
+	r.used[index] = true
+
+	response := r.fixture.Interactions[index].Response
+	body := []byte(response.Body)
+	var text string
+	if json.Unmarshal(body, &text) == nil {
+		// Recorded as a JSON string because it wasn't JSON
+		body = []byte(text)
+	}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if response.RetryAfter != "" {
+		header.Set("Retry-After", response.RetryAfter)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status)),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Requests returns every request the replayer received, in order, with the bodies as sent
+func (r *Replayer) Requests() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedRequest{}, r.requests...)
+}
+
+// Unmatched returns the requests that had no recorded response left, ex. GET seller /orders?page=0
+func (r *Replayer) Unmatched() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.unmatched...)
+}
+
+// Unused returns the recorded interactions that were never replayed
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unused := []Interaction{}
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.fixture.Interactions[i])
+		}
+	}
+	return unused
+}
+
+// fixtureURL returns the path and query of a URL, with the query parameters sorted
+func fixtureURL(u *url.URL) string {
+	query := u.Query().Encode()
+	if query == "" {
+		return u.Path
+	}
+	return u.Path + "?" + query
+}
+
+// alias returns the alias of an API key
+func alias(aliases map[string]string, apiKey string) string {
+	if name, ok := aliases[apiKey]; ok {
+		return name
+	}
+	return unknownKey
+}
+
+func replayKey(method string, apiKey string, rawURL string) string {
+	// Recorded URLs may have been written by hand, normalize them the same way
+	if parsed, err := url.Parse(rawURL); err == nil {
+		rawURL = fixtureURL(parsed)
+	}
+	return fmt.Sprintf("%s %s %s", method, apiKey, rawURL)
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"distribution-bridge/env"
+	"sync"
+	"time"
+)
+
+// limiters space out the requests of each API key, so every sync job and worker using an account together stay under
+// API_RATE_LIMIT without one bridge slowing down another
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*rateLimiter{}
+)
+
+// limiterFor returns the rate limiter of an API key (the Authorization header)
+func limiterFor(apiKey string) *rateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiter, ok := limiters[apiKey]
+	if !ok {
+		limiter = &rateLimiter{}
+		limiters[apiKey] = limiter
+	}
+	return limiter
+}
+
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until the next request is allowed
+func (l *rateLimiter) Wait() {
+	rate := env.GetAPIRateLimit()
+	if rate <= 0 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(interval)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
@@ -0,0 +1,68 @@
+//go:build go1.18
+// +build go1.18
+
+package lenient
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Whatever the API sends, decoding doesn't panic, and a decoded value encodes and decodes back to itself
+
+func FuzzInt(f *testing.F) {
+	for _, seed := range []string{`2`, `"2"`, `2.5`, `null`, `""`, `"two"`, `1e30`, `{}`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var value Int
+		if json.Unmarshal(data, &value) != nil {
+			return
+		}
+		var again Int
+		encoded, _ := json.Marshal(value)
+		if err := json.Unmarshal(encoded, &again); err != nil || again != value {
+			t.Fatalf("%s decoded to %d, which decodes to %d (%v)", data, value, again, err)
+		}
+	})
+}
+
+func FuzzFloat(f *testing.F) {
+	for _, seed := range []string{`42.99`, `"42.99"`, `null`, `""`, `"Inf"`, `1e400`, `[]`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var value Float
+		if json.Unmarshal(data, &value) != nil {
+			return
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s decoded to %v, which can't be encoded: %v", data, value, err)
+		}
+		var again Float
+		if err := json.Unmarshal(encoded, &again); err != nil || again != value {
+			t.Fatalf("%s decoded to %v, which decodes to %v (%v)", data, value, again, err)
+		}
+	})
+}
+
+func FuzzTime(f *testing.F) {
+	for _, seed := range []string{`"2021-04-01T12:30:00Z"`, `"2021-04-01T12:30:00+05:30"`, `"2021-04-01"`, `null`, `""`, `0`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var value Time
+		if json.Unmarshal(data, &value) != nil {
+			return
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s decoded to %v, which can't be encoded: %v", data, value, err)
+		}
+		var again Time
+		if err := json.Unmarshal(encoded, &again); err != nil || !again.Equal(value) {
+			t.Fatalf("%s decoded to %v, which decodes to %v (%v)", data, value, again, err)
+		}
+	})
+}
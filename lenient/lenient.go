@@ -0,0 +1,129 @@
+// Package lenient has JSON types for the API's models that accept the odd shapes real payloads come in: numbers sent
+// as strings, empty strings and nulls for missing values, and dates in more than one format. They encode the same way
+// as the plain Go types.
+package lenient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Int is an int that also decodes from a numeric string ("2") or a number with a fraction (rounded). Null and "" are 0.
+type Int int
+
+// Float is a float64 that also decodes from a numeric string ("42.99"). Null and "" are 0.
+type Float float64
+
+// Time is a time.Time that also decodes from dates without a time zone (UTC) or a time. Null and "" are the zero time.
+type Time struct {
+	time.Time
+}
+
+// timeFormats are tried in order, RFC 3339 first as it is what the API sends
+var timeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func (i *Int) UnmarshalJSON(data []byte) error {
+	str, ok, err := scalar(data, "int")
+	if err != nil || !ok {
+		*i = 0
+		return err
+	}
+	if n, err := strconv.ParseInt(str, 10, 64); err == nil {
+		*i = Int(n)
+		return nil
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	// Outside of the range ParseInt accepts, rounding can't help
+	if err != nil || math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return fmt.Errorf("invalid int %s", data)
+	}
+	*i = Int(math.Round(f))
+	return nil
+}
+
+func (f *Float) UnmarshalJSON(data []byte) error {
+	str, ok, err := scalar(data, "float")
+	if err != nil || !ok {
+		*f = 0
+		return err
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	// NaN and infinities can't be encoded again
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return fmt.Errorf("invalid float %s", data)
+	}
+	*f = Float(n)
+	return nil
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	t.Time = time.Time{}
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("invalid time %s", data)
+	}
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return nil
+	}
+	for _, format := range timeFormats {
+		if parsed, err := time.Parse(format, str); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %s", data)
+}
+
+// Equal is true when both are the same instant, so go-cmp can compare models with times
+func (t Time) Equal(u Time) bool {
+	return t.Time.Equal(u.Time)
+}
+
+// scalar returns a number or string as the text of the number, false for null and "". Anything else is an error.
+func scalar(data []byte, kind string) (string, bool, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return "", false, nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return "", false, fmt.Errorf("invalid %s %s", kind, data)
+		}
+		str = strings.TrimSpace(str)
+		return str, str != "", nil
+	}
+	if len(data) == 0 || !(data[0] == '-' || data[0] >= '0' && data[0] <= '9') {
+		return "", false, fmt.Errorf("invalid %s %s", kind, data)
+	}
+	return string(data), true, nil
+}
+
+// DecodeEach decodes a JSON array, calling decode with each element, so an element that can't be decoded doesn't stop
+// the others. Only an invalid array is an error.
+func DecodeEach(data []byte, decode func(element json.RawMessage)) error {
+	var elements []json.RawMessage
+	err := json.Unmarshal(data, &elements)
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		decode(element)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package lenient
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Int
+		invalid bool
+	}{
+		{json: `2`, want: 2},
+		{json: `-7`, want: -7},
+		{json: `"2"`, want: 2},
+		{json: `" 2 "`, want: 2},
+		{json: `2.0`, want: 2},
+		{json: `2.5`, want: 3},
+		{json: `"1e3"`, want: 1000},
+		{json: `null`, want: 0},
+		{json: `""`, want: 0},
+		{json: `"two"`, invalid: true},
+		{json: `true`, invalid: true},
+		{json: `{"value":2}`, invalid: true},
+		{json: `[2]`, invalid: true},
+		{json: `1e30`, invalid: true},
+		{json: `"NaN"`, invalid: true},
+	}
+	for _, test := range tests {
+		var got Int
+		err := json.Unmarshal([]byte(test.json), &got)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %d", test.json, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %d (%v), expected %d", test.json, got, err, test.want)
+		}
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Float
+		invalid bool
+	}{
+		{json: `42.99`, want: 42.99},
+		{json: `"42.99"`, want: 42.99},
+		{json: `3`, want: 3},
+		{json: `null`, want: 0},
+		{json: `""`, want: 0},
+		{json: `"$42.99"`, invalid: true},
+		{json: `"Inf"`, invalid: true},
+		{json: `false`, invalid: true},
+	}
+	for _, test := range tests {
+		var got Float
+		err := json.Unmarshal([]byte(test.json), &got)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.json, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %v (%v), expected %v", test.json, got, err, test.want)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    time.Time
+		invalid bool
+	}{
+		{json: `"2021-04-01T12:30:00Z"`, want: time.Date(2021, 4, 1, 12, 30, 0, 0, time.UTC)},
+		{json: `"2021-04-01T12:30:00.5-04:00"`, want: time.Date(2021, 4, 1, 16, 30, 0, 5e8, time.UTC)},
+		{json: `"2021-04-01T12:30:00"`, want: time.Date(2021, 4, 1, 12, 30, 0, 0, time.UTC)},
+		{json: `"2021-04-01 12:30:00"`, want: time.Date(2021, 4, 1, 12, 30, 0, 0, time.UTC)},
+		{json: `"2021-04-01"`, want: time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{json: `"0001-01-01T00:00:00Z"`},
+		{json: `null`},
+		{json: `""`},
+		{json: `"yesterday"`, invalid: true},
+		{json: `1617280200`, invalid: true},
+	}
+	for _, test := range tests {
+		var got Time
+		err := json.Unmarshal([]byte(test.json), &got)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.json, got)
+			}
+			continue
+		}
+		if err != nil || !got.Time.Equal(test.want) {
+			t.Errorf("%s: got %v (%v), expected %v", test.json, got, err, test.want)
+		}
+	}
+}
+
+func TestTimeEncodesLikeTime(t *testing.T) {
+	now := time.Now()
+	for _, value := range []time.Time{{}, now} {
+		want, _ := json.Marshal(value)
+		got, err := json.Marshal(Time{Time: value})
+		if err != nil || string(got) != string(want) {
+			t.Errorf("got %s (%v), expected %s", got, err, want)
+		}
+	}
+}
+
+func TestDecodeEach(t *testing.T) {
+	decoded := []string{}
+	err := DecodeEach([]byte(`[{"quantity":1},{"quantity":{}},null]`), func(element json.RawMessage) {
+		decoded = append(decoded, string(element))
+	})
+	if err != nil || len(decoded) != 3 {
+		t.Fatalf("every element should be decoded on its own: %v (%v)", decoded, err)
+	}
+	if DecodeEach([]byte(`{"quantity":1}`), func(json.RawMessage) {}) == nil {
+		t.Errorf("an object is not a list")
+	}
+}
+
+// Any int, sent as a number or as a string, decodes to itself
+func TestIntProperties(t *testing.T) {
+	property := func(n int64) bool {
+		var fromNumber, fromString Int
+		errNumber := json.Unmarshal([]byte(strconv.FormatInt(n, 10)), &fromNumber)
+		errString := json.Unmarshal([]byte(strconv.Quote(strconv.FormatInt(n, 10))), &fromString)
+		return errNumber == nil && errString == nil && int64(fromNumber) == n && fromString == fromNumber
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// Any float encodes and decodes back to itself, and to the same value when sent as a string
+func TestFloatProperties(t *testing.T) {
+	property := func(f float64) bool {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return true
+		}
+		data, err := json.Marshal(Float(f))
+		if err != nil {
+			return false
+		}
+		var fromNumber, fromString Float
+		errNumber := json.Unmarshal(data, &fromNumber)
+		errString := json.Unmarshal([]byte(strconv.Quote(string(data))), &fromString)
+		return errNumber == nil && errString == nil && float64(fromNumber) == f && fromString == fromNumber
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// Any time between year 1 and 9999 encodes and decodes back to the same instant
+func TestTimeProperties(t *testing.T) {
+	min := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	max := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC).Unix()
+	property := func(seconds int64, nanos uint32, offsetMinutes int16) bool {
+		seconds = min + abs(seconds)%(max-min)
+		zone := time.FixedZone("", int(offsetMinutes%(14*60))*60)
+		value := time.Unix(seconds, int64(nanos%1e9)).In(zone)
+		if value.Year() < 1 || value.Year() > 9999 {
+			return true
+		}
+		data, err := json.Marshal(Time{Time: value})
+		if err != nil {
+			return false
+		}
+		var decoded Time
+		err = json.Unmarshal(data, &decoded)
+		return err == nil && decoded.Equal(Time{Time: value})
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -(n + 1)
+	}
+	return n
+}
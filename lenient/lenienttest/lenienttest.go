@@ -0,0 +1,53 @@
+// Package lenienttest rewrites API payloads the odd ways the API sends them, to test the lenient decoding.
+package lenienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+// Vary rewrites a JSON document the odd ways the API has been seen to send the same values: numbers as strings, dates
+// without a time zone or with padding. The models decode both documents to the same values.
+func Vary(data []byte, r *rand.Rand) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(vary(value, r))
+}
+
+func vary(value interface{}, r *rand.Rand) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			v[key] = vary(field, r)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = vary(element, r)
+		}
+	case json.Number:
+		if r.Intn(2) == 0 {
+			return v.String()
+		}
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return v
+		}
+		switch r.Intn(4) {
+		case 0:
+			return parsed.UTC().Format("2006-01-02T15:04:05.999999999")
+		case 1:
+			return parsed.UTC().Format("2006-01-02 15:04:05.999999999")
+		case 2:
+			return " " + v + " "
+		}
+	}
+	return value
+}
@@ -2,10 +2,12 @@ package logger
 
 import "fmt"
 
+// Info prints a message with any sensitive values redacted
 func Info(msg string) {
-	fmt.Printf(fmt.Sprintf("%s\n", msg))
+	fmt.Printf("%s\n", Redact(msg))
 }
 
+// Error prints a message and error with any sensitive values redacted
 func Error(msg string, err error) {
-	fmt.Printf(fmt.Sprintf("%s :: %+v\n", msg, err))
-}
\ No newline at end of file
+	fmt.Printf("%s :: %s\n", Redact(msg), Redact(fmt.Sprintf("%+v", err)))
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Categories of sensitive values masked by Redact. Any of them can be logged in clear by adding it to the allowlist.
+const (
+	CategoryAPIKey        = "api_key"
+	CategoryAuthorization = "authorization"
+	CategoryEmail         = "email"
+	CategoryPhone         = "phone"
+	CategoryAddress       = "address"
+)
+
+const mask = "[REDACTED]"
+
+type redactionRule struct {
+	category    string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// Rules are applied in order, key/value style rules first so the free text rules don't mangle their keys.
+var redactionRules = []redactionRule{
+	{CategoryAuthorization, regexp.MustCompile(`(?i)("?authorization"?\s*[:=]\s*\[?"?)(?:bearer\s+)?[^"\s,\[\]}]+`), "${1}" + mask},
+	{CategoryAuthorization, regexp.MustCompile(`(?i)(bearer\s+)[^"\s,\[\]}]+`), "${1}" + mask},
+	{CategoryAPIKey, regexp.MustCompile(`(?i)("?(?:api_?key|secret|token)"?\s*[:=]\s*"?)[^"&\s,}]+`), "${1}" + mask},
+	{CategoryEmail, regexp.MustCompile(`[A-Za-z0-9._%+\-]+(?:@|%40)[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), mask},
+	// Only phone-like characters, a struct dump (%+v) has no quote or comma after the value
+	{CategoryPhone, regexp.MustCompile(`(?i)("?phone(?:Number)?"?\s*[:=]\s*"?)[+\d\s().\-]*\d\)?`), "${1}" + mask},
+	{CategoryPhone, regexp.MustCompile(`\+\d{1,3}[\s.\-]?\(?\d{1,4}\)?(?:[\s.\-]?\d){6,10}\b|\(?\b\d{3}\)?[\s.\-]\d{3}[\s.\-]\d{4}\b`), mask},
+	// JSON payloads, ex. "addressOne":"1 Main St"
+	{CategoryAddress, regexp.MustCompile(`(?i)("(?:addressOne|addressTwo|address1|address2|street|zip|postalCode)"\s*:\s*")[^"]*`), "${1}" + mask},
+	// Go struct dumps (%+v), ex. {AddressOne:1 Main St City:Toronto}. One rule per field as each match consumes the next key.
+	{CategoryAddress, regexp.MustCompile(`\b(AddressOne:).*?( [A-Z][A-Za-z]*:|}|$)`), "${1}" + mask + "${2}"},
+	{CategoryAddress, regexp.MustCompile(`\b(AddressTwo:).*?( [A-Z][A-Za-z]*:|}|$)`), "${1}" + mask + "${2}"},
+	{CategoryAddress, regexp.MustCompile(`\b(Zip:).*?( [A-Z][A-Za-z]*:|}|$)`), "${1}" + mask + "${2}"},
+	{CategoryAddress, regexp.MustCompile(`(?i)\b\d{1,6}\s+(?:[A-Za-z0-9.'\-]+\s+){0,4}(?:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|way|place|pl|crescent|cres)\b\.?`), mask},
+}
+
+var (
+	redactMu  sync.RWMutex
+	secrets   []string
+	allowlist = map[string]bool{}
+)
+
+// AddSecrets registers exact values (ex. API keys) that are always masked, no matter where they show up.
+func AddSecrets(values ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	for _, value := range values {
+		// Very short values would mask unrelated text
+		if len(value) < 4 {
+			continue
+		}
+		found := false
+		for _, secret := range secrets {
+			if secret == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// SetAllowlist sets the categories that may be logged in clear. Everything else is redacted.
+func SetAllowlist(categories []string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	allowlist = map[string]bool{}
+	for _, category := range categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category != "" {
+			allowlist[category] = true
+		}
+	}
+}
+
+// Redact masks API keys, authorization headers, emails, phone numbers and street addresses in a string.
+func Redact(s string) string {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+
+	if !allowlist[CategoryAPIKey] {
+		for _, secret := range secrets {
+			s = strings.ReplaceAll(s, secret, mask)
+		}
+	}
+	for _, rule := range redactionRules {
+		if allowlist[rule.category] {
+			continue
+		}
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+// RedactCredentials masks only the API keys, secrets and authorization headers in a string, whatever the allowlist.
+// Customer details are kept, ex. for a recorded fixture that has to replay the same way.
+func RedactCredentials(s string) string {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, mask)
+	}
+	for _, rule := range redactionRules {
+		if rule.category == CategoryAPIKey || rule.category == CategoryAuthorization {
+			s = rule.pattern.ReplaceAllString(s, rule.replacement)
+		}
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package logger
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	AddSecrets("sk_live_12345")
+	t.Cleanup(func() { SetAllowlist(nil) })
+
+	tests := []struct {
+		name      string
+		in        string
+		want      string
+		allowlist []string
+	}{
+		{name: "known secret", in: "request failed for sk_live_12345", want: "request failed for [REDACTED]"},
+		{name: "api key json", in: `{"apiKey":"abc123","id":"1"}`, want: `{"apiKey":"[REDACTED]","id":"1"}`},
+		{name: "api key query", in: "GET /products?api_key=abc123&page=2", want: "GET /products?api_key=[REDACTED]&page=2"},
+		{name: "api key struct", in: "{APIKey:abc123 Name:test}", want: "{APIKey:[REDACTED] Name:test}"},
+		{name: "authorization json", in: `{"Authorization":["Bearer abc123"]}`, want: `{"Authorization":["[REDACTED]"]}`},
+		{name: "authorization struct", in: "map[Authorization:[abc123] Accept:[json]]", want: "map[Authorization:[[REDACTED]] Accept:[json]]"},
+		{name: "bearer", in: "sent bearer abc123 to the API", want: "sent bearer [REDACTED] to the API"},
+		{name: "email json", in: `{"buyerEmail":"jane.doe@example.com"}`, want: `{"buyerEmail":"[REDACTED]"}`},
+		{name: "email struct", in: "{BuyerEmail:jane.doe@example.com Note:gift}", want: "{BuyerEmail:[REDACTED] Note:gift}"},
+		{name: "email query", in: "/orders?email=jane%40example.com", want: "/orders?email=[REDACTED]"},
+		{name: "phone json", in: `{"phone":"+1 (416) 555-0100","name":"Jane"}`, want: `{"phone":"[REDACTED]","name":"Jane"}`},
+		{name: "phone struct", in: "{Phone:416 555 0100 Name:Jane Doe, Country:CA}", want: "{Phone:[REDACTED] Name:Jane Doe, Country:CA}"},
+		{name: "phone free text", in: "call 416-555-0100 before noon", want: "call [REDACTED] before noon"},
+		{name: "address json", in: `{"addressOne":"1 Main St","city":"Toronto","zip":"M5V 2T6"}`, want: `{"addressOne":"[REDACTED]","city":"Toronto","zip":"[REDACTED]"}`},
+		{name: "address struct", in: "{AddressOne:1 Main St AddressTwo:Unit 4 City:Toronto Zip:M5V 2T6}", want: "{AddressOne:[REDACTED] AddressTwo:[REDACTED] City:Toronto Zip:[REDACTED]}"},
+		{name: "address free text", in: "ship to 12 Queen Street West", want: "ship to [REDACTED] West"},
+		{name: "allowlisted email", in: `{"buyerEmail":"jane@example.com","phone":"4165550100"}`, want: `{"buyerEmail":"jane@example.com","phone":"[REDACTED]"}`, allowlist: []string{"email"}},
+		{name: "allowlisted phone struct", in: "{Phone:416 555 0100 AddressOne:1 Main St}", want: "{Phone:416 555 0100 AddressOne:[REDACTED]}", allowlist: []string{" Phone "}},
+		{name: "allowlisted api key", in: "key sk_live_12345", want: "key sk_live_12345", allowlist: []string{"api_key"}},
+	}
+	for _, test := range tests {
+		SetAllowlist(test.allowlist)
+		if got := Redact(test.in); got != test.want {
+			t.Errorf("%s: got %s, expected %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRedactCredentials(t *testing.T) {
+	AddSecrets("sk_live_12345")
+	SetAllowlist([]string{"api_key"})
+	t.Cleanup(func() { SetAllowlist(nil) })
+
+	in := `{"apiKey":"abc123","note":"sk_live_12345","phone":"416-555-0100","address":{"addressOne":"1 Main St","zip":"M5V 2T6"}}`
+	want := `{"apiKey":"[REDACTED]","note":"[REDACTED]","phone":"416-555-0100","address":{"addressOne":"1 Main St","zip":"M5V 2T6"}}`
+	if got := RedactCredentials(in); got != want {
+		t.Errorf("got %s, expected %s", got, want)
+	}
+}
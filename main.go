@@ -1,29 +1,346 @@
 package main
 
 import (
+	"context"
+	"distribution-bridge/checkpoint"
 	"distribution-bridge/env"
+	"distribution-bridge/holds"
+	"distribution-bridge/http"
 	"distribution-bridge/logger"
 	"distribution-bridge/orders"
+	"distribution-bridge/products"
+	"distribution-bridge/server"
+	"distribution-bridge/status"
+	"distribution-bridge/webhook"
+	"flag"
 	"fmt"
+	nethttp "net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
 	fmt.Println("Starting Distribution Bridge...")
+	configPath := flag.String("config", "", "Path to a JSON config file (Default: CONFIG_FILE)")
+	overrides := stringList{}
+	flag.Var(&overrides, "set", "Override a config key, ex. --set jobs.interval=5m (Can be repeated)")
+	bridgeName := flag.String("bridge", "", "Only use the bridge with this name (Default: every bridge)")
+	flag.Parse()
+
+	command := "run"
+	args := flag.Args()
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+	}
+
+	// Defaults < config file < environment < flags
+	err := env.Load(*configPath, overrides)
+	if err == nil && command != "secrets" {
+		// The secrets command is how missing keyfile secrets get added
+		err = env.CheckSecrets()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Never print the API keys or customer details unless explicitly allowed
+	logger.SetAllowlist(env.GetLogRedactionAllowlist())
+	if command != "secrets" {
+		for _, bridge := range env.Bridges() {
+			logger.AddSecrets(bridge.GetSellerAPIKey(), bridge.GetBuyerAPIKey(), bridge.GetWebhookSecret(), bridge.GetAdminToken(),
+				bridge.GetNotifySMTPPassword(), bridge.GetNotifyWebhookURL())
+		}
+	}
+	switch command {
+	case "run":
+		parseSyncFlags("run", args)
+		bridges := selectBridges(*bridgeName)
+		requireEnvVariables(bridges)
+		saveRecording := record(bridges)
+		ctx, cancel := shutdownContext()
+		ok := runAll(ctx, bridges)
+		cancel()
+		saveRecording()
+		if !ok {
+			// Something failed, let the scheduler (ex. cron) know
+			os.Exit(1)
+		}
+	case "serve":
+		parseSyncFlags("serve", args)
+		bridges := selectBridges(*bridgeName)
+		requireEnvVariables(bridges)
+		saveRecording := record(bridges)
+		serve(bridges)
+		saveRecording()
+	case "retries":
+		os.Exit(retriesCommand(args, selectBridges(*bridgeName)))
+	case "holds":
+		os.Exit(holdsCommand(args, selectBridges(*bridgeName)))
+	case "stock":
+		os.Exit(stockCommand(args, selectBridges(*bridgeName)))
+	case "sla":
+		os.Exit(slaCommand(args, selectBridges(*bridgeName)))
+	case "config":
+		os.Exit(configCommand(args))
+	case "secrets":
+		os.Exit(secretsCommand(args))
+	default:
+		logger.Info(fmt.Sprintf("Unknown command %q (Expected run, serve, retries, holds, stock, sla, config or secrets)", command))
+		os.Exit(2)
+	}
+}
+
+// selectBridges returns the bridge with the name, or every bridge when the name is empty. Exits when there is no such
+// bridge.
+func selectBridges(name string) []*env.Bridge {
+	if name == "" {
+		return env.Bridges()
+	}
+	bridge, err := env.GetBridge(name)
+	if err != nil {
+		logger.Error("failed to select the bridge", err)
+		os.Exit(2)
+	}
+	return []*env.Bridge{bridge}
+}
+
+// requireEnvVariables exits when the variables needed to call the API are missing
+func requireEnvVariables(bridges []*env.Bridge) {
 	// Check for variables
-	if !env.ValidEnvVariables() {
+	valid := true
+	for _, bridge := range bridges {
+		valid = bridge.Valid() && valid
+	}
+	if !valid {
 		logger.Info("Required environment variables are missing")
-		return
+		os.Exit(1)
+	}
+}
+
+// record records every API request and response to API_RECORD_FILE, when it is set. Returns the function that saves
+// the recording.
+func record(bridges []*env.Bridge) func() {
+	path := env.GetAPIRecordFile()
+	if path == "" {
+		return func() {}
+	}
+	aliases := map[string]string{}
+	for _, bridge := range bridges {
+		aliases[bridge.GetSellerAPIKey()] = bridge.Label("seller")
+		aliases[bridge.GetBuyerAPIKey()] = bridge.Label("buyer")
+	}
+	recorder := http.NewRecorder(nil, aliases)
+	http.SetTransport(recorder)
+	return func() {
+		err := recorder.Save(path)
+		if err != nil {
+			logger.Error("failed to save the API recording", err)
+			return
+		}
+		logger.Info(fmt.Sprintf("Recorded %d API requests to %s", len(recorder.Fixture().Interactions), path))
+	}
+}
+
+var (
+	syncMuLock sync.Mutex
+	syncMu     = map[string]*sync.Mutex{}
+)
+
+// bridgeMu stops the scheduled sync and webhook events from working on the same bridge's entities at once. Bridges
+// don't wait for each other.
+func bridgeMu(bridge *env.Bridge) *sync.Mutex {
+	syncMuLock.Lock()
+	defer syncMuLock.Unlock()
+	mu, ok := syncMu[bridge.Name]
+	if !ok {
+		mu = &sync.Mutex{}
+		syncMu[bridge.Name] = mu
+	}
+	return mu
+}
+
+// parseSyncFlags parses the flags shared by the commands that run sync jobs
+func parseSyncFlags(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	resume := flags.Bool("resume", false, "Continue unfinished sweeps from their checkpoint, no matter how old it is")
+	flags.Parse(args)
+	checkpoint.SetForceResume(*resume)
+}
+
+// shutdownContext returns a context that is cancelled on SIGINT or SIGTERM, so jobs stop taking new work
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-stop:
+			logger.Info("Shutting down, finishing the products and orders in progress...")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(stop)
+	}()
+	return ctx, cancel
+}
+
+// runAll runs every enabled sync job of each bridge once, the bridges side by side. Returns false if any job or entity
+// failed.
+func runAll(ctx context.Context, bridges []*env.Bridge) bool {
+	results := make([]bool, len(bridges))
+	var wg sync.WaitGroup
+	for i, bridge := range bridges {
+		wg.Add(1)
+		go func(i int, bridge *env.Bridge) {
+			defer wg.Done()
+			results[i] = runSync(ctx, bridge)
+		}(i, bridge)
 	}
+	wg.Wait()
+
+	ok := true
+	for _, result := range results {
+		ok = ok && result
+	}
+	return ok
+}
+
+// runSync runs every enabled sync job of the bridge once. Returns false if any job or entity failed. A panic only
+// fails this bridge's run.
+func runSync(ctx context.Context, bridge *env.Bridge) (ok bool) {
+	mu := bridgeMu(bridge)
+	mu.Lock()
+	defer mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(fmt.Sprintf("%s stopped unexpectedly", bridge.Label("sync")), fmt.Errorf("panic: %v", r))
+			ok = false
+		}
+	}()
+	summaries := []status.Summary{}
 
 	// Sync products
-	//products.SyncProducts()
+	if bridge.ProductSyncEnabled() {
+		summaries = append(summaries, products.SyncProducts(ctx, bridge))
+	}
 
 	// Sync orders
-	if env.DropShippingEnabled() {
-		logger.Info("Drop shipping is enabled.")
-		orders.SyncOrders()
+	if bridge.DropShippingEnabled() {
+		logger.Info(fmt.Sprintf("Drop shipping is enabled for %s.", bridge.Name))
+		summaries = append(summaries, orders.SyncOrders(ctx, bridge))
+	}
+
+	// Retry anything that failed in an earlier run and is due
+	summaries = append(summaries, processRetries(ctx, bridge))
+
+	ok = true
+	for _, summary := range summaries {
+		ok = ok && summary.OK()
+	}
+	return ok
+}
+
+// serve runs each bridge's sync jobs on its interval and exposes the HTTP endpoints until the process is stopped
+func serve(bridges []*env.Bridge) {
+	ctx, cancel := shutdownContext()
+	defer cancel()
+
+	// Webhooks trigger targeted syncs, the scheduled sync remains as the fallback sweep
+	handlers := map[string]nethttp.Handler{}
+	for _, bridge := range bridges {
+		// Register the enabled jobs so readiness waits for them
+		if bridge.ProductSyncEnabled() {
+			status.Register(bridge, "products")
+		}
+		if bridge.DropShippingEnabled() {
+			status.Register(bridge, "orders")
+		}
+
+		if bridge.GetWebhookSecret() != "" {
+			handlers[webhookPath(bridge)] = webhookReceiver(ctx, bridge)
+		}
+		// Held orders are reviewed over HTTP only with an admin token
+		if bridge.GetAdminToken() != "" {
+			path := holdsPath(bridge)
+			handler := holds.NewHandler(bridge, path, func(id string) error { return forwardHeld(bridge, id) })
+			handlers[path] = handler
+			handlers[path+"/"] = handler
+		}
+	}
+
+	srv := server.New(fmt.Sprintf(":%s", env.GetPort()), handlers)
+	go func() {
+		logger.Info(fmt.Sprintf("Listening on %s", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && err != nethttp.ErrServerClosed {
+			logger.Error("HTTP server stopped", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, bridge := range bridges {
+		wg.Add(1)
+		go func(bridge *env.Bridge) {
+			defer wg.Done()
+			schedule(ctx, bridge)
+		}(bridge)
+	}
+
+	<-ctx.Done()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down the HTTP server", err)
+	}
+	// Wait for the runs in progress
+	wg.Wait()
+	// Wait for webhook events that are being handled
+	for _, bridge := range bridges {
+		bridgeMu(bridge).Lock()
 	}
 }
 
+// schedule runs the bridge's sync jobs every interval until the context is cancelled
+func schedule(ctx context.Context, bridge *env.Bridge) {
+	ticker := time.NewTicker(bridge.GetSyncInterval())
+	defer ticker.Stop()
 
+	runSync(ctx, bridge)
+	for {
+		select {
+		case <-ticker.C:
+			runSync(ctx, bridge)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
+// webhookPath returns where a bridge receives webhooks: /webhooks/convictional, or /webhooks/convictional/<name> for
+// named bridges
+func webhookPath(bridge *env.Bridge) string {
+	if bridge.Named() {
+		return "/webhooks/convictional/" + bridge.Name
+	}
+	return "/webhooks/convictional"
+}
+
+// webhookReceiver verifies and queues the bridge's webhook events, handling them one at a time
+func webhookReceiver(ctx context.Context, bridge *env.Bridge) *webhook.Receiver {
+	receiver := webhook.NewReceiver(bridge.GetWebhookSecret, 1000, func(event webhook.Event) (err error) {
+		mu := bridgeMu(bridge)
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return webhook.Dispatch(bridge, event)
+	})
+	go receiver.Run(ctx.Done())
+	return receiver
+}
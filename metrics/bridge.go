@@ -0,0 +1,57 @@
+package metrics
+
+import "time"
+
+// Metrics exposed by the bridge
+var (
+	APIRequests = NewCounterVec("bridge_api_requests_total",
+		"Convictional API requests by method, endpoint template and status code.", "method", "endpoint", "status")
+	APIRequestDuration = NewHistogramVec("bridge_api_request_duration_seconds",
+		"Convictional API request latency.", DefaultBuckets, "method", "endpoint")
+	Retries = NewCounterVec("bridge_retries_total",
+		"Failed products and orders retried from the retry queue by kind.", "bridge", "kind")
+	Products = NewCounterVec("bridge_products_total",
+		"Products processed by the product sync by result (created, updated, unchanged, failed).", "bridge", "result")
+	OrdersForwarded = NewCounterVec("bridge_orders_forwarded_total",
+		"Retailer orders forwarded to the supplier account.", "bridge")
+	FulfillmentsCopied = NewCounterVec("bridge_fulfillments_copied_total",
+		"Supplier fulfillments copied to the retailer order.", "bridge")
+	Errors = NewCounterVec("bridge_errors_total",
+		"Errors by class.", "bridge", "class")
+	RunDuration = NewHistogramVec("bridge_sync_run_duration_seconds",
+		"Duration of each sync job run.", []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200}, "bridge", "job")
+	RetryQueue = NewGaugeVec("bridge_retry_queue_entries",
+		"Failed entities waiting to be retried (pending) or dead lettered (dead).", "bridge", "state")
+	HeldOrders = NewGaugeVec("bridge_held_orders",
+		"Retailer orders held instead of being forwarded to the supplier by status (held, approved, rejected).", "bridge", "status")
+	SplitOrders = NewGaugeVec("bridge_split_orders",
+		"Forwarded retailer orders split between several suppliers that are waiting for some of them to ship.", "bridge")
+	PurchaseOrders = NewCounterVec("bridge_stock_purchase_orders_total",
+		"Purchase orders placed with the suppliers to replenish the distributor's stock, in stock mode.", "bridge")
+	BackorderedVariants = NewGaugeVec("bridge_stock_backordered_variants",
+		"Variants with more stock reserved for retailer orders than on hand, in stock mode.", "bridge")
+	OpenPurchaseOrders = NewGaugeVec("bridge_stock_open_purchase_orders",
+		"Purchase orders the suppliers haven't shipped in full, in stock mode.", "bridge")
+	LateOrders = NewGaugeVec("bridge_sla_late_orders",
+		"Supplier orders past their ship-by deadline that haven't shipped.", "bridge")
+	SupplierShipments = NewCounterVec("bridge_sla_shipments_total",
+		"Supplier orders shipped by supplier and whether they shipped by their deadline (on_time, late).", "bridge", "supplier", "result")
+	LastSuccess = NewGaugeVec("bridge_sync_last_success_timestamp_seconds",
+		"Unix time of the last successful run of each sync job.", "bridge", "job")
+)
+
+// Product sync results
+const (
+	ProductCreated   = "created"
+	ProductUpdated   = "updated"
+	ProductUnchanged = "unchanged"
+	ProductFailed    = "failed"
+)
+
+// ObserveRun records the duration of a sync job run and, when it succeeded, the time of the last success
+func ObserveRun(bridge string, job string, start time.Time, succeeded bool) {
+	RunDuration.Observe(time.Since(start).Seconds(), bridge, job)
+	if succeeded {
+		LastSuccess.Set(float64(time.Now().Unix()), bridge, job)
+	}
+}
@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the latency buckets (seconds) used for histograms
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds a set of metrics and renders them in the Prometheus text exposition format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// DefaultRegistry is the registry served on /metrics
+var DefaultRegistry = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.metrics {
+		if existing.name() == m.name() {
+			panic(fmt.Sprintf("metric already registered :: %s", m.name()))
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every registered metric, sorted by name
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := make([]metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the registry over HTTP
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// vec is the shared label handling of all metric types
+type vec struct {
+	metricName string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	keys       []string
+	values     map[string][]string
+}
+
+func (v *vec) init(name string, help string, labelNames []string) {
+	v.metricName = name
+	v.help = help
+	v.labelNames = labelNames
+	v.values = map[string][]string{}
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+// key returns the series key for the label values, adding the series if it is new. Caller must hold the lock.
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d labels, got %d", v.metricName, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := v.values[key]; !ok {
+		v.values[key] = append([]string{}, labelValues...)
+		v.keys = append(v.keys, key)
+		sort.Strings(v.keys)
+	}
+	return key
+}
+
+func (v *vec) labels(key string, extraName string, extraValue string) string {
+	pairs := []string{}
+	for i, labelName := range v.labelNames {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labelName, labelValue(v.values[key][i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, labelValue(extraValue)))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelValue escapes a label value for the exposition format, which only escapes backslashes, quotes and new lines
+var labelValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+func (v *vec) header(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, metricType)
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	vec
+	counts map[string]float64
+}
+
+// NewCounterVec creates and registers a counter on the default registry
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{counts: map[string]float64{}}
+	c.init(name, help, labelNames)
+	DefaultRegistry.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta (must be positive) to the series with the given label values
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.key(labelValues)] += delta
+}
+
+// Value returns the current value of a series
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[strings.Join(labelValues, "\xff")]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, key := range c.keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labels(key, "", ""), formatFloat(c.counts[key]))
+	}
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	vec
+	gauges map[string]float64
+}
+
+// NewGaugeVec creates and registers a gauge on the default registry
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{gauges: map[string]float64{}}
+	g.init(name, help, labelNames)
+	DefaultRegistry.register(g)
+	return g
+}
+
+// Set sets the series with the given label values
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gauges[g.key(labelValues)] = value
+}
+
+// Value returns the current value of a series
+func (g *GaugeVec) Value(labelValues ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gauges[strings.Join(labelValues, "\xff")]
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w, "gauge")
+	for _, key := range g.keys {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labels(key, "", ""), formatFloat(g.gauges[key]))
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// NewHistogramVec creates and registers a histogram on the default registry
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{
+		buckets: buckets,
+		counts:  map[string][]uint64{},
+		sums:    map[string]float64{},
+		totals:  map[string]uint64{},
+	}
+	h.init(name, help, labelNames)
+	DefaultRegistry.register(h)
+	return h
+}
+
+// Observe records a value in the series with the given label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.key(labelValues)
+	if _, ok := h.counts[key]; !ok {
+		h.counts[key] = make([]uint64, len(h.buckets))
+	}
+	for i, bucket := range h.buckets {
+		if value <= bucket {
+			h.counts[key][i]++
+		}
+	}
+	h.sums[key] += value
+	h.totals[key]++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, key := range h.keys {
+		for i, bucket := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(key, "le", formatFloat(bucket)), h.counts[key][i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(key, "le", "+Inf"), h.totals[key])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labels(key, "", ""), formatFloat(h.sums[key]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labels(key, "", ""), h.totals[key])
+	}
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", value)
+}
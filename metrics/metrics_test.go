@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := &Registry{}
+	counter := &CounterVec{counts: map[string]float64{}}
+	counter.init("test_requests_total", "Requests.", []string{"endpoint", "status"})
+	registry.register(counter)
+	gauge := &GaugeVec{gauges: map[string]float64{}}
+	gauge.init("test_queue", "Queue.", nil)
+	registry.register(gauge)
+	histogram := &HistogramVec{buckets: []float64{.1, 1}, counts: map[string][]uint64{}, sums: map[string]float64{}, totals: map[string]uint64{}}
+	histogram.init("test_duration_seconds", "Duration.", []string{"job"})
+	registry.register(histogram)
+
+	counter.Inc("/orders/{id}", "200")
+	counter.Add(2, "/orders/{id}", "200")
+	counter.Inc(`C:\path "quoted"`+"\n", "500")
+	counter.Inc("/produits/café", "200")
+	gauge.Set(3)
+	histogram.Observe(.05, "orders")
+	histogram.Observe(.5, "orders")
+
+	var out bytes.Buffer
+	registry.Write(&out)
+	want := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{job="orders",le="0.1"} 1
+test_duration_seconds_bucket{job="orders",le="1"} 2
+test_duration_seconds_bucket{job="orders",le="+Inf"} 2
+test_duration_seconds_sum{job="orders"} 0.55
+test_duration_seconds_count{job="orders"} 2
+# HELP test_queue Queue.
+# TYPE test_queue gauge
+test_queue 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{endpoint="/orders/{id}",status="200"} 3
+test_requests_total{endpoint="/produits/café",status="200"} 1
+test_requests_total{endpoint="C:\\path \"quoted\"\n",status="500"} 1
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("unexpected exposition (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,172 @@
+// Package money has an amount type that knows its currency, so prices are rounded to the currency's minor unit and
+// amounts in different currencies are never added or compared by accident.
+package money
+
+import (
+	"distribution-bridge/lenient"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when amounts in different currencies are combined
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// maxAmount is the largest amount in minor units that converts to and from a float64 exactly
+const maxAmount = 1 << 53
+
+// decodedExponent is the number of decimal places kept for an amount without a currency, the most of any currency, so
+// it is only rounded once its currency is known
+const decodedExponent = 3
+
+// exponents are the ISO 4217 currencies that don't have 2 decimal places
+var exponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0,
+	"KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
+
+// Money is an amount in the minor unit of its currency (ex. cents). The API sends amounts as decimal numbers (ex.
+// 42.99) without their currency, so decoded amounts have no currency, and are in thousandths, until WithCurrency
+// gives them the currency of their order or account.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New returns an amount in the minor unit of the currency, ex. New(4299, "CAD") is 42.99 CAD
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: normalize(currency)}
+}
+
+// FromFloat returns a decimal amount (ex. 42.99) rounded to the minor unit of the currency
+func FromFloat(value float64, currency string) (Money, error) {
+	currency = normalize(currency)
+	minor := math.Round(value * scale(Exponent(currency)))
+	if math.IsNaN(minor) || math.Abs(minor) > maxAmount {
+		return Money{}, fmt.Errorf("amount out of range: %v", value)
+	}
+	return Money{Amount: int64(minor), Currency: currency}, nil
+}
+
+// Exponent returns the number of decimal places of the currency's minor unit, 2 for an unknown currency and 3 for an
+// amount without a currency
+func Exponent(currency string) int {
+	currency = normalize(currency)
+	if currency == "" {
+		return decodedExponent
+	}
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// ValidCurrency is true for a three letter currency code, ex. CAD
+func ValidCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float returns the amount as a decimal number, ex. 42.99
+func (m Money) Float() float64 {
+	return float64(m.Amount) / scale(Exponent(m.Currency))
+}
+
+func (m Money) String() string {
+	value := strconv.FormatFloat(m.Float(), 'f', Exponent(m.Currency), 64)
+	if m.Currency == "" {
+		return value
+	}
+	return value + " " + m.Currency
+}
+
+// IsZero is true for a zero amount in any currency
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// WithCurrency gives an amount without a currency (ex. decoded from the API) the currency, rounding it to the
+// currency's minor unit. An amount that already has a different currency is an error, it needs converting.
+func (m Money) WithCurrency(currency string) (Money, error) {
+	currency = normalize(currency)
+	if m.Currency == currency {
+		return m, nil
+	}
+	if m.Currency != "" {
+		return m, fmt.Errorf("%w: %s is not in %s", ErrCurrencyMismatch, m, currency)
+	}
+	return Money{Amount: rescale(m.Amount, Exponent(""), Exponent(currency)), Currency: currency}, nil
+}
+
+// Add returns the sum of amounts in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return m, fmt.Errorf("%w: can't add %s and %s", ErrCurrencyMismatch, m, other)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns the difference of amounts in the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return m, fmt.Errorf("%w: can't subtract %s from %s", ErrCurrencyMismatch, other, m)
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+// Markup returns the amount increased by the percent, rounded half away from zero to the currency's minor unit. An
+// amount without a currency is rounded to 2 decimal places, like most currencies, before and after the markup.
+func (m Money) Markup(percent float64) Money {
+	if normalize(m.Currency) == "" {
+		cents := math.Round(float64(m.Amount) / 10)
+		return Money{Amount: int64(math.Round(cents*(100+percent)/100)) * 10, Currency: m.Currency}
+	}
+	return Money{Amount: int64(math.Round(float64(m.Amount) * (100 + percent) / 100)), Currency: m.Currency}
+}
+
+// MarshalJSON encodes the amount as the API expects it, a decimal number without the currency
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(m.Float(), 'f', -1, 64)), nil
+}
+
+// UnmarshalJSON decodes a decimal number, also sent as a string, into thousandths without a currency. Null and "" are 0.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var value lenient.Float
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	decoded, err := FromFloat(float64(value), "")
+	if err != nil {
+		return err
+	}
+	*m = decoded
+	return nil
+}
+
+func normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+func scale(exponent int) float64 {
+	return math.Pow10(exponent)
+}
+
+// rescale converts an amount between minor units, rounding half away from zero when there are fewer decimal places
+func rescale(amount int64, from int, to int) int64 {
+	if from == to {
+		return amount
+	}
+	return int64(math.Round(float64(amount) * scale(to-from)))
+}
@@ -0,0 +1,165 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		currency string
+		want     Money
+		encoded  string
+	}{
+		{json: `42.99`, want: New(42990, ""), encoded: `42.99`},
+		{json: `"42.99"`, currency: "CAD", want: New(4299, "CAD"), encoded: `42.99`},
+		{json: `42.995`, currency: "USD", want: New(4300, "USD"), encoded: `43`},
+		{json: `4299`, currency: "JPY", want: New(4299, "JPY"), encoded: `4299`},
+		{json: `42.5`, currency: "JPY", want: New(43, "JPY"), encoded: `43`},
+		{json: `1.23`, currency: "KWD", want: New(1230, "KWD"), encoded: `1.23`},
+		{json: `1.234`, currency: "KWD", want: New(1234, "KWD"), encoded: `1.234`},
+		{json: `"0.125"`, currency: "BHD", want: New(125, "BHD"), encoded: `0.125`},
+		{json: `null`, currency: "CAD", want: New(0, "CAD"), encoded: `0`},
+		{json: `-0.01`, currency: "EUR", want: New(-1, "EUR"), encoded: `-0.01`},
+	}
+	for _, test := range tests {
+		var decoded Money
+		err := json.Unmarshal([]byte(test.json), &decoded)
+		if err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		got, err := decoded.WithCurrency(test.currency)
+		if err != nil || got != test.want {
+			t.Errorf("%s in %q: got %v (%v), expected %v", test.json, test.currency, got, err, test.want)
+		}
+		encoded, _ := json.Marshal(got)
+		if string(encoded) != test.encoded {
+			t.Errorf("%v encoded as %s, expected %s", got, encoded, test.encoded)
+		}
+	}
+
+	var invalid Money
+	if err := json.Unmarshal([]byte(`1e300`), &invalid); err == nil {
+		t.Errorf("an amount too large for minor units should be an error")
+	}
+}
+
+func TestCurrencyMismatch(t *testing.T) {
+	cad, usd := New(100, "CAD"), New(100, "USD")
+	if _, err := cad.Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("adding CAD and USD: %v", err)
+	}
+	if _, err := cad.Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("subtracting USD from CAD: %v", err)
+	}
+	if _, err := cad.WithCurrency("USD"); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("CAD isn't USD without a conversion: %v", err)
+	}
+	if sum, err := cad.Add(New(250, "cad")); err != nil || sum != New(350, "CAD") {
+		t.Errorf("got %v (%v), expected 3.50 CAD", sum, err)
+	}
+}
+
+func TestMarkup(t *testing.T) {
+	tests := []struct {
+		price   Money
+		percent float64
+		want    Money
+	}{
+		{price: New(4299, "CAD"), percent: 10, want: New(4729, "CAD")},
+		{price: New(1000, "CAD"), percent: 0.05, want: New(1001, "CAD")},
+		{price: New(1000, "CAD"), percent: 0.04, want: New(1000, "CAD")},
+		{price: New(999, "JPY"), percent: 15, want: New(1149, "JPY")},
+		{price: New(-1000, "CAD"), percent: 0.05, want: New(-1001, "CAD")},
+		// Without a currency the amount is in thousandths, and still rounded to cents
+		{price: New(9990, ""), percent: 15, want: New(11490, "")},
+		{price: New(9995, ""), percent: 0, want: New(10000, "")},
+	}
+	for _, test := range tests {
+		if got := test.price.Markup(test.percent); got != test.want {
+			t.Errorf("%v + %v%%: got %v, expected %v", test.price, test.percent, got, test.want)
+		}
+	}
+}
+
+// Any amount within range encodes and decodes back to itself in its currency
+func TestJSONProperties(t *testing.T) {
+	for _, currency := range []string{"", "USD", "JPY", "KWD"} {
+		property := func(amount int64) bool {
+			amount %= maxAmount / 1000
+			m := New(amount, currency)
+			data, err := json.Marshal(m)
+			if err != nil {
+				return false
+			}
+			var decoded Money
+			if json.Unmarshal(data, &decoded) != nil {
+				return false
+			}
+			decoded, err = decoded.WithCurrency(currency)
+			return err == nil && decoded == m
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("%q: %v", currency, err)
+		}
+	}
+}
+
+func TestRates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "rates.json")
+	err = ioutil.WriteFile(path, []byte(`{"base": "USD", "rates": {"CAD": 1.25, "JPY": 110}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rates, err := LoadRates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from Money
+		to   string
+		want Money
+	}{
+		{from: New(1000, "USD"), to: "CAD", want: New(1250, "CAD")},
+		{from: New(1250, "CAD"), to: "USD", want: New(1000, "USD")},
+		{from: New(4299, "CAD"), to: "JPY", want: New(3783, "JPY")},
+		{from: New(4299, "CAD"), to: "CAD", want: New(4299, "CAD")},
+	}
+	for _, test := range tests {
+		got, err := rates.Convert(test.from, test.to)
+		if err != nil || got != test.want {
+			t.Errorf("%v to %s: got %v (%v), expected %v", test.from, test.to, got, err, test.want)
+		}
+	}
+	if _, err := rates.Convert(New(100, "USD"), "EUR"); !errors.Is(err, ErrNoRate) {
+		t.Errorf("USD to EUR: %v", err)
+	}
+	if _, err := rates.Convert(New(100, ""), "USD"); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("an amount without a currency can't be converted: %v", err)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`{"base": "USD", "rates": {"CAD": -1}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file is read again once it changes
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if _, err := LoadRates(path); err == nil {
+		t.Errorf("a negative rate should be an error")
+	}
+}
@@ -0,0 +1,118 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoRate is returned when the rate table can't convert between two currencies
+var ErrNoRate = errors.New("no exchange rate")
+
+// Rates is a table of exchange rates against a base currency, ex. {"base": "USD", "rates": {"CAD": 1.25}} when 1 USD
+// is 1.25 CAD. It is supplied locally (ex. updated daily by a cron job), the bridge never fetches rates.
+type Rates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+type cachedRates struct {
+	modified time.Time
+	rates    Rates
+}
+
+var (
+	ratesMu sync.Mutex
+	loaded  = map[string]cachedRates{}
+)
+
+// LoadRates reads a rate table file. The file is only read again when it changes.
+func LoadRates(path string) (Rates, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Rates{}, fmt.Errorf("failed to read the rates file: %w", err)
+	}
+	ratesMu.Lock()
+	defer ratesMu.Unlock()
+	if cached, ok := loaded[path]; ok && cached.modified.Equal(info.ModTime()) {
+		return cached.rates, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Rates{}, fmt.Errorf("failed to read the rates file: %w", err)
+	}
+	var rates Rates
+	err = json.Unmarshal(data, &rates)
+	if err != nil {
+		return Rates{}, fmt.Errorf("failed to decode the rates file %s: %w", path, err)
+	}
+	err = rates.validate()
+	if err != nil {
+		return Rates{}, fmt.Errorf("invalid rates file %s: %w", path, err)
+	}
+	loaded[path] = cachedRates{modified: info.ModTime(), rates: rates}
+	return rates, nil
+}
+
+func (r Rates) validate() error {
+	if !ValidCurrency(r.Base) {
+		return fmt.Errorf("invalid base currency %q", r.Base)
+	}
+	for currency, rate := range r.Rates {
+		if !ValidCurrency(currency) {
+			return fmt.Errorf("invalid currency %q", currency)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) {
+			return fmt.Errorf("invalid rate for %s: %v", currency, rate)
+		}
+	}
+	return nil
+}
+
+// Rate returns how much of the to currency one unit of the from currency is worth
+func (r Rates) Rate(from string, to string) (float64, error) {
+	from, to = normalize(from), normalize(to)
+	if from == to {
+		return 1, nil
+	}
+	fromRate, ok := r.against(from)
+	if !ok {
+		return 0, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+	}
+	toRate, ok := r.against(to)
+	if !ok {
+		return 0, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+	}
+	return toRate / fromRate, nil
+}
+
+// against returns the rate of the currency against the base currency
+func (r Rates) against(currency string) (float64, bool) {
+	if currency == r.Base && currency != "" {
+		return 1, true
+	}
+	rate, ok := r.Rates[currency]
+	return rate, ok
+}
+
+// Convert returns the amount in the currency, rounded half away from zero to its minor unit
+func (r Rates) Convert(m Money, currency string) (Money, error) {
+	currency = normalize(currency)
+	if m.Currency == currency {
+		return m, nil
+	}
+	if m.Currency == "" {
+		return m, fmt.Errorf("%w: %s has no currency to convert from", ErrCurrencyMismatch, m)
+	}
+	rate, err := r.Rate(m.Currency, currency)
+	if err != nil {
+		return m, err
+	}
+	return FromFloat(m.Float()*rate, currency)
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Caps on how long a channel may take to accept events
+const (
+	webhookTimeout = 10 * time.Second
+	emailTimeout   = 30 * time.Second // From dialing the SMTP server to the end of the exchange
+)
+
+// Webhook posts events as JSON: {"bridge": "...", "events": [...]}
+type Webhook struct {
+	URL    string
+	Client *http.Client // Default: a client with a 10s timeout
+}
+
+// Notify posts the events in a single request. Any response but 2xx is an error.
+func (w *Webhook) Notify(events []Event) error {
+	body, err := json.Marshal(map[string]interface{}{"bridge": events[0].Bridge, "events": events})
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post to the notification webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error: the notification webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends events as a plain text email through an SMTP server, a digest as a single email
+type Email struct {
+	Server   string // host:port
+	Username string // Empty to not sign in
+	Password string
+	From     string
+	To       []string
+}
+
+// Notify sends the events in a single email
+func (e *Email) Notify(events []Event) error {
+	err := e.send(e.message(events))
+	if err != nil {
+		return fmt.Errorf("failed to email the notifications: %w", err)
+	}
+	return nil
+}
+
+// send delivers the message like smtp.SendMail, but gives up when the server doesn't answer within the email timeout
+func (e *Email) send(message []byte) error {
+	host, _, err := net.SplitHostPort(e.Server)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", e.Server, emailTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(emailTimeout))
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if e.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", e.Username, e.Password, host))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(e.From)
+	if err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		err = client.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(message)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message returns the email with its headers
+func (e *Email) message(events []Event) []byte {
+	subject := fmt.Sprintf("[%s] %s", events[0].Bridge, events[0].Subject)
+	if len(events) > 1 {
+		subject = fmt.Sprintf("[%s] %d notifications", events[0].Bridge, len(events))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, event := range events {
+		fmt.Fprintf(&b, "%s [%s] %s\r\n", event.Time.UTC().Format(time.RFC3339), event.Kind, event.Subject)
+		for _, key := range detailKeys(event.Details) {
+			fmt.Fprintf(&b, "  %s: %s\r\n", key, headerValue(event.Details[key]))
+		}
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
+
+// headerValue keeps a value on a single header line
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// File appends events to an outbox file as JSON lines, ex. for another process to pick up
+type File struct {
+	Path string
+}
+
+// Notify appends a line for each event
+func (f *File) Notify(events []Event) error {
+	err := os.MkdirAll(filepath.Dir(f.Path), 0700)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open the notification outbox: %w", err)
+	}
+	defer file.Close()
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(append(line, '\n'))
+		if err != nil {
+			return fmt.Errorf("failed to write to the notification outbox: %w", err)
+		}
+	}
+	return nil
+}
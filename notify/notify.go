@@ -0,0 +1,291 @@
+// Package notify delivers events someone should know about, ex. a supplier order past its ship-by deadline, to the
+// bridge's channels: a JSON webhook, email, an outbox file or the log. The same event is only sent once per dedup
+// window, and events can be batched into digests so on-call isn't flooded.
+package notify
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/store"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of events
+const (
+	EventProductCreateFailed = "product_create_failed" // A product couldn't be created on the seller account
+	EventOrderHeld           = "order_held"            // A retailer order was held for review
+	EventSLABreach           = "sla_breach"            // A supplier order wasn't shipped by its deadline
+	EventDeadLetter          = "dead_letter"           // A failed product or order was given up on
+	EventAuthFailure         = "auth_failure"          // The API rejected one of the bridge's API keys
+)
+
+// Channels events are routed to
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+	ChannelFile    = "file"
+	ChannelNone    = "none" // Drops the event
+)
+
+const fileName = "notify.json"
+
+// Event is something that happened on a bridge
+type Event struct {
+	Kind    string            `json:"kind"`
+	Bridge  string            `json:"bridge"`
+	Key     string            `json:"key"` // What the event is about, ex. an order ID. Events are deduplicated by kind and key.
+	Subject string            `json:"subject"`
+	Details map[string]string `json:"details,omitempty"`
+	Time    time.Time         `json:"time"`
+}
+
+// Notifier delivers events, several at once for a digest
+type Notifier interface {
+	Notify(events []Event) error
+}
+
+// Log writes events to the log
+type Log struct{}
+
+// Notify logs each event with its details
+func (Log) Notify(events []Event) error {
+	for _, event := range events {
+		logger.Info(fmt.Sprintf("Notification [%s] %s :: %s", event.Kind, event.Subject, formatDetails(event.Details)))
+	}
+	return nil
+}
+
+// state is what a bridge has sent, kept in the bridge's state directory so digests and deduplication work across runs.
+// It is read again for every operation so an event sent by another process (ex. the CLI while serve is running) is
+// never lost.
+type state struct {
+	Sent       map[string]time.Time `json:"sent"`    // Kind/key -> when it was last sent
+	Pending    []Event              `json:"pending"` // Waiting for the next digest
+	LastDigest time.Time            `json:"lastDigest"`
+	// Failed is the events a channel couldn't take, by channel. They are sent to it again with the next flush.
+	Failed map[string][]Event `json:"failed,omitempty"`
+}
+
+// update reads the bridge's state, applies the change and saves the state when the change returns true, holding the
+// state's lock throughout
+func update(bridge *env.Bridge, change func(s *state) bool) error {
+	s := &state{}
+	err := store.Update(bridge.GetStateDir(), fileName, s, func() (bool, error) {
+		if s.Sent == nil {
+			s.Sent = map[string]time.Time{}
+		}
+		if s.Pending == nil {
+			s.Pending = []Event{}
+		}
+		return change(s), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the notifications of %s: %w", bridge.Name, err)
+	}
+	return nil
+}
+
+// Send delivers an event from the bridge, unless the same event was sent within the dedup window. With a digest
+// interval the event waits for the next digest. The subject and details are redacted before they leave the bridge.
+// Failures are logged, they never fail a sync.
+func Send(bridge *env.Bridge, event Event) {
+	event.Bridge = bridge.Name
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Subject = logger.Redact(event.Subject)
+	if event.Details != nil {
+		details := map[string]string{}
+		for key, value := range event.Details {
+			details[key] = logger.Redact(value)
+		}
+		event.Details = details
+	}
+
+	var events []Event
+	loaded := false
+	err := update(bridge, func(s *state) bool {
+		loaded = true
+		window := bridge.GetNotifyDedupWindow()
+		id := event.Kind + "/" + event.Key
+		if last, ok := s.Sent[id]; ok && window > 0 && event.Time.Sub(last) < window {
+			return false
+		}
+		s.Sent[id] = event.Time
+		for sentID, sent := range s.Sent {
+			if event.Time.Sub(sent) >= window {
+				delete(s.Sent, sentID)
+			}
+		}
+		events = []Event{event}
+		if bridge.GetNotifyDigestInterval() > 0 {
+			s.Pending = append(s.Pending, event)
+			events = s.due(bridge, event.Time, false)
+		}
+		return true
+	})
+	if err != nil {
+		logger.Error("failed to save the notifications state", err)
+		if !loaded {
+			events = []Event{event}
+		}
+	}
+	deliver(bridge, events, nil)
+}
+
+// FlushDue sends the bridge's pending events as a digest once the digest interval has passed since the last one, and
+// the events a channel failed to take
+func FlushDue(bridge *env.Bridge) {
+	flush(bridge, false)
+}
+
+// Flush sends the bridge's pending events as a digest now, and the events a channel failed to take
+func Flush(bridge *env.Bridge) {
+	flush(bridge, true)
+}
+
+func flush(bridge *env.Bridge, force bool) {
+	var (
+		events []Event
+		failed map[string][]Event
+	)
+	err := update(bridge, func(s *state) bool {
+		events = s.due(bridge, time.Now(), force)
+		failed = s.Failed
+		s.Failed = nil
+		return len(events) > 0 || len(failed) > 0
+	})
+	if err != nil {
+		logger.Error("failed to save the notifications state", err)
+	}
+	deliver(bridge, events, failed)
+}
+
+// due returns the pending events and clears them when a digest is due, nil otherwise
+func (s *state) due(bridge *env.Bridge, now time.Time, force bool) []Event {
+	if len(s.Pending) == 0 || (!force && now.Sub(s.LastDigest) < bridge.GetNotifyDigestInterval()) {
+		return nil
+	}
+	events := s.Pending
+	s.Pending = []Event{}
+	s.LastDigest = now
+	return events
+}
+
+// deliver sends the events to the channels each is routed to, and the failed events to the channel that failed them.
+// The events a channel fails to take are kept for the next flush.
+func deliver(bridge *env.Bridge, events []Event, failed map[string][]Event) {
+	if len(events) == 0 && len(failed) == 0 {
+		return
+	}
+	configured := channels(bridge)
+	byChannel := map[string][]Event{}
+	for channel, events := range failed {
+		byChannel[channel] = append(byChannel[channel], events...)
+	}
+	for _, event := range events {
+		for _, channel := range route(bridge, configured, event.Kind) {
+			byChannel[channel] = append(byChannel[channel], event)
+		}
+	}
+
+	failed = map[string][]Event{}
+	for channel, routed := range byChannel {
+		notifier, ok := configured[channel]
+		if !ok {
+			// The channel was removed from the settings since it failed
+			continue
+		}
+		if err := notifier.Notify(routed); err != nil {
+			logger.Error(fmt.Sprintf("failed to send %d notifications to %s for %s, they are sent again with the next flush", len(routed), channel, bridge.Label("bridge")), err)
+			failed[channel] = routed
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	err := update(bridge, func(s *state) bool {
+		if s.Failed == nil {
+			s.Failed = map[string][]Event{}
+		}
+		for channel, routed := range failed {
+			s.Failed[channel] = append(s.Failed[channel], routed...)
+		}
+		return true
+	})
+	if err != nil {
+		logger.Error("failed to save the notifications state", err)
+	}
+}
+
+// channels returns the bridge's configured channels by name
+func channels(bridge *env.Bridge) map[string]Notifier {
+	configured := map[string]Notifier{ChannelLog: Log{}}
+	if url := bridge.GetNotifyWebhookURL(); url != "" {
+		configured[ChannelWebhook] = &Webhook{URL: url}
+	}
+	if server := bridge.GetNotifySMTPServer(); server != "" {
+		configured[ChannelEmail] = &Email{
+			Server:   server,
+			Username: bridge.GetNotifySMTPUsername(),
+			Password: bridge.GetNotifySMTPPassword(),
+			From:     bridge.GetNotifyEmailFrom(),
+			To:       bridge.GetNotifyEmailTo(),
+		}
+	}
+	if path := bridge.GetNotifyFile(); path != "" {
+		configured[ChannelFile] = &File{Path: path}
+	}
+	return configured
+}
+
+// route returns the channels an event goes to: its route, the * route, or every configured channel (the log when
+// there are none)
+func route(bridge *env.Bridge, configured map[string]Notifier, kind string) []string {
+	routes := bridge.GetNotifyRoutes()
+	names, ok := routes[kind]
+	if !ok {
+		names, ok = routes["*"]
+	}
+	if !ok {
+		names = []string{}
+		for name := range configured {
+			if name != ChannelLog {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			names = []string{ChannelLog}
+		}
+	}
+	routed := []string{}
+	for _, name := range names {
+		if _, ok := configured[name]; ok {
+			routed = append(routed, name)
+		}
+	}
+	return routed
+}
+
+// formatDetails returns the details as key=value pairs, sorted by key
+func formatDetails(details map[string]string) string {
+	pairs := []string{}
+	for _, key := range detailKeys(details) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, details[key]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// detailKeys returns the keys of the details, sorted
+func detailKeys(details map[string]string) []string {
+	keys := []string{}
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"bufio"
+	"distribution-bridge/env"
+	"distribution-bridge/env/envtest"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newBridge returns a bridge with its own state and outbox file and the notification settings
+func newBridge(t *testing.T, settings ...string) (*env.Bridge, string) {
+	outbox := filepath.Join(envtest.TempDir(t), "outbox.jsonl")
+	return envtest.NewBridge(t, append([]string{"notify.file=" + outbox}, settings...)...), outbox
+}
+
+// readOutbox returns the kind/key of every event in the outbox
+func readOutbox(t *testing.T, path string) []string {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []string{}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, event.Kind+"/"+event.Key)
+	}
+	return sent
+}
+
+func TestSendDeduplicatesAndDigests(t *testing.T) {
+	bridge, outbox := newBridge(t, "notify.dedupWindow=1h", "notify.digestInterval=1h")
+
+	// The first event goes out right away, the next ones wait for the digest
+	Send(bridge, Event{Kind: EventAuthFailure, Key: "orders"})
+	Send(bridge, Event{Kind: EventAuthFailure, Key: "orders"})
+	Send(bridge, Event{Kind: EventOrderHeld, Key: "order_1"})
+	Send(bridge, Event{Kind: EventOrderHeld, Key: "order_2"})
+	Send(bridge, Event{Kind: EventOrderHeld, Key: "order_1"})
+	if sent := readOutbox(t, outbox); strings.Join(sent, ",") != "auth_failure/orders" {
+		t.Fatalf("unexpected events before the digest: %v", sent)
+	}
+	FlushDue(bridge)
+	if sent := readOutbox(t, outbox); len(sent) != 1 {
+		t.Fatalf("the digest isn't due yet: %v", sent)
+	}
+
+	// Pending events are kept on disk
+	Flush(bridge)
+	if sent := readOutbox(t, outbox); strings.Join(sent, ",") != "auth_failure/orders,order_held/order_1,order_held/order_2" {
+		t.Errorf("unexpected events after the digest: %v", sent)
+	}
+}
+
+func TestSendRoutesEvents(t *testing.T) {
+	var (
+		hookMu   sync.Mutex
+		received []Event
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Events []Event `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		hookMu.Lock()
+		received = append(received, body.Events...)
+		hookMu.Unlock()
+	}))
+	t.Cleanup(hook.Close)
+	bridge, outbox := newBridge(t, "notify.webhook.url="+hook.URL, "notify.routes=order_held=file,sla_breach=none,*=webhook+file")
+
+	Send(bridge, Event{Kind: EventOrderHeld, Key: "order_1"})
+	Send(bridge, Event{Kind: EventSLABreach, Key: "order_2"})
+	Send(bridge, Event{Kind: EventDeadLetter, Key: "product:1"})
+
+	if sent := readOutbox(t, outbox); strings.Join(sent, ",") != "order_held/order_1,dead_letter/product:1" {
+		t.Errorf("unexpected events in the outbox: %v", sent)
+	}
+	if len(received) != 1 || received[0].Kind != EventDeadLetter || received[0].Bridge != bridge.Name {
+		t.Errorf("unexpected events posted to the webhook: %+v", received)
+	}
+}
+
+func TestFailedEventsAreSentWithTheNextFlush(t *testing.T) {
+	var (
+		hookMu   sync.Mutex
+		down     = true
+		received []Event
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hookMu.Lock()
+		defer hookMu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var body struct {
+			Events []Event `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body.Events...)
+	}))
+	t.Cleanup(hook.Close)
+	bridge, outbox := newBridge(t, "notify.webhook.url="+hook.URL, "notify.dedupWindow=1h")
+
+	Send(bridge, Event{Kind: EventDeadLetter, Key: "product:1"})
+	FlushDue(bridge)
+	if len(received) != 0 {
+		t.Fatalf("unexpected events posted to the webhook: %+v", received)
+	}
+
+	// Once the webhook is back the event is sent to it, and only to it
+	hookMu.Lock()
+	down = false
+	hookMu.Unlock()
+	Send(bridge, Event{Kind: EventDeadLetter, Key: "product:1"})
+	FlushDue(bridge)
+	FlushDue(bridge)
+	if len(received) != 1 || received[0].Key != "product:1" {
+		t.Errorf("unexpected events posted to the webhook: %+v", received)
+	}
+	if sent := readOutbox(t, outbox); strings.Join(sent, ",") != "dead_letter/product:1" {
+		t.Errorf("unexpected events in the outbox: %v", sent)
+	}
+}
+
+func TestSendRedactsEvents(t *testing.T) {
+	bridge, outbox := newBridge(t)
+	details := map[string]string{"reasons": "PO box: 12 Main Street, jane.doe@example.com"}
+	Send(bridge, Event{Kind: EventOrderHeld, Key: "order_1", Subject: "Held for jane.doe@example.com", Details: details})
+
+	data, err := ioutil.ReadFile(outbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "jane.doe") || strings.Contains(string(data), "Main Street") {
+		t.Errorf("expected the customer's details to be redacted: %s", data)
+	}
+	if details["reasons"] != "PO box: 12 Main Street, jane.doe@example.com" {
+		t.Errorf("expected the caller's details to be kept, got %v", details)
+	}
+}
+
+// smtpServer is a local SMTP stand-in that accepts a single message
+func smtpServer(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ready")
+		data := []string{}
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case inData && line == ".":
+				inData = false
+				messages <- strings.Join(data, "\n")
+				reply("250 queued")
+			case inData:
+				data = append(data, line)
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 localhost")
+			case line == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func TestEmailSendsDigests(t *testing.T) {
+	server, messages := smtpServer(t)
+	email := &Email{Server: server, From: "bridge@example.com", To: []string{"oncall@example.com", "ops@example.com"}}
+	err := email.Notify([]Event{
+		{Kind: EventSLABreach, Bridge: "brand_a", Subject: "Order 1 is late", Details: map[string]string{"supplier": "supplier_a"}},
+		{Kind: EventOrderHeld, Bridge: "brand_a", Subject: "Order 2 was held"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := <-messages
+	for _, want := range []string{"Subject: [brand_a] 2 notifications", "To: oncall@example.com, ops@example.com", "[sla_breach] Order 1 is late", "  supplier: supplier_a", "[order_held] Order 2 was held"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("the email is missing %q:\n%s", want, message)
+		}
+	}
+}
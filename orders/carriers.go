@@ -0,0 +1,52 @@
+package orders
+
+import (
+	"distribution-bridge/carriers"
+	"distribution-bridge/env"
+	"distribution-bridge/http"
+	"distribution-bridge/logger"
+	"distribution-bridge/status"
+	"fmt"
+	"strings"
+)
+
+// carrierRegistry :: Returns the carriers fulfillments are normalized with, with the bridge's tracking URL templates
+func carrierRegistry(bridge *env.Bridge) (carriers.Registry, error) {
+	registry, err := carriers.Load(env.GetCarriersFile())
+	if err != nil {
+		return carriers.Registry{}, err
+	}
+	return registry.WithTrackingURLs(bridge.GetCarrierTrackingURLs())
+}
+
+// normalizeFulfillment :: Returns a supplier fulfillment with its carrier as a canonical code, its tracking number
+// without spaces and a tracking URL from the carrier's template when the supplier sent none. A tracking number that
+// doesn't match the carrier's formats is an error when the bridge rejects invalid tracking numbers.
+func normalizeFulfillment(run *status.Run, orderID string, registry carriers.Registry, fulfillment Fulfillment) (Fulfillment, error) {
+	carrier, ok := registry.Find(fulfillment.Carrier)
+	if !ok {
+		if strings.TrimSpace(fulfillment.Carrier) != "" {
+			logger.Info(fmt.Sprintf("Unknown carrier %q on order [%s], copied as is", fulfillment.Carrier, orderID))
+			run.Count("unknown_carrier")
+		}
+		return fulfillment, nil
+	}
+	fulfillment.Carrier = carrier.Code
+	if fulfillment.TrackingCode == "" {
+		return fulfillment, nil
+	}
+	fulfillment.TrackingCode = carriers.NormalizeTracking(fulfillment.TrackingCode)
+	if !carrier.ValidTracking(fulfillment.TrackingCode) {
+		if run.Bridge.RejectInvalidTracking() {
+			return fulfillment, &http.ClassError{Class: "invalid_tracking", Err: fmt.Errorf("error: %s is not a %s tracking number", fulfillment.TrackingCode, carrier.Name)}
+		}
+		logger.Info(fmt.Sprintf("Tracking number %s on order [%s] is not a %s tracking number, copied anyway", fulfillment.TrackingCode, orderID, carrier.Name))
+		run.Count("invalid_tracking")
+	}
+	if len(fulfillment.TrackingUrls) == 0 {
+		if url := carrier.URL(fulfillment.TrackingCode); url != "" {
+			fulfillment.TrackingUrls = []string{url}
+		}
+	}
+	return fulfillment, nil
+}
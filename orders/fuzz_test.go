@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package orders
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"testing"
+)
+
+// Whatever the API sends, decoding an order doesn't panic, and a decoded order encodes and decodes back to itself
+func FuzzDecodeOrder(f *testing.F) {
+	f.Add([]byte(orderJSON))
+	f.Add([]byte(`{"_id": "1", "items": [{"quantity": "2"}], "created": "", "fillTime": null}`))
+	f.Add([]byte(`{"_id": "1", "items": [{"quantity": {}}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		order := decodeOrder(data)
+		if order.decodeErr != nil {
+			return
+		}
+		encoded, err := json.Marshal(order)
+		if err != nil {
+			t.Fatalf("%s decoded to an order that can't be encoded: %v", data, err)
+		}
+		again := decodeOrder(encoded)
+		if again.decodeErr != nil || !cmp.Equal(again, order, cmpopts.IgnoreUnexported(Order{}), cmpopts.EquateEmpty()) {
+			t.Fatalf("%s decoded differently once encoded: %v %s", data, again.decodeErr, cmp.Diff(order, again, cmpopts.IgnoreUnexported(Order{})))
+		}
+	})
+}
+
+func FuzzDecodeBuyerOrder(f *testing.F) {
+	f.Add([]byte(buyerOrderJSON))
+	f.Add([]byte(`{"sellerOrders": [{"postedDate": "", "items": [{"basePrice": "21.50"}]}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var buyerOrder BuyerOrder
+		if json.Unmarshal(data, &buyerOrder) != nil {
+			return
+		}
+		encoded, err := json.Marshal(buyerOrder)
+		if err != nil {
+			t.Fatalf("%s decoded to a buyer order that can't be encoded: %v", data, err)
+		}
+		var again BuyerOrder
+		if err := json.Unmarshal(encoded, &again); err != nil || !cmp.Equal(again, buyerOrder, cmpopts.EquateEmpty()) {
+			t.Fatalf("%s decoded differently once encoded: %v %s", data, err, cmp.Diff(buyerOrder, again))
+		}
+	})
+}
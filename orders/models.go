@@ -1,54 +1,84 @@
 package orders
 
 import (
-	"time"
+	"distribution-bridge/address"
+	"distribution-bridge/lenient"
+	"distribution-bridge/money"
+	"encoding/json"
+	"fmt"
 )
 
 type Order struct {
-	ID               string `json:"_id"`
-	BuyerOrderCode   string `json:"buyerOrderCode"`
-	SellerOrderCode  string `json:"sellerOrderCode"`
-	BuyerCompanyID   string `json:"buyerCompanyId"`
-	SellerCompanyID  string `json:"sellerCompanyId"`
-	BuyerEmail       string `json:"buyerEmail"`
-	Currency         string `json:"currency"`
-	InvoiceID        string `json:"invoiceId"`
-	Note             string `json:"note"`
-	HasCancellations bool   `json:"hasCancellations"`
-	ShippingAddress  struct {
-		Name       string `json:"name"`
-		AddressOne string `json:"addressOne"`
-		AddressTwo string `json:"addressTwo"`
-		City       string `json:"city"`
-		State      string `json:"state"`
-		Country    string `json:"country"`
-		Zip        string `json:"zip"`
-		Company    string `json:"company"`
-	} `json:"shippingAddress"`
-	FillTime float64 `json:"fillTime"`
-	ShipTime float64 `json:"shipTime"`
-	Custom   []struct {
+	ID               string          `json:"_id"`
+	BuyerOrderCode   string          `json:"buyerOrderCode"`
+	SellerOrderCode  string          `json:"sellerOrderCode"`
+	BuyerCompanyID   string          `json:"buyerCompanyId"`
+	SellerCompanyID  string          `json:"sellerCompanyId"`
+	BuyerEmail       string          `json:"buyerEmail"`
+	Currency         string          `json:"currency"`
+	InvoiceID        string          `json:"invoiceId"`
+	Note             string          `json:"note"`
+	HasCancellations bool            `json:"hasCancellations"`
+	ShippingAddress  address.Address `json:"shippingAddress"`
+	BillingAddress   address.Address `json:"billingAddress"`
+	FillTime         lenient.Float   `json:"fillTime"`
+	ShipTime         lenient.Float   `json:"shipTime"`
+	Custom           []struct {
 		Key   string `json:"key"`
 		Type  string `json:"type"`
 		Value string `json:"value"`
 	} `json:"custom"`
 	Items []struct {
-		ID                string    `json:"_id"`
-		SellerVariantCode string    `json:"sellerVariantCode"`
-		Quantity          int       `json:"quantity"`
-		Cancelled         bool      `json:"cancelled"`
-		CancelledReason   string    `json:"cancelledReason"`
-		CancelledDate     time.Time `json:"cancelledDate"`
+		ID                string       `json:"_id"`
+		SellerVariantCode string       `json:"sellerVariantCode"`
+		Sku               string       `json:"sku"`
+		Quantity          lenient.Int  `json:"quantity"`
+		Price             money.Money  `json:"price"`
+		Cancelled         bool         `json:"cancelled"`
+		CancelledReason   string       `json:"cancelledReason"`
+		CancelledDate     lenient.Time `json:"cancelledDate"`
 	} `json:"items"`
-	Created     time.Time `json:"created"`
-	Updated     time.Time `json:"updated"`
-	Posted      bool      `json:"posted"`
-	PostedDate  time.Time `json:"postedDate"`
-	Shipped     bool      `json:"shipped"`
-	ShippedDate time.Time `json:"shippedDate"`
-	Billed      bool      `json:"billed"`
-	BilledDate  time.Time `json:"billedDate"`
+	Created      lenient.Time  `json:"created"`
+	Updated      lenient.Time  `json:"updated"`
+	Posted       bool          `json:"posted"`
+	PostedDate   lenient.Time  `json:"postedDate"`
+	Shipped      bool          `json:"shipped"`
+	ShippedDate  lenient.Time  `json:"shippedDate"`
+	Billed       bool          `json:"billed"`
+	BilledDate   lenient.Time  `json:"billedDate"`
 	Fulfillments []Fulfillment `json:"fulfillments"`
+
+	// Set when the order was listed but couldn't be decoded, only the ID is known
+	decodeErr error
+}
+
+// UnmarshalJSON gives the item and fulfillment item prices the order's currency
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type order Order
+	err := json.Unmarshal(data, (*order)(o))
+	if err != nil {
+		return err
+	}
+	for i := range o.Items {
+		o.Items[i].Price, err = o.Items[i].Price.WithCurrency(o.Currency)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range o.Fulfillments {
+		for j := range o.Fulfillments[i].Items {
+			item := &o.Fulfillments[i].Items[j]
+			item.Price, err = item.Price.WithCurrency(o.Currency)
+			if err != nil {
+				return err
+			}
+			item.RetailPrice, err = item.RetailPrice.WithCurrency(o.Currency)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 type Fulfillment struct {
@@ -59,7 +89,7 @@ type Fulfillment struct {
 	TrackingCode          string   `json:"trackingCode"`
 	TrackingUrls          []string `json:"trackingUrls"`
 	Items                 []struct {
-		Quantity          int           `json:"quantity"`
+		Quantity          lenient.Int   `json:"quantity"`
 		ID                string        `json:"_id"`
 		OrderItemID       string        `json:"orderItemId"`
 		BuyerItemCode     string        `json:"buyerItemCode"`
@@ -71,115 +101,133 @@ type Fulfillment struct {
 		Type              string        `json:"type"`
 		Title             string        `json:"title"`
 		Sku               string        `json:"sku"`
-		Price             int           `json:"price"`
-		RetailPrice       int           `json:"retailPrice"`
+		Price             money.Money   `json:"price"`
+		RetailPrice       money.Money   `json:"retailPrice"`
 		Barcode           string        `json:"barcode"`
 		BarcodeType       string        `json:"barcodeType"`
-		Weight            int           `json:"weight"`
+		Weight            lenient.Float `json:"weight"`
 		Custom            []interface{} `json:"custom"`
 	} `json:"items"`
 }
 
 type NewFulfillmentRequestBody struct {
-	Carrier string `json:"carrier"`
-	TrackingCode string `json:"trackingCode"`
-	TrackingURLs []string `json:"trackingUrls"`
-	Items []NewFulfillmentItem `json:"items"`
+	Carrier      string               `json:"carrier"`
+	TrackingCode string               `json:"trackingCode"`
+	TrackingURLs []string             `json:"trackingUrls"`
+	Items        []NewFulfillmentItem `json:"items"`
 }
 
 type NewFulfillmentItem struct {
-	ID int32 `json:"id"`
-	SKU string `json:"sku"`
-	Quantity int32 `json:"quantity"`
+	ID       int32  `json:"id"`
+	SKU      string `json:"sku"`
+	Quantity int32  `json:"quantity"`
 }
 
-
 type BuyerOrder struct {
-	ID             string    `json:"id"`
-	BuyerReference string    `json:"buyerReference"`
-	OrderedDate    time.Time `json:"orderedDate"`
-	Created        time.Time `json:"created"`
-	Updated        time.Time `json:"updated"`
-	Address        struct {
-		Name       string `json:"name"`
-		AddressOne string `json:"addressOne"`
-		AddressTwo string `json:"addressTwo"`
-		City       string `json:"city"`
-		State      string `json:"state"`
-		Country    string `json:"country"`
-		Zip        string `json:"zip"`
-		Company    string `json:"company"`
-	} `json:"address"`
-	Items []BuyerItem `json:"items"`
-	Note         string `json:"note"`
-	SellerOrders []struct {
-		ID              string    `json:"id"`
-		BuyerOrderID    string    `json:"buyerOrderId"`
-		BuyerReference  string    `json:"buyerReference"`
-		SellerReference string    `json:"sellerReference"`
-		CompanyID       string    `json:"companyId"`
-		BaseCurrency    string    `json:"baseCurrency"`
-		PackingSlipURL  string    `json:"packingSlipUrl"`
-		InvoiceID       string    `json:"invoiceId"`
-		Posted          bool      `json:"posted"`
-		PostedDate      string    `json:"postedDate"`
-		Fulfilled       bool      `json:"fulfilled"`
-		FulfilledDate   string    `json:"fulfilledDate"`
-		Invoiced        bool      `json:"invoiced"`
-		InvoicedDate    string    `json:"invoicedDate"`
-		Refunded        bool      `json:"refunded"`
-		RefundedDate    string    `json:"refundedDate"`
-		Created         time.Time `json:"created"`
-		Updated         time.Time `json:"updated"`
-		Address         struct {
-			Name       string `json:"name"`
-			AddressOne string `json:"addressOne"`
-			AddressTwo string `json:"addressTwo"`
-			City       string `json:"city"`
-			State      string `json:"state"`
-			Country    string `json:"country"`
-			Zip        string `json:"zip"`
-			Company    string `json:"company"`
-		} `json:"address"`
-		Items []struct {
-			ID        string  `json:"id"`
-			VariantID string  `json:"variantId"`
-			Quantity  int     `json:"quantity"`
-			BasePrice float64 `json:"basePrice"`
+	ID             string           `json:"id"`
+	BuyerReference string           `json:"buyerReference"`
+	OrderedDate    lenient.Time     `json:"orderedDate"`
+	Created        lenient.Time     `json:"created"`
+	Updated        lenient.Time     `json:"updated"`
+	Address        address.Address  `json:"address"`
+	BillingAddress *address.Address `json:"billingAddress,omitempty"`
+	Items          []BuyerItem      `json:"items"`
+	Note           string           `json:"note"`
+	SellerOrders   []struct {
+		ID              string          `json:"id"`
+		BuyerOrderID    string          `json:"buyerOrderId"`
+		BuyerReference  string          `json:"buyerReference"`
+		SellerReference string          `json:"sellerReference"`
+		CompanyID       string          `json:"companyId"`
+		BaseCurrency    string          `json:"baseCurrency"`
+		PackingSlipURL  string          `json:"packingSlipUrl"`
+		InvoiceID       string          `json:"invoiceId"`
+		Posted          bool            `json:"posted"`
+		PostedDate      lenient.Time    `json:"postedDate"`
+		Fulfilled       bool            `json:"fulfilled"`
+		FulfilledDate   lenient.Time    `json:"fulfilledDate"`
+		Invoiced        bool            `json:"invoiced"`
+		InvoicedDate    lenient.Time    `json:"invoicedDate"`
+		Refunded        bool            `json:"refunded"`
+		RefundedDate    lenient.Time    `json:"refundedDate"`
+		Created         lenient.Time    `json:"created"`
+		Updated         lenient.Time    `json:"updated"`
+		Address         address.Address `json:"address"`
+		Items           []struct {
+			ID        string      `json:"id"`
+			VariantID string      `json:"variantId"`
+			Quantity  lenient.Int `json:"quantity"`
+			BasePrice money.Money `json:"basePrice"`
 		} `json:"items"`
 		Fulfillments []struct {
-			ID           string    `json:"id"`
-			Posted       bool      `json:"posted"`
-			PostedDate   string    `json:"postedDate"`
-			Created      time.Time `json:"created"`
-			Updated      time.Time `json:"updated"`
-			Carrier      string    `json:"carrier"`
-			TrackingCode string    `json:"trackingCode"`
-			TrackingUrls []string  `json:"trackingUrls"`
+			ID           string       `json:"id"`
+			Posted       bool         `json:"posted"`
+			PostedDate   lenient.Time `json:"postedDate"`
+			Created      lenient.Time `json:"created"`
+			Updated      lenient.Time `json:"updated"`
+			Carrier      string       `json:"carrier"`
+			TrackingCode string       `json:"trackingCode"`
+			TrackingUrls []string     `json:"trackingUrls"`
 			Items        []struct {
-				ID          string `json:"id"`
-				OrderItemID string `json:"orderItemId"`
-				Quantity    int    `json:"quantity"`
+				ID          string      `json:"id"`
+				OrderItemID string      `json:"orderItemId"`
+				Quantity    lenient.Int `json:"quantity"`
 			} `json:"items"`
 		} `json:"fulfillments"`
 	} `json:"sellerOrders"`
 	Metafields *struct {
 		AdditionalProp struct {
 			AdditionalProp struct {
-				Value       string    `json:"value"`
-				Description string    `json:"description"`
-				Updated     time.Time `json:"updated"`
+				Value       string       `json:"value"`
+				Description string       `json:"description"`
+				Updated     lenient.Time `json:"updated"`
 			} `json:"additionalProp"`
 		} `json:"additionalProp"`
 	} `json:"metafields"`
 }
 
+// UnmarshalJSON gives the seller order item prices their seller order's currency
+func (o *BuyerOrder) UnmarshalJSON(data []byte) error {
+	type buyerOrder BuyerOrder
+	err := json.Unmarshal(data, (*buyerOrder)(o))
+	if err != nil {
+		return err
+	}
+	for i := range o.SellerOrders {
+		for j := range o.SellerOrders[i].Items {
+			item := &o.SellerOrders[i].Items[j]
+			item.BasePrice, err = item.BasePrice.WithCurrency(o.SellerOrders[i].BaseCurrency)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 type BuyerItem struct {
-	ID                string  `json:"id"`
-	VariantID         string  `json:"variantId"`
-	BuyerReference    string  `json:"buyerReference"`
-	SellerOrderID     string  `json:"sellerOrderId"`
-	SellerOrderItemID string  `json:"sellerOrderItemId"`
-	Quantity          int     `json:"quantity"`
-	RetailPrice       float64 `json:"retailPrice"`
-}
\ No newline at end of file
+	ID                string      `json:"id"`
+	VariantID         string      `json:"variantId"`
+	BuyerReference    string      `json:"buyerReference"`
+	SellerOrderID     string      `json:"sellerOrderId"`
+	SellerOrderItemID string      `json:"sellerOrderItemId"`
+	Quantity          lenient.Int `json:"quantity"`
+	RetailPrice       money.Money `json:"retailPrice"`
+	// The company ID of the supplier the line routes to, not sent
+	Supplier string `json:"-"`
+}
+
+// decodeOrder :: Decodes an order from a page of orders. An order that can't be decoded keeps its ID and the error, so
+// it fails on its own instead of failing the whole page.
+func decodeOrder(element json.RawMessage) Order {
+	var order Order
+	err := json.Unmarshal(element, &order)
+	if err == nil {
+		return order
+	}
+	var id struct {
+		ID string `json:"_id"`
+	}
+	json.Unmarshal(element, &id)
+	return Order{ID: id.ID, decodeErr: fmt.Errorf("failed to decode the order: %w", err)}
+}
@@ -0,0 +1,124 @@
+package orders
+
+import (
+	"distribution-bridge/lenient/lenienttest"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+const orderJSON = `{
+  "_id": "000000000000000000000006",
+  "sellerOrderCode": "order_abc123",
+  "buyerOrderCode": "order_abc123",
+  "currency": "CAD",
+  "shippingAddress": {"name": "Jane Doe", "addressOne": "123 Main St", "city": "Waterloo", "zip": "N2L 3G1"},
+  "fillTime": 1.5,
+  "shipTime": 26,
+  "custom": [{"key": "gift", "type": "bool", "value": "true"}],
+  "items": [{"_id": "000000000000000000000007", "sellerVariantCode": "JJ--1--1", "quantity": 2, "cancelledDate": "2021-04-02T08:00:00Z"}],
+  "created": "2021-04-01T12:30:00.123456789Z",
+  "updated": "2021-04-01T16:30:00-04:00",
+  "posted": true,
+  "postedDate": "2021-04-01T12:31:00Z",
+  "shipped": true,
+  "shippedDate": "2021-04-03T09:00:00Z",
+  "fulfillments": [{
+    "_id": "00000000000000000000000d",
+    "carrier": "UPS",
+    "trackingCode": "1Z999AA10123456784",
+    "trackingUrls": ["https://www.ups.com/track?tracknum=1Z999AA10123456784"],
+    "items": [{"_id": "000000000000000000000008", "sku": "JJ-1.1", "quantity": 2, "price": 21.5, "retailPrice": 42.99, "weight": 0.25}]
+  }]
+}`
+
+const buyerOrderJSON = `{
+  "id": "00000000000000000000000b",
+  "buyerReference": "order_def456",
+  "orderedDate": "2021-04-01T12:30:00Z",
+  "created": "2021-04-01T12:30:01Z",
+  "updated": "2021-04-01T12:30:01Z",
+  "address": {"name": "John Doe", "city": "Toronto", "zip": "M5V 2T6"},
+  "items": [{"id": "00000000000000000000000a", "variantId": "000000000000000000000003", "quantity": 1, "retailPrice": 42.99}],
+  "sellerOrders": [{
+    "id": "00000000000000000000000c",
+    "posted": true,
+    "postedDate": "2021-04-01T12:31:00Z",
+    "fulfilledDate": "2021-04-03T09:00:00Z",
+    "created": "2021-04-01T12:30:01Z",
+    "items": [{"id": "00000000000000000000000e", "quantity": 1, "basePrice": 21.5}],
+    "fulfillments": [{"id": "00000000000000000000000f", "postedDate": "2021-04-03T09:00:00Z", "items": [{"quantity": 1}]}]
+  }]
+}`
+
+// Numbers sent as strings and dates in other formats decode to the same order
+func TestOrderDecodesVariations(t *testing.T) {
+	var want Order
+	if err := json.Unmarshal([]byte(orderJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	property := func(seed int64) bool {
+		varied, err := lenienttest.Vary([]byte(orderJSON), rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := decodeOrder(varied)
+		if got.decodeErr != nil || !cmp.Equal(got, want, cmpopts.IgnoreUnexported(Order{})) {
+			t.Logf("%s\n%v %s", varied, got.decodeErr, cmp.Diff(want, got, cmpopts.IgnoreUnexported(Order{})))
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuyerOrderDecodesVariations(t *testing.T) {
+	var want BuyerOrder
+	if err := json.Unmarshal([]byte(buyerOrderJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	property := func(seed int64) bool {
+		varied, err := lenienttest.Vary([]byte(buyerOrderJSON), rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got BuyerOrder
+		err = json.Unmarshal(varied, &got)
+		if err != nil || !cmp.Equal(got, want) {
+			t.Logf("%s\n%v %s", varied, err, cmp.Diff(want, got))
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// Empty and null values decode to zero values instead of failing the order
+func TestOrderDecodesEmptyValues(t *testing.T) {
+	order := decodeOrder([]byte(`{
+		"_id": "1", "fillTime": "", "shipTime": null, "custom": null, "fulfillments": null,
+		"items": [{"quantity": "", "cancelledDate": ""}],
+		"created": "", "updated": null, "postedDate": "", "shippedDate": null, "billedDate": ""
+	}`))
+	if order.decodeErr != nil {
+		t.Fatal(order.decodeErr)
+	}
+	if order.ID != "1" || !order.Updated.IsZero() || order.Items[0].Quantity != 0 || order.FillTime != 0 {
+		t.Errorf("unexpected order: %+v", order)
+	}
+}
+
+// An order that can't be decoded keeps its ID, so it can fail on its own
+func TestDecodeOrderInvalid(t *testing.T) {
+	order := decodeOrder([]byte(`{"_id": "1", "items": [{"quantity": {"value": 2}}]}`))
+	if order.decodeErr == nil || order.ID != "1" {
+		t.Errorf("unexpected order: %+v", order)
+	}
+}
@@ -1,181 +1,540 @@
 package orders
 
 import (
+	"context"
+	"distribution-bridge/address"
+	"distribution-bridge/carriers"
+	"distribution-bridge/checkpoint"
 	"distribution-bridge/env"
+	"distribution-bridge/holds"
 	"distribution-bridge/http"
+	"distribution-bridge/lenient"
 	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/notify"
+	"distribution-bridge/pool"
 	"distribution-bridge/products"
+	"distribution-bridge/retries"
+	"distribution-bridge/status"
+	"distribution-bridge/stock"
+	"distribution-bridge/watermark"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
-// Sync new orders from buyer account to seller account. Sync order updates both ways.
-func SyncOrders() {
+// Sync new orders from buyer account to seller account of the bridge. Sync order updates both ways. Orders are
+// processed concurrently, and an order that fails doesn't stop the others unless more fail than the error budget allows.
+// In stock mode new orders reserve the distributor's stock instead, and stock is ordered from the suppliers as needed.
+func SyncOrders(ctx context.Context, bridge *env.Bridge) status.Summary {
+	run := status.Start(bridge, "orders")
+	var err error
+
 	// Get new orders from seller account (Retailer side)
-	//syncNewOrders()
+	if bridge.ForwardNewOrders() || bridge.StockModeEnabled() {
+		err = syncNewOrders(ctx, run)
+	}
 
 	// Get order updates from buyer account (Supplier side)
-	syncOrderUpdates()
+	if !errors.Is(err, status.ErrOverBudget) && ctx.Err() == nil {
+		updateErr := syncOrderUpdates(ctx, run)
+		if updateErr != nil {
+			err = updateErr
+		}
+	}
+
+	// Ship retailer orders from stock and replenish it
+	if bridge.StockModeEnabled() && !errors.Is(err, status.ErrOverBudget) && ctx.Err() == nil {
+		stockErr := syncStockShipments(ctx, run)
+		if stockErr == nil {
+			stockErr = replenish(run)
+		}
+		if stockErr != nil {
+			err = stockErr
+		}
+	}
+
+	// Alert on supplier orders past their ship-by deadline
+	if bridge.SLAEnabled() && !errors.Is(err, status.ErrOverBudget) && ctx.Err() == nil {
+		slaErr := checkLateOrders(ctx, run)
+		if slaErr != nil {
+			err = slaErr
+		}
+	}
+
+	return run.FinishWith(err)
 }
 
-func syncOrderUpdates() {
-	page := 0
-	allOrdersFound := false
-	ordersCount := 0
+// syncOrderUpdates :: Copies fulfillments from shipped buyer orders (Supplier side) to the seller orders. Returns an
+// error if the orders could not be listed, too many failed or the run was cancelled.
+func syncOrderUpdates(ctx context.Context, run *status.Run) error {
+	return sweepOrders(ctx, run, "order_updates", getBuyerShippedOrders, run.Bridge.GetBuyerAPIKey(), func(buyerOrder Order) error {
+		err := run.Outcome(buyerOrder.ID, syncOrderUpdate(run, buyerOrder))
+		retries.Track(run.Bridge, retries.KindOrderUpdate, buyerOrder.ID, err)
+		return err
+	})
+}
+
+// sweepOrders :: Pages through the orders updated since the job's watermark (or all of them when a full sweep is due),
+// listed with the API key, processing them concurrently with checkpoints after every page
+func sweepOrders(ctx context.Context, run *status.Run, job string, list func(page int, since time.Time, apiKey string) ([]Order, error), apiKey string, processOrder func(Order) error) error {
+	bridge := run.Bridge
+	sweep := checkpoint.Begin(bridge, job, watermark.Since(bridge, job, time.Now()))
+	since := sweep.Since()
+	newest := &watermark.Tracker{}
+	listing := &watermark.Listing{}
 
-	for !allOrdersFound {
-		// Retrieve orders from buyer account
-		buyerOrders, err := getBuyerShippedOrders(page)
+	ordersCount := 0
+	fetch := func(page int) ([]interface{}, error) {
+		orders, err := list(page, since, apiKey)
 		if err != nil {
-			logger.Error(fmt.Sprintf("failed to get orders on page :: %d", page), err)
-			return
+			run.Error(fmt.Sprintf("failed to get orders on page :: %d", page), err)
+			return nil, err
 		}
-
-		ordersCount = ordersCount + len(buyerOrders)
-		if len(buyerOrders) == 0 {
-			logger.Info(fmt.Sprintf("All orders have been found [%d]", ordersCount))
-			allOrdersFound = true
-			continue
+		ordersCount = ordersCount + len(orders)
+		if len(orders) == 0 {
+			logger.Info(fmt.Sprintf("All orders have been found for %s [%d]", bridge.Label(job), ordersCount))
 		}
-
-		for _, buyerOrder := range buyerOrders {
-			// Fetch the order
-			order, exists, err := getSellerOrderWithSellerOrderCode(buyerOrder.BuyerOrderCode)
-			if err != nil {
-				logger.Error("failed to get order with buyer order code", err)
+		updated := []time.Time{}
+		items := []interface{}{}
+		for i := range orders {
+			updated = append(updated, orders[i].Updated.Time)
+			// In case the API doesn't filter by updatedSince. Orders without a date are kept.
+			if !orders[i].Updated.IsZero() && orders[i].Updated.Before(since) {
 				continue
 			}
-
-			if !exists {
-				logger.Error("Order has not been synced to seller account", errors.New("error: order missing"))
-				continue
+			items = append(items, orders[i])
+		}
+		newestFirst := listing.NewestFirst(updated)
+		if len(orders) > 0 && len(items) == 0 {
+			// The pages after this one can only be older when the orders are listed newest first
+			if !newestFirst {
+				logger.Info(fmt.Sprintf("Orders aren't listed newest first for %s, checking the pages after %d [%d]", bridge.Label(job), page, ordersCount))
+				return nil, pool.ErrSkipPage
 			}
+			logger.Info(fmt.Sprintf("Reached orders older than the watermark for %s on page %d [%d]", bridge.Label(job), page, ordersCount))
+		}
+		return items, nil
+	}
+
+	process := func(item interface{}) error {
+		order := item.(Order)
+		err := processOrder(order)
+		if err != nil {
+			newest.Fail(order.Updated.Time)
+		} else {
+			newest.See(order.Updated.Time)
+		}
+		if err != nil && run.OverBudget() {
+			return status.ErrOverBudget
+		}
+		return nil
+	}
 
-			// Check if the order has been marked as shipped on the seller account (retail side)
-			//if hasBuyerOrderShipped(buyerOrder) && !order.Shipped {
-			if buyerOrder.Shipped && !order.Shipped {
-				logger.Info("Order has been shipped in buyer account, sharing it with the seller account")
+	err := pool.Run(ctx, pool.Options{
+		Concurrency: bridge.GetSyncConcurrency(),
+		StartPage:   sweep.StartPage(),
+		PageDone: func(page int, lastItem interface{}) {
+			sweep.PageDone(page, lastItem.(Order).ID)
+		},
+	}, fetch, process)
+	sweep.Finish(err)
+	if err == nil {
+		watermark.Advance(bridge, job, since, sweep.Started(), newest.Newest())
+	}
+	return err
+}
+
+// SyncOrderUpdate :: Syncs the fulfillments of a single order on the buyer account (Supplier side), ex. from a webhook
+func SyncOrderUpdate(run *status.Run, buyerOrderID string) error {
+	buyerOrder, err := getOrderWithID(buyerOrderID, run.Bridge.GetBuyerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to get buyer order [%s]", buyerOrderID), err)
+	} else {
+		err = syncOrderUpdate(run, buyerOrder)
+	}
+	err = run.Outcome(buyerOrderID, err)
+	retries.Track(run.Bridge, retries.KindOrderUpdate, buyerOrderID, err)
+	return err
+}
 
-				err := createFulfillmentOnSellerOrder(order.ID, buyerOrder.Fulfillments)
-				if err != nil {
-					logger.Error("failed to create fulfillment on the seller order", err)
-					continue
+// syncOrderUpdate :: Copies the fulfillments of a shipped buyer order (Supplier side) to the matching seller order
+func syncOrderUpdate(run *status.Run, buyerOrder Order) error {
+	if buyerOrder.decodeErr != nil {
+		run.Error(fmt.Sprintf("failed to decode buyer order [%s]", buyerOrder.ID), buyerOrder.decodeErr)
+		return buyerOrder.decodeErr
+	}
+	// Purchase orders replenish the distributor's stock, they have no retailer order
+	purchaseOrder, err := stock.GetPurchaseOrder(run.Bridge, buyerOrder.BuyerOrderCode)
+	if err == nil {
+		return receivePurchaseOrder(run, purchaseOrder, buyerOrder)
+	} else if !errors.Is(err, stock.ErrNotFound) {
+		run.Error(fmt.Sprintf("failed to get purchase order [%s]", buyerOrder.BuyerOrderCode), err)
+		return err
+	}
+
+	// Fetch the order
+	order, exists, err := getSellerOrderWithSellerOrderCode(buyerOrder.BuyerOrderCode, run.Bridge.GetSellerAPIKey())
+	if err != nil {
+		run.Error("failed to get order with buyer order code", err)
+		return err
+	}
+
+	if !exists {
+		err := &http.ClassError{Class: "order_missing", Err: errors.New("error: order missing")}
+		run.Error("Order has not been synced to seller account", err)
+		return err
+	}
+
+	// An order split between several suppliers is shipped once every supplier has shipped its part
+	split, isSplit, err := getSplit(run.Bridge, order.ID)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to get the split of order [%s]", order.ID), err)
+		return err
+	}
+	if isSplit {
+		return syncSplitOrderUpdate(run, order, split, buyerOrder)
+	}
+
+	// Check if the order has been marked as shipped on the seller account (retail side)
+	if buyerOrder.Shipped && !order.Shipped {
+		logger.Info("Order has been shipped in buyer account, sharing it with the seller account")
+
+		err := createFulfillmentOnSellerOrder(run, order.ID, buyerOrder.Fulfillments)
+		if err != nil {
+			run.Error("failed to create fulfillment on the seller order", err)
+			return err
+		}
+		logger.Info("Order has been marked as shipped in both accounts")
+		run.Count("shipped")
+		recordShipment(run, buyerOrder)
+	} else if !buyerOrder.Shipped && order.Shipped {
+		err := &http.ClassError{Class: "invalid_state", Err: errors.New("invalid state")}
+		run.Error("Order was marked as shipped in seller account but not buyer account", err)
+		return err
+	} else if order.Shipped {
+		return status.Skip("already shipped in both accounts")
+	} else {
+		return status.Skip("not shipped")
+	}
+	return nil
+}
+
+// syncSplitOrderUpdate :: Records the fulfillments of one supplier's part of a split order. Once every supplier has
+// shipped, the fulfillments of all of them are copied to the seller order together, so the retailer's order isn't
+// marked as shipped while parts of it are still waiting.
+func syncSplitOrderUpdate(run *status.Run, order Order, split Split, supplierOrder Order) error {
+	index := split.route(supplierOrder)
+	if index < 0 {
+		err := &http.ClassError{Class: "route_missing", Err: errors.New("error: route missing")}
+		run.Error(fmt.Sprintf("Order [%s] doesn't match any supplier order [%s] was routed to", supplierOrder.ID, order.ID), err)
+		return err
+	}
+	route := split.Routes[index]
+	if !route.Shipped {
+		if !supplierOrder.Shipped {
+			return status.Skip("not shipped")
+		}
+		var err error
+		split, err = markRouteShipped(run.Bridge, order.ID, index, supplierOrder)
+		if err != nil {
+			run.Error(fmt.Sprintf("failed to track the split of order [%s]", order.ID), err)
+			return err
+		}
+		recordShipment(run, supplierOrder)
+		if !split.Shipped() {
+			shipped := 0
+			for _, route := range split.Routes {
+				if route.Shipped {
+					shipped++
 				}
-				logger.Info("Order has been marked as shipped in both accounts")
-			} else if !buyerOrder.Shipped && order.Shipped {
-				logger.Error("Order was marked as shipped in seller account but not buyer account", errors.New("invalid state"))
-				continue
-			} // Else: Shipped in both, or not shipped
+			}
+			logger.Info(fmt.Sprintf("Supplier %s has shipped its part of order [%s], %d of %d suppliers have shipped", route.Supplier, order.ID, shipped, len(split.Routes)))
+			run.Count("partially_shipped")
+			return nil
 		}
+	} else if !split.Shipped() {
+		return status.Skip("already shipped, waiting for the other suppliers")
+	} else if split.SharedBy != supplierOrder.ID {
+		return status.Skip("already shipped, shared with the last supplier")
+	}
 
-		page++
+	// Every supplier has shipped. Fulfillments already copied are left out in case an earlier attempt failed part way.
+	fulfillments := newFulfillments(order, split.fulfillments())
+	logger.Info(fmt.Sprintf("Every supplier has shipped order [%s], sharing %d fulfillments with the seller account", order.ID, len(fulfillments)))
+	err := createFulfillmentOnSellerOrder(run, order.ID, fulfillments)
+	if err != nil {
+		run.Error("failed to create fulfillment on the seller order", err)
+		return err
 	}
+	err = finishSplit(run.Bridge, order.ID)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to track the split of order [%s]", order.ID), err)
+		return err
+	}
+	logger.Info("Order has been marked as shipped in both accounts")
+	run.Count("shipped")
+	return nil
 }
 
-// syncNewOrders :: Syncs any new orders from the seller account (retailer side) to the buyer account (supplier side)
-func syncNewOrders() {
-	page := 0
-	allOrdersFound := false
-	ordersCount := 0
+// newFulfillments :: Returns the fulfillments that aren't on the order yet, matched by tracking code or, without one,
+// by SKUs (copies have the carrier normalized)
+func newFulfillments(order Order, fulfillments []Fulfillment) []Fulfillment {
+	copied := map[string]bool{}
+	for _, fulfillment := range order.Fulfillments {
+		copied[fulfillmentKey(fulfillment)] = true
+	}
+	missing := []Fulfillment{}
+	for _, fulfillment := range fulfillments {
+		if !copied[fulfillmentKey(fulfillment)] {
+			missing = append(missing, fulfillment)
+		}
+	}
+	return missing
+}
+
+func fulfillmentKey(fulfillment Fulfillment) string {
+	if fulfillment.TrackingCode != "" {
+		// Copies have the tracking number normalized
+		return carriers.NormalizeTracking(fulfillment.TrackingCode)
+	}
+	skus := []string{}
+	for _, item := range fulfillment.Items {
+		skus = append(skus, fmt.Sprintf("%s:%d", item.Sku, item.Quantity))
+	}
+	return strings.Join(skus, ",")
+}
+
+// syncNewOrders :: Syncs any new orders from the seller account (retailer side) to the buyer account (supplier side).
+// Returns an error if the orders could not be listed, too many failed or the run was cancelled.
+func syncNewOrders(ctx context.Context, run *status.Run) error {
+	return sweepOrders(ctx, run, "new_orders", getSellerNonShippedOrders, run.Bridge.GetSellerAPIKey(), func(order Order) error {
+		err := run.Outcome(order.ID, syncNewOrder(run, order))
+		retries.Track(run.Bridge, retries.KindNewOrder, order.ID, err)
+		return err
+	})
+}
 
-	for !allOrdersFound {
-		orders, err := getSellerNonShippedOrders(page)
+// SyncNewOrder :: Forwards a single order from the seller account (retailer side) to the buyer account, ex. from a webhook
+func SyncNewOrder(run *status.Run, orderID string) error {
+	order, err := getOrderWithID(orderID, run.Bridge.GetSellerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to get seller order [%s]", orderID), err)
+	} else {
+		err = syncNewOrder(run, order)
+	}
+	err = run.Outcome(orderID, err)
+	retries.Track(run.Bridge, retries.KindNewOrder, orderID, err)
+	return err
+}
+
+// syncNewOrder :: Creates the order on the buyer account (supplier side) unless it already exists there
+func syncNewOrder(run *status.Run, order Order) error {
+	if order.decodeErr != nil {
+		run.Error(fmt.Sprintf("failed to decode seller order [%s]", order.ID), order.decodeErr)
+		return order.decodeErr
+	}
+	// Check if exist on buyer/supplier side using the seller order code against the buyer order code
+	_, exists, err := getBuyerOrderWithBuyerOrderCode(order.SellerOrderCode, run.Bridge.GetBuyerAPIKey())
+	if err != nil {
+		run.Error("failed to get order with buyer order code", err)
+		return err
+	}
+	logger.Info(fmt.Sprintf("order :: %+v", order))
+	if exists {
+		releaseOrder(run.Bridge, order)
+		return status.Skip("already forwarded")
+	}
+	if run.Bridge.StockModeEnabled() {
+		reserved, err := stock.Reserved(run.Bridge, order.ID)
 		if err != nil {
-			logger.Error(fmt.Sprintf("failed to get orders on page %d", page), err)
-			return
+			run.Error(fmt.Sprintf("failed to check the stock reserved for order [%s]", order.ID), err)
+			return err
 		}
-		ordersCount = ordersCount + len(orders)
-		if len(orders) == 0 {
-			logger.Info(fmt.Sprintf("All new orders have been found and synced [%d]", ordersCount))
-			allOrdersFound = true
-			continue
+		if reserved {
+			return status.Skip("already reserved")
 		}
+	}
 
-		for _, order := range orders {
-			// Check if exist on buyer/supplier side using the seller order code against the buyer order code
-			_, exists, err := getBuyerOrderWithBuyerOrderCode(order.SellerOrderCode)
-			if err != nil {
-				logger.Error("failed to get order with buyer order code", err)
-				continue
-			}
-			fmt.Printf("order :: %+v\n", order)
-
-			if !exists {
-				// Create new instance of the order on the buyer side
-				buyerOrder, err := ConvertToBuyerOrder(order)
-				if err != nil {
-					logger.Error(fmt.Sprintf("Failed to convert order to buyer order for %s (Seller Order ID)", order.ID), err)
-					continue
-				}
-				buyerOrderID, err := postNewBuyerOrderToAPI(buyerOrder)
-				if err != nil {
-					logger.Error(fmt.Sprintf("Failed to create new order for %s (Seller Order ID)", order.ID), err)
-					continue
-				}
-				logger.Info(fmt.Sprintf("New order created on the buyer account :: %s --> %s", order.ID, buyerOrderID))
-			}
+	// An order held earlier keeps its review: a rejected order is never forwarded, an approved one is forwarded
+	// whatever held it, and an edited address replaces the retailer's
+	held, err := holds.Get(run.Bridge, order.ID)
+	if err != nil && !errors.Is(err, holds.ErrNotFound) {
+		run.Error(fmt.Sprintf("failed to get held order [%s]", order.ID), err)
+		return err
+	}
+	if held.Status == holds.StatusRejected {
+		return status.Skip("rejected")
+	}
+	if held.Edited != nil {
+		order.ShippingAddress = *held.Edited
+	}
+	order, problems := normalizeAddresses(order)
+	reasons, err := holdReasons(run.Bridge, order, problems)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to check the hold rules for order [%s]", order.ID), err)
+		return err
+	}
+	if len(reasons) > 0 && held.Status != holds.StatusApproved {
+		return holdOrder(run, order, reasons)
+	}
+
+	// The distributor ships the order from stock
+	if run.Bridge.StockModeEnabled() {
+		return reserveOrder(run, order)
+	}
+
+	// Create new instance of the order on the buyer side
+	buyerOrder, err := ConvertToBuyerOrder(order, run.Bridge.GetBuyerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("Failed to convert order to buyer order for %s (Seller Order ID)", order.ID), err)
+		return err
+	}
+	created, err := postNewBuyerOrderToAPI(buyerOrder, run.Bridge.GetBuyerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("Failed to create new order for %s (Seller Order ID)", order.ID), err)
+		return err
+	}
+	logger.Info(fmt.Sprintf("New order created on the buyer account :: %s --> %s", order.ID, created.ID))
+	metrics.OrdersForwarded.Inc(run.Bridge.Name)
+	run.Count("forwarded")
+	releaseOrder(run.Bridge, order)
+	splitOrder(run, order, buyerOrder, created)
+	return nil
+}
+
+// splitOrder :: Tracks a forwarded order whose lines route to several suppliers, so it is only shipped once all of
+// them have shipped. The order is already forwarded, failures are only logged.
+func splitOrder(run *status.Run, order Order, sent BuyerOrder, created BuyerOrder) {
+	routes := splitRoutes(order, sent, created)
+	if len(routes) < 2 {
+		return
+	}
+	logger.Info(fmt.Sprintf("Order [%s] is split between %d suppliers :: %s", order.ID, len(routes), describeRoutes(routes)))
+	run.Count("split")
+	err := trackSplit(run.Bridge, Split{
+		OrderID:      order.ID,
+		OrderCode:    order.SellerOrderCode,
+		BuyerOrderID: created.ID,
+		Routes:       routes,
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to track the split of order [%s]", order.ID), err)
+	}
+}
+
+// releaseOrder :: Removes a forwarded order from the held orders and remembers its retailer. The order is already
+// forwarded, failures are only logged.
+func releaseOrder(bridge *env.Bridge, order Order) {
+	err := holds.Release(bridge, order.ID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to release held order [%s]", order.ID), err)
+	}
+	err = holds.AddRetailer(bridge, order.BuyerCompanyID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to remember retailer [%s]", order.BuyerCompanyID), err)
+	}
+}
+
+// normalizeAddresses :: Returns the order with its shipping and billing addresses cleaned up, and the problems that make
+// the shipping address look undeliverable. The billing address isn't sent to the supplier's carrier, its problems are
+// only logged.
+func normalizeAddresses(order Order) (Order, []string) {
+	shipping, problems := address.Normalize(order.ShippingAddress)
+	order.ShippingAddress = shipping
+	if !order.BillingAddress.IsZero() {
+		billing, billingProblems := address.Normalize(order.BillingAddress)
+		if len(billingProblems) > 0 {
+			logger.Info(fmt.Sprintf("Order [%s] billing address problems :: %s", order.ID, strings.Join(billingProblems, ", ")))
 		}
+		order.BillingAddress = billing
+	}
+	return order, problems
+}
 
-		page++
+// holdOrder :: Adds the order to the bridge's held orders instead of forwarding it
+func holdOrder(run *status.Run, order Order, reasons []string) error {
+	isNew, err := holds.Hold(run.Bridge, holds.Entry{
+		ID:        order.ID,
+		OrderCode: order.SellerOrderCode,
+		Reasons:   reasons,
+		Address:   order.ShippingAddress,
+	})
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to hold order [%s]", order.ID), err)
+		return err
+	}
+	run.Count("held")
+	if isNew {
+		notify.Send(run.Bridge, notify.Event{
+			Kind:    notify.EventOrderHeld,
+			Key:     order.ID,
+			Subject: fmt.Sprintf("Order %s was held for review", order.SellerOrderCode),
+			Details: map[string]string{"order": order.ID, "reasons": strings.Join(reasons, ", ")},
+		})
 	}
+	return status.Skip("held: " + strings.Join(reasons, ", "))
 }
 
-func createFulfillmentOnSellerOrder(orderID string, fulfillments []Fulfillment) error {
-	for index, fulfillment := range fulfillments {
+// createFulfillmentOnSellerOrder :: Copies the supplier's fulfillments to the seller order, with the carriers and
+// tracking numbers normalized. Nothing is copied if any of them is rejected.
+func createFulfillmentOnSellerOrder(run *status.Run, orderID string, fulfillments []Fulfillment) error {
+	bridge := run.Bridge
+	registry, err := carrierRegistry(bridge)
+	if err != nil {
+		return err
+	}
+	normalized := []Fulfillment{}
+	for _, fulfillment := range fulfillments {
+		fulfillment, err := normalizeFulfillment(run, orderID, registry, fulfillment)
+		if err != nil {
+			return err
+		}
+		normalized = append(normalized, fulfillment)
+	}
+
+	for index, fulfillment := range normalized {
 		newFulfillmentItems := []NewFulfillmentItem{}
 		for _, newFulfillmentItem := range fulfillment.Items {
 			newFulfillmentItems = append(newFulfillmentItems, NewFulfillmentItem{
-				ID: int32(index + 1),
-				SKU: newFulfillmentItem.Sku,
+				ID:       int32(index + 1),
+				SKU:      newFulfillmentItem.Sku,
 				Quantity: int32(newFulfillmentItem.Quantity),
 			})
 		}
 		jsonPayload, err := json.Marshal(NewFulfillmentRequestBody{
-			Carrier: fulfillment.Carrier,
+			Carrier:      fulfillment.Carrier,
 			TrackingCode: fulfillment.TrackingCode,
 			TrackingURLs: fulfillment.TrackingUrls,
-			Items: newFulfillmentItems,
+			Items:        newFulfillmentItems,
 		})
 		if err != nil {
 			return err
 		}
-		fmt.Printf("jsonPayload :: %+v\n", string(jsonPayload))
+		logger.Info(fmt.Sprintf("jsonPayload :: %s", string(jsonPayload)))
 
-		_, err = http.PostRequest(fmt.Sprintf("/orders/%s/fulfillments", orderID), env.GetSellerAPIKey(), jsonPayload)
+		_, err = http.PostRequest(fmt.Sprintf("/orders/%s/fulfillments", orderID), bridge.GetSellerAPIKey(), jsonPayload)
 		if err != nil {
 			return err
 		}
-		time.Sleep(time.Second * 1)
+		metrics.FulfillmentsCopied.Inc(bridge.Name)
 	}
 	return nil
 }
 
-// hasBuyerOrderShipped :: is a helper method for checking if all "seller orders" attached to a buyer order has shipped.
-func hasBuyerOrderShipped(buyerOrder BuyerOrder) bool {
-	if len(buyerOrder.SellerOrders) == 0 && len(buyerOrder.Items) > 0 {
-		// There is a least one item on the order that needs to be shipped
-		return false
-	}
-	for _, sellerOrder := range buyerOrder.SellerOrders {
-		if !sellerOrder.Fulfilled {
-			// Item(s) has not been fulfilled
-			return false
-		}
-	}
-	return true
-}
-
-// getBuyerShippedOrders :: Returns a list of buyer orders that have been shipped
-func getBuyerShippedOrders(page int) ([]Order, error) {
-	resp, err := http.GetRequest("/orders?shipped=true", page, env.GetBuyerAPIKey())
+// getBuyerShippedOrders :: Returns a list of buyer orders that have been shipped, updated since the given time (zero for all)
+func getBuyerShippedOrders(page int, since time.Time, apiKey string) ([]Order, error) {
+	resp, err := http.GetRequest(http.WithUpdatedSince("/orders?shipped=true", since), page, apiKey)
 	if err != nil {
 		return []Order{}, err
 	}
 
-	var response []Order
-	err = json.Unmarshal(resp, &response)
+	response := []Order{}
+	err = lenient.DecodeEach(resp, func(element json.RawMessage) {
+		response = append(response, decodeOrder(element))
+	})
 	if err != nil {
 		return []Order{}, err
 	}
@@ -183,8 +542,8 @@ func getBuyerShippedOrders(page int) ([]Order, error) {
 }
 
 // getSellerOrderWithSellerOrderCode :: Returns a seller order using the seller order code from the seller API
-func getSellerOrderWithSellerOrderCode(orderCode string) (Order, bool, error) {
-	resp, err := http.GetRequest(fmt.Sprintf("/orders?sellerOrderCode=%s", orderCode), 0, env.GetSellerAPIKey())
+func getSellerOrderWithSellerOrderCode(orderCode string, apiKey string) (Order, bool, error) {
+	resp, err := http.GetRequest(fmt.Sprintf("/orders?sellerOrderCode=%s", orderCode), 0, apiKey)
 	if err != nil {
 		return Order{}, false, err
 	}
@@ -202,15 +561,33 @@ func getSellerOrderWithSellerOrderCode(orderCode string) (Order, bool, error) {
 	return response[0], true, nil
 }
 
-// getSellerNonShippedOrders :: Returns a list of (seller) orders that have not shipped from the seller API
-func getSellerNonShippedOrders(page int) ([]Order, error) {
-	resp, err := http.GetRequest("/orders?shipped=false", page, env.GetSellerAPIKey())
+// getOrderWithID :: Returns a single order using its ID from the account the API key belongs to
+func getOrderWithID(orderID string, apiKey string) (Order, error) {
+	resp, err := http.GetSingleRequest(fmt.Sprintf("/orders/%s", orderID), apiKey)
 	if err != nil {
-		return []Order{}, err
+		return Order{}, err
 	}
 
-	var response []Order
+	var response Order
 	err = json.Unmarshal(resp, &response)
+	if err != nil {
+		return Order{}, err
+	}
+	return response, nil
+}
+
+// getSellerNonShippedOrders :: Returns a list of (seller) orders that have not shipped from the seller API, updated since
+// the given time (zero for all)
+func getSellerNonShippedOrders(page int, since time.Time, apiKey string) ([]Order, error) {
+	resp, err := http.GetRequest(http.WithUpdatedSince("/orders?shipped=false", since), page, apiKey)
+	if err != nil {
+		return []Order{}, err
+	}
+
+	response := []Order{}
+	err = lenient.DecodeEach(resp, func(element json.RawMessage) {
+		response = append(response, decodeOrder(element))
+	})
 	if err != nil {
 		return []Order{}, err
 	}
@@ -220,8 +597,8 @@ func getSellerNonShippedOrders(page int) ([]Order, error) {
 // getBuyerOrderWithBuyerOrderCode :: Returns a buyer order from the buyer account using the list all orders endpoint
 // and filter by the buyerOrderCode
 // TODO - Using a seller get orders endpoint (should be buyer but it does not exist)
-func getBuyerOrderWithBuyerOrderCode(buyerOrderCode string) (BuyerOrder, bool, error) {
-	resp, err := http.GetRequest(fmt.Sprintf("/orders?buyerOrderCode=%s", buyerOrderCode), 0, env.GetBuyerAPIKey())
+func getBuyerOrderWithBuyerOrderCode(buyerOrderCode string, apiKey string) (BuyerOrder, bool, error) {
+	resp, err := http.GetRequest(fmt.Sprintf("/orders?buyerOrderCode=%s", buyerOrderCode), 0, apiKey)
 	if err != nil {
 		return BuyerOrder{}, true, err
 	}
@@ -237,49 +614,56 @@ func getBuyerOrderWithBuyerOrderCode(buyerOrderCode string) (BuyerOrder, bool, e
 	return BuyerOrder{}, true, nil
 }
 
-// postNewBuyerOrderToAPI :: Submits a new order to the Buyer API for the buyer account
-func postNewBuyerOrderToAPI(buyerOrder BuyerOrder) (string, error) {
-	fmt.Printf("buyerOrder :: %+v\n", buyerOrder)
+// postNewBuyerOrderToAPI :: Submits a new order to the Buyer API for the buyer account. Returns the created buyer order
+// with the seller orders the platform split it into.
+func postNewBuyerOrderToAPI(buyerOrder BuyerOrder, apiKey string) (BuyerOrder, error) {
+	logger.Info(fmt.Sprintf("buyerOrder :: %+v", buyerOrder))
 	jsonPayload, err := json.Marshal(buyerOrder)
 	if err != nil {
-		return "", err
+		return BuyerOrder{}, err
 	}
 
-	resp, err := http.PostRequest("/buyer/orders", env.GetBuyerAPIKey(), jsonPayload)
+	resp, err := http.PostRequest("/buyer/orders", apiKey, jsonPayload)
 	if err != nil {
-		return "", err
+		return BuyerOrder{}, err
 	}
 
 	var response BuyerOrder
 	err = json.Unmarshal(resp, &response)
 	if err != nil {
-		return "", err
+		return BuyerOrder{}, err
 	}
-	return response.ID, nil
+	return response, nil
 }
 
-// ConvertToBuyerOrder :: Converts an order from the seller order model to the buyer order model
-func ConvertToBuyerOrder(o Order) (BuyerOrder, error) {
+// ConvertToBuyerOrder :: Converts an order from the seller order model to the buyer order model, looking up the variants
+// and the suppliers they route to with the buyer API key
+func ConvertToBuyerOrder(o Order, buyerAPIKey string) (BuyerOrder, error) {
 	buyerItems := []BuyerItem{}
 	for _, item := range o.Items {
 		// Look up the ID of the variant
-		idOfVariant, err := products.GetIDOfVariantBySellerVariantCode(env.GetBuyerAPIKey(), item.SellerVariantCode)
+		idOfVariant, supplier, err := products.GetVariantBySellerVariantCode(buyerAPIKey, item.SellerVariantCode)
 		if err != nil {
 			return BuyerOrder{}, err
 		}
 		buyerItems = append(buyerItems, BuyerItem{
-			VariantID: idOfVariant,
+			VariantID:      idOfVariant,
 			BuyerReference: item.ID,
-			Quantity: item.Quantity,
+			Quantity:       item.Quantity,
+			Supplier:       supplier,
 		})
-		time.Sleep(time.Second * 1)
 	}
-	return BuyerOrder{
+	buyerOrder := BuyerOrder{
 		BuyerReference: o.SellerOrderCode,
-		OrderedDate: o.Created,
-		Created: o.Created,
-		Updated: o.Updated,
-		Address: o.ShippingAddress, // Does not support both billing and shipping
-		Items: buyerItems,
-	}, nil
-}
\ No newline at end of file
+		OrderedDate:    o.Created,
+		Created:        o.Created,
+		Updated:        o.Updated,
+		Address:        o.ShippingAddress,
+		Items:          buyerItems,
+	}
+	if !o.BillingAddress.IsZero() {
+		billing := o.BillingAddress
+		buyerOrder.BillingAddress = &billing
+	}
+	return buyerOrder, nil
+}
@@ -0,0 +1,733 @@
+package orders
+
+import (
+	"context"
+	"distribution-bridge/env"
+	"distribution-bridge/fakeapi"
+	"distribution-bridge/holds"
+	"distribution-bridge/retries"
+	"distribution-bridge/status"
+	"errors"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const (
+	sellerKey = "seller-key"
+	buyerKey  = "buyer-key"
+)
+
+// newBridge returns a fake API with a seller and a buyer account, and a bridge between them with its own state. The
+// buyer account has a product with the variant code V-1. The settings are set for the bridge only.
+func newBridge(t *testing.T, settings ...string) (*fakeapi.Server, *env.Bridge) {
+	api := fakeapi.New()
+	api.AddAccount("seller", sellerKey)
+	api.AddAccount("buyer", buyerKey)
+	api.AddProduct(buyerKey, fakeapi.Document{
+		"code":     "P-1",
+		"title":    "Bracelet",
+		"variants": []interface{}{map[string]interface{}{"code": "V-1", "sku": "SKU-1"}},
+	})
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return api, loadBridge(t, srv.URL, settings...)
+}
+
+// loadBridge loads the config of a bridge with its own state that calls the API at the URL. The settings are set for
+// the bridge only, ex. "jobs.orders.forwardNew=true".
+func loadBridge(t *testing.T, apiURL string, settings ...string) *env.Bridge {
+	dir, err := ioutil.TempDir("", "bridge-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	overrides := []string{"api.url=" + apiURL, "api.rateLimit=0", "state.dir=" + dir}
+	for _, setting := range append([]string{"api.sellerKey=" + sellerKey, "api.buyerKey=" + buyerKey}, settings...) {
+		overrides = append(overrides, "bridges.test."+setting)
+	}
+	err = env.Load("", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge, err := env.GetBridge("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bridge
+}
+
+// runJob runs a sync job the way SyncOrders does and returns its summary
+func runJob(bridge *env.Bridge, job func(context.Context, *status.Run) error) status.Summary {
+	run := status.Start(bridge, "orders")
+	return run.FinishWith(job(context.Background(), run))
+}
+
+func sellerOrder(code string, variantCode string) fakeapi.Document {
+	return fakeapi.Document{
+		"sellerOrderCode": code,
+		"shippingAddress": map[string]interface{}{
+			"name": "Jane Doe", "addressOne": "123 Main St", "city": "Waterloo", "state": "ON", "country": "CA", "zip": "N2L 3G1",
+		},
+		"items": []interface{}{map[string]interface{}{"sellerVariantCode": variantCode, "quantity": 2}},
+	}
+}
+
+func shippedBuyerOrder(code string, trackingCode string) fakeapi.Document {
+	return fakeapi.Document{
+		"buyerOrderCode": code,
+		"shipped":        true,
+		"fulfillments": []interface{}{map[string]interface{}{
+			"carrier":      "UPS",
+			"trackingCode": trackingCode,
+			"trackingUrls": []interface{}{"https://example.com/track/" + trackingCode},
+			"items":        []interface{}{map[string]interface{}{"sku": "SKU-1", "quantity": 2}},
+		}},
+	}
+}
+
+func TestSyncNewOrdersForwardsOrders(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+
+	buyerOrders := api.BuyerOrders(buyerKey)
+	if len(buyerOrders) != 1 {
+		t.Fatalf("buyer account has %d buyer orders, expected 1", len(buyerOrders))
+	}
+	if buyerOrders[0]["buyerReference"] != "order_1" {
+		t.Errorf("buyerReference = %v, expected order_1", buyerOrders[0]["buyerReference"])
+	}
+	items := buyerOrders[0]["items"].([]interface{})
+	variantID := api.Products(buyerKey)[0]["variants"].([]interface{})[0].(map[string]interface{})["_id"]
+	if len(items) != 1 || items[0].(map[string]interface{})["variantId"] != variantID {
+		t.Errorf("the item should be the buyer account's variant %v: %v", variantID, items)
+	}
+	if buyerOrders[0]["address"].(map[string]interface{})["name"] != "Jane Doe" {
+		t.Errorf("the shipping address should be copied: %v", buyerOrders[0]["address"])
+	}
+	if len(api.Orders(sellerKey)) != 1 {
+		t.Errorf("the seller account should be unchanged")
+	}
+}
+
+func TestSyncNewOrdersNormalizesAddresses(t *testing.T) {
+	api, bridge := newBridge(t)
+	order := sellerOrder("order_1", "V-1")
+	order["shippingAddress"] = map[string]interface{}{
+		"name": "JANE  DOE", "addressOne": " 123 main st ", "city": "waterloo", "state": "Ontario", "country": "Canada",
+		"zip": "n2l3g1",
+	}
+	order["billingAddress"] = map[string]interface{}{
+		"name": "Jane Doe", "addressOne": "1 Market St", "city": "San Francisco", "state": "California",
+		"country": "United States", "zip": "94105",
+	}
+	api.AddOrder(sellerKey, order)
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	buyerOrder := api.BuyerOrders(buyerKey)[0]
+	want := map[string]interface{}{
+		"name": "Jane Doe", "addressOne": "123 Main St", "addressTwo": "", "city": "Waterloo", "state": "ON",
+		"country": "CA", "zip": "N2L 3G1", "company": "",
+	}
+	if diff := cmp.Diff(want, buyerOrder["address"]); diff != "" {
+		t.Errorf("unexpected shipping address: %s", diff)
+	}
+	billing := buyerOrder["billingAddress"].(map[string]interface{})
+	if billing["state"] != "CA" || billing["country"] != "US" || billing["city"] != "San Francisco" {
+		t.Errorf("unexpected billing address: %v", billing)
+	}
+}
+
+func TestSyncNewOrdersHoldsUndeliverableOrders(t *testing.T) {
+	api, bridge := newBridge(t)
+	order := sellerOrder("order_1", "V-1")
+	order["shippingAddress"] = map[string]interface{}{
+		"name": "Jane Doe", "addressOne": "123 Main St", "city": "Waterloo", "state": "ON", "country": "Canada",
+		"zip": "12345",
+	}
+	order = api.AddOrder(sellerKey, order)
+	api.AddOrder(sellerKey, sellerOrder("order_2", "V-1"))
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if len(api.BuyerOrders(buyerKey)) != 1 {
+		t.Fatalf("the held order should not be forwarded")
+	}
+	held, err := holds.List(bridge, false)
+	if err != nil || len(held) != 1 || held[0].ID != order["_id"] || held[0].OrderCode != "order_1" {
+		t.Fatalf("unexpected held orders: %+v (%v)", held, err)
+	}
+	if want := []string{`invalid postal code "12345" for CA`}; !cmp.Equal(held[0].Reasons, want) {
+		t.Errorf("reasons = %v, expected %v", held[0].Reasons, want)
+	}
+
+	// Once the retailer fixes the address the order is forwarded and no longer held
+	err = api.UpdateOrder(sellerKey, order["_id"].(string), fakeapi.Document{"shippingAddress": map[string]interface{}{
+		"name": "Jane Doe", "addressOne": "123 Main St", "city": "Waterloo", "state": "ON", "country": "Canada",
+		"zip": "N2L 3G1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary = runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if len(api.BuyerOrders(buyerKey)) != 2 {
+		t.Errorf("the fixed order should be forwarded")
+	}
+	if held, _ := holds.List(bridge, false); len(held) != 0 {
+		t.Errorf("the fixed order should be released: %+v", held)
+	}
+}
+
+func TestSyncNewOrdersForwardsUndeliverableOrdersWhenNotHolding(t *testing.T) {
+	api, bridge := newBridge(t, "policies.holdUndeliverableOrders=false")
+	order := sellerOrder("order_1", "V-1")
+	order["shippingAddress"] = map[string]interface{}{"name": "Jane Doe", "city": "Waterloo"}
+	api.AddOrder(sellerKey, order)
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if held, _ := holds.List(bridge, false); len(held) != 0 {
+		t.Errorf("no order should be held: %+v", held)
+	}
+}
+
+func TestSyncNewOrdersHoldRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []string
+		change   func(order fakeapi.Document)
+		reasons  []string
+	}{
+		{
+			name:     "order value",
+			settings: []string{"holds.orderValueOver=500"},
+			change: func(order fakeapi.Document) {
+				order["currency"] = "USD"
+				order["items"] = []interface{}{map[string]interface{}{"sellerVariantCode": "V-1", "quantity": 2, "price": 300}}
+			},
+			reasons: []string{"order value 600.00 USD is over 500"},
+		},
+		{
+			name:     "country",
+			settings: []string{"holds.countries=Canada,MX"},
+			reasons:  []string{"ships to CA"},
+		},
+		{
+			name:     "sku",
+			settings: []string{"holds.skus=v-1"},
+			reasons:  []string{"v-1 is flagged for review"},
+		},
+		{
+			name:     "currency mismatch",
+			settings: []string{"pricing.sellerCurrency=USD", "holds.currencyMismatch=true"},
+			change:   func(order fakeapi.Document) { order["currency"] = "EUR" },
+			reasons:  []string{"order is in EUR but the seller account sells in USD"},
+		},
+		{
+			name:     "new retailer",
+			settings: []string{"holds.newRetailers=true"},
+			change:   func(order fakeapi.Document) { order["buyerCompanyId"] = "retailer_1" },
+			reasons:  []string{"first order from retailer retailer_1"},
+		},
+		{
+			name:     "no match",
+			settings: []string{"holds.orderValueOver=500", "holds.countries=US", "holds.skus=V-2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api, bridge := newBridge(t, test.settings...)
+			order := sellerOrder("order_1", "V-1")
+			if test.change != nil {
+				test.change(order)
+			}
+			api.AddOrder(sellerKey, order)
+
+			summary := runJob(bridge, syncNewOrders)
+			if !summary.OK() {
+				t.Fatalf("unexpected summary: %s", summary)
+			}
+			held, err := holds.List(bridge, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(test.reasons) == 0 {
+				if len(held) != 0 || len(api.BuyerOrders(buyerKey)) != 1 {
+					t.Errorf("the order should be forwarded: %+v", held)
+				}
+				return
+			}
+			if len(held) != 1 || len(api.BuyerOrders(buyerKey)) != 0 {
+				t.Fatalf("the order should be held: %+v", held)
+			}
+			if !cmp.Equal(held[0].Reasons, test.reasons) {
+				t.Errorf("reasons = %v, expected %v", held[0].Reasons, test.reasons)
+			}
+		})
+	}
+}
+
+func TestSyncNewOrdersHoldsOnlyTheFirstOrderFromARetailer(t *testing.T) {
+	api, bridge := newBridge(t, "holds.newRetailers=true")
+	first := sellerOrder("order_1", "V-1")
+	first["buyerCompanyId"] = "retailer_1"
+	first = api.AddOrder(sellerKey, first)
+	runJob(bridge, syncNewOrders)
+	if _, err := holds.Approve(bridge, first["_id"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	runJob(bridge, syncNewOrders)
+
+	second := sellerOrder("order_2", "V-1")
+	second["buyerCompanyId"] = "retailer_1"
+	api.AddOrder(sellerKey, second)
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if len(api.BuyerOrders(buyerKey)) != 2 {
+		t.Errorf("both orders should be forwarded once the retailer is known")
+	}
+}
+
+func TestSyncNewOrdersReviewsHeldOrders(t *testing.T) {
+	api, bridge := newBridge(t, "holds.skus=V-1")
+	approved := api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	rejected := api.AddOrder(sellerKey, sellerOrder("order_2", "V-1"))
+	runJob(bridge, syncNewOrders)
+	if held, _ := holds.List(bridge, false); len(held) != 2 {
+		t.Fatalf("both orders should be held: %+v", held)
+	}
+
+	if _, err := holds.Approve(bridge, approved["_id"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := holds.Reject(bridge, rejected["_id"].(string), "fraud"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := holds.Approve(bridge, rejected["_id"].(string)); err != holds.ErrReviewed {
+		t.Errorf("approving a rejected order should fail, got %v", err)
+	}
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 || summary.Skipped != 1 || summary.Outcomes[0].Reason != "rejected" {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	buyerOrders := api.BuyerOrders(buyerKey)
+	if len(buyerOrders) != 1 || buyerOrders[0]["buyerReference"] != "order_1" {
+		t.Fatalf("only the approved order should be forwarded: %v", buyerOrders)
+	}
+	if held, _ := holds.List(bridge, false); len(held) != 0 {
+		t.Errorf("the forwarded order should be released: %+v", held)
+	}
+	if held, _ := holds.List(bridge, true); len(held) != 1 || held[0].Note != "fraud" {
+		t.Errorf("the rejected order should be kept: %+v", held)
+	}
+}
+
+func TestSyncNewOrdersForwardsEditedAddresses(t *testing.T) {
+	api, bridge := newBridge(t)
+	order := sellerOrder("order_1", "V-1")
+	order["shippingAddress"].(map[string]interface{})["zip"] = "12345"
+	order = api.AddOrder(sellerKey, order)
+	runJob(bridge, syncNewOrders)
+
+	if _, err := holds.Edit(bridge, order["_id"].(string), []byte(`{"street": "1 King St"}`)); !errors.Is(err, holds.ErrInvalidChanges) {
+		t.Errorf("unknown fields should be rejected, got %v", err)
+	}
+	if _, err := holds.Edit(bridge, order["_id"].(string), []byte(`{"zip": "n2l 3g1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	buyerOrders := api.BuyerOrders(buyerKey)
+	if len(buyerOrders) != 1 || buyerOrders[0]["address"].(map[string]interface{})["zip"] != "N2L 3G1" {
+		t.Errorf("the order should be forwarded with the edited address: %v", buyerOrders)
+	}
+}
+
+func TestSyncNewOrdersSkipsForwardedOrders(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	runJob(bridge, syncNewOrders)
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Skipped != 1 || summary.Outcomes[0].Reason != "already forwarded" {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if len(api.BuyerOrders(buyerKey)) != 1 {
+		t.Errorf("the order should only be forwarded once")
+	}
+}
+
+func TestSyncNewOrdersPagination(t *testing.T) {
+	api, bridge := newBridge(t)
+	for i := 0; i < 251; i++ {
+		api.AddOrder(sellerKey, sellerOrder(fmt.Sprintf("order_%03d", i), "V-1"))
+	}
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 251 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	forwarded := map[interface{}]int{}
+	for _, buyerOrder := range api.BuyerOrders(buyerKey) {
+		forwarded[buyerOrder["buyerReference"]]++
+	}
+	if len(forwarded) != 251 {
+		t.Errorf("%d orders were forwarded, expected 251", len(forwarded))
+	}
+	for reference, count := range forwarded {
+		if count != 1 {
+			t.Errorf("%v was forwarded %d times", reference, count)
+		}
+	}
+}
+
+func TestSyncNewOrdersUnknownVariant(t *testing.T) {
+	api, bridge := newBridge(t)
+	order := api.AddOrder(sellerKey, sellerOrder("order_1", "V-404"))
+
+	summary := runJob(bridge, syncNewOrders)
+	if summary.OK() || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if len(api.BuyerOrders(buyerKey)) != 0 {
+		t.Errorf("nothing should be forwarded")
+	}
+	if _, err := retries.Get(bridge, retries.EntryID(retries.KindNewOrder, order["_id"].(string))); err != nil {
+		t.Errorf("the order should be queued for a retry: %v", err)
+	}
+}
+
+func TestSyncNewOrdersCreateFails(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.FailNext("POST", "/buyer/orders", 500, 1)
+
+	summary := runJob(bridge, syncNewOrders)
+	if summary.OK() || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if len(api.BuyerOrders(buyerKey)) != 0 {
+		t.Errorf("nothing should be forwarded")
+	}
+
+	// The retry forwards it
+	summary = runJob(bridge, syncNewOrders)
+	if !summary.OK() || len(api.BuyerOrders(buyerKey)) != 1 {
+		t.Fatalf("the order should be forwarded on the next run: %s", summary)
+	}
+	if len(retries.List(bridge, false)) != 0 {
+		t.Errorf("the retry entry should be resolved")
+	}
+}
+
+func TestSyncNewOrdersOddPayloads(t *testing.T) {
+	api, bridge := newBridge(t)
+	// A quantity sent as a string and an empty date are fine
+	odd := sellerOrder("order_1", "V-1")
+	odd["items"] = []interface{}{map[string]interface{}{"sellerVariantCode": "V-1", "quantity": "2", "cancelledDate": ""}}
+	odd["postedDate"] = ""
+	api.AddOrder(sellerKey, odd)
+	// A quantity that isn't a number only fails its own order
+	broken := sellerOrder("order_2", "V-1")
+	broken["items"] = []interface{}{map[string]interface{}{"sellerVariantCode": "V-1", "quantity": map[string]interface{}{"value": 2}}}
+	broken = api.AddOrder(sellerKey, broken)
+
+	summary := runJob(bridge, syncNewOrders)
+	if summary.OK() || summary.Succeeded != 1 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	buyerOrders := api.BuyerOrders(buyerKey)
+	if len(buyerOrders) != 1 || buyerOrders[0]["buyerReference"] != "order_1" {
+		t.Fatalf("only order_1 should be forwarded: %v", buyerOrders)
+	}
+	if quantity := buyerOrders[0]["items"].([]interface{})[0].(map[string]interface{})["quantity"]; quantity != float64(2) {
+		t.Errorf("quantity = %v, expected 2", quantity)
+	}
+	if _, err := retries.Get(bridge, retries.EntryID(retries.KindNewOrder, broken["_id"].(string))); err != nil {
+		t.Errorf("the broken order should be queued for a retry: %v", err)
+	}
+}
+
+func TestSyncOrderUpdatesCopiesFulfillments(t *testing.T) {
+	api, bridge := newBridge(t)
+	order := api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(buyerKey, shippedBuyerOrder("order_1", "TRACK-1"))
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+
+	updated := api.Orders(sellerKey)[0]
+	if updated["_id"] != order["_id"] || updated["shipped"] != true {
+		t.Fatalf("the seller order should be shipped: %v", updated)
+	}
+	fulfillments := updated["fulfillments"].([]interface{})
+	if len(fulfillments) != 1 {
+		t.Fatalf("seller order has %d fulfillments, expected 1", len(fulfillments))
+	}
+	fulfillment := fulfillments[0].(map[string]interface{})
+	if fulfillment["carrier"] != "ups" || fulfillment["trackingCode"] != "TRACK-1" {
+		t.Errorf("unexpected fulfillment: %v", fulfillment)
+	}
+	items := fulfillment["items"].([]interface{})
+	if len(items) != 1 || items[0].(map[string]interface{})["sku"] != "SKU-1" {
+		t.Errorf("unexpected fulfillment items: %v", items)
+	}
+}
+
+func TestSyncOrderUpdatesNormalizesCarriers(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  []string
+		carrier   string
+		tracking  string
+		succeeded int
+		want      map[string]interface{}
+	}{
+		{
+			name: "known carrier", carrier: "UPS Ground", tracking: "1z999aa1 0123456784", succeeded: 1,
+			want: map[string]interface{}{"carrier": "ups", "trackingCode": "1Z999AA10123456784", "trackingUrls": []interface{}{"https://www.ups.com/track?tracknum=1Z999AA10123456784"}},
+		},
+		{
+			name: "bridge template", settings: []string{"carriers.trackingUrls=fedex=https://track.example.com/?n={tracking}"},
+			carrier: "Federal Express", tracking: "123456789012", succeeded: 1,
+			want: map[string]interface{}{"carrier": "fedex", "trackingCode": "123456789012", "trackingUrls": []interface{}{"https://track.example.com/?n=123456789012"}},
+		},
+		{
+			name: "unknown carrier", carrier: "Bob's Trucking", tracking: "B-1", succeeded: 1,
+			want: map[string]interface{}{"carrier": "Bob's Trucking", "trackingCode": "B-1"},
+		},
+		{
+			name: "invalid tracking copied", carrier: "UPS", tracking: "TRACK-1", succeeded: 1,
+			want: map[string]interface{}{"carrier": "ups", "trackingCode": "TRACK-1", "trackingUrls": []interface{}{"https://www.ups.com/track?tracknum=TRACK-1"}},
+		},
+		{
+			name: "invalid tracking rejected", settings: []string{"policies.rejectInvalidTracking=true"}, carrier: "UPS", tracking: "TRACK-1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api, bridge := newBridge(t, test.settings...)
+			api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+			api.AddOrder(buyerKey, fakeapi.Document{
+				"buyerOrderCode": "order_1",
+				"shipped":        true,
+				"fulfillments": []interface{}{map[string]interface{}{
+					"carrier":      test.carrier,
+					"trackingCode": test.tracking,
+					"items":        []interface{}{map[string]interface{}{"sku": "SKU-1", "quantity": 2}},
+				}},
+			})
+
+			summary := runJob(bridge, syncOrderUpdates)
+			if summary.Succeeded != test.succeeded {
+				t.Fatalf("unexpected summary: %s", summary)
+			}
+			fulfillments, _ := api.Orders(sellerKey)[0]["fulfillments"].([]interface{})
+			if test.want == nil {
+				if len(fulfillments) != 0 {
+					t.Errorf("nothing should be copied: %v", fulfillments)
+				}
+				return
+			}
+			if len(fulfillments) != 1 {
+				t.Fatalf("seller order has %d fulfillments, expected 1", len(fulfillments))
+			}
+			fulfillment := fulfillments[0].(map[string]interface{})
+			for key, value := range test.want {
+				if diff := cmp.Diff(value, fulfillment[key]); diff != "" {
+					t.Errorf("unexpected %s: %s", key, diff)
+				}
+			}
+			if _, ok := test.want["trackingUrls"]; !ok && fulfillment["trackingUrls"] != nil && len(fulfillment["trackingUrls"].([]interface{})) != 0 {
+				t.Errorf("no tracking URL should be made up: %v", fulfillment["trackingUrls"])
+			}
+		})
+	}
+}
+
+func TestSyncOrderUpdatesWaitsForEverySupplier(t *testing.T) {
+	api, bridge := newBridge(t)
+	if err := api.UpdateProduct(buyerKey, api.Products(buyerKey)[0]["_id"].(string), fakeapi.Document{"companyId": "supplier_a"}); err != nil {
+		t.Fatal(err)
+	}
+	api.AddProduct(buyerKey, fakeapi.Document{
+		"code":      "P-2",
+		"companyId": "supplier_b",
+		"variants":  []interface{}{map[string]interface{}{"code": "V-2", "sku": "SKU-2"}},
+	})
+	order := sellerOrder("order_1", "V-1")
+	order["items"] = append(order["items"].([]interface{}), map[string]interface{}{"sellerVariantCode": "V-2", "quantity": 1})
+	order = api.AddOrder(sellerKey, order)
+
+	summary := runJob(bridge, syncNewOrders)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	supplierOrders := api.Orders(buyerKey)
+	if len(supplierOrders) != 2 {
+		t.Fatalf("the order should be split between 2 suppliers: %v", supplierOrders)
+	}
+	split, ok, err := getSplit(bridge, order["_id"].(string))
+	if err != nil || !ok {
+		t.Fatalf("the split should be tracked (%v)", err)
+	}
+	want := []Route{
+		{Supplier: "supplier_a", SellerOrderID: supplierOrders[0]["_id"].(string), Items: []string{"V-1"}},
+		{Supplier: "supplier_b", SellerOrderID: supplierOrders[1]["_id"].(string), Items: []string{"V-2"}},
+	}
+	if diff := cmp.Diff(want, split.Routes); diff != "" {
+		t.Errorf("unexpected routes: %s", diff)
+	}
+
+	ship := func(supplierOrder fakeapi.Document, trackingCode string) {
+		shipped := shippedBuyerOrder("order_1", trackingCode)
+		if err := api.UpdateOrder(buyerKey, supplierOrder["_id"].(string), fakeapi.Document{"shipped": true, "fulfillments": shipped["fulfillments"]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// supplier_b ships first, the order waits for supplier_a
+	ship(supplierOrders[1], "TRACK-B")
+	summary = runJob(bridge, syncOrderUpdates)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if split, ok, _ := getSplit(bridge, order["_id"].(string)); !ok || split.Shipped() || !split.Routes[1].Shipped {
+		t.Errorf("only supplier_b should have shipped: %+v", split)
+	}
+	if retailerOrder := api.Orders(sellerKey)[0]; retailerOrder["shipped"] != false || retailerOrder["fulfillments"] != nil {
+		t.Errorf("the retailer order shouldn't be shipped before supplier_a ships: %v", retailerOrder)
+	}
+
+	ship(supplierOrders[0], "TRACK-A")
+	summary = runJob(bridge, syncOrderUpdates)
+	if !summary.OK() || summary.Succeeded != 1 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	fulfillments := api.Orders(sellerKey)[0]["fulfillments"].([]interface{})
+	if len(fulfillments) != 2 {
+		t.Fatalf("both suppliers' fulfillments should be copied: %v", fulfillments)
+	}
+	if _, ok, _ := getSplit(bridge, order["_id"].(string)); ok {
+		t.Errorf("the split should no longer be tracked once every supplier has shipped")
+	}
+}
+
+func TestSyncOrderUpdatesSkipsShippedOrders(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(buyerKey, shippedBuyerOrder("order_1", "TRACK-1"))
+	runJob(bridge, syncOrderUpdates)
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if !summary.OK() || summary.Skipped != 1 || summary.Outcomes[0].Reason != "already shipped in both accounts" {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if fulfillments := api.Orders(sellerKey)[0]["fulfillments"].([]interface{}); len(fulfillments) != 1 {
+		t.Errorf("the fulfillment should only be copied once, found %d", len(fulfillments))
+	}
+}
+
+func TestSyncOrderUpdatesIgnoresUnshippedOrders(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(buyerKey, fakeapi.Document{"buyerOrderCode": "order_1", "shipped": false})
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if !summary.OK() || summary.Succeeded+summary.Failed+summary.Skipped != 0 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if api.Orders(sellerKey)[0]["shipped"] != false {
+		t.Errorf("the seller order should not be shipped")
+	}
+}
+
+func TestSyncOrderUpdatesMissingSellerOrder(t *testing.T) {
+	api, bridge := newBridge(t)
+	buyerOrder := api.AddOrder(buyerKey, shippedBuyerOrder("order_1", "TRACK-1"))
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if summary.OK() || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	entry, err := retries.Get(bridge, retries.EntryID(retries.KindOrderUpdate, buyerOrder["_id"].(string)))
+	if err != nil {
+		t.Fatalf("the order should be queued for a retry: %v", err)
+	}
+	if entry.Attempts != 1 {
+		t.Errorf("attempts = %d, expected 1", entry.Attempts)
+	}
+}
+
+func TestSyncOrderUpdatesDuplicateSellerOrders(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(buyerKey, shippedBuyerOrder("order_1", "TRACK-1"))
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if summary.OK() || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	for _, order := range api.Orders(sellerKey) {
+		if order["shipped"] != false {
+			t.Errorf("neither duplicate should be shipped")
+		}
+	}
+}
+
+func TestSyncOrderUpdatesFulfillmentFails(t *testing.T) {
+	api, bridge := newBridge(t)
+	order := api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(buyerKey, shippedBuyerOrder("order_1", "TRACK-1"))
+	api.FailNext("POST", fmt.Sprintf("/orders/%s/fulfillments", order["_id"]), 502, 1)
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if summary.OK() || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if api.Orders(sellerKey)[0]["shipped"] != false {
+		t.Errorf("the seller order should not be shipped")
+	}
+}
+
+func TestSyncOrderUpdatesListingRateLimited(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.AddOrder(buyerKey, shippedBuyerOrder("order_1", "TRACK-1"))
+	api.FailNext("GET", "/orders", 429, 1)
+
+	summary := runJob(bridge, syncOrderUpdates)
+	if summary.OK() {
+		t.Fatalf("the run should fail when the orders can't be listed: %s", summary)
+	}
+	if api.Orders(sellerKey)[0]["shipped"] != false {
+		t.Errorf("the seller order should not be shipped")
+	}
+}
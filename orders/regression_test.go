@@ -0,0 +1,72 @@
+package orders
+
+import (
+	"context"
+	"distribution-bridge/http"
+	"encoding/json"
+	"testing"
+)
+
+// replay answers the API requests from a recorded fixture instead of the fake API
+func replay(t *testing.T, path string) *http.Replayer {
+	fixture, err := http.LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := http.NewReplayer(fixture, map[string]string{sellerKey: "seller", buyerKey: "buyer"})
+	http.SetTransport(replayer)
+	t.Cleanup(func() { http.SetTransport(nil) })
+	return replayer
+}
+
+// sentBodies decodes the bodies of the requests sent with the method and API key alias
+func sentBodies(t *testing.T, replayer *http.Replayer, method string, apiKey string) []map[string]interface{} {
+	t.Helper()
+	bodies := []map[string]interface{}{}
+	for _, request := range replayer.Requests() {
+		if request.Method != method || request.APIKey != apiKey {
+			continue
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(request.Body, &body); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, body)
+	}
+	return bodies
+}
+
+// A new order is forwarded while an already forwarded one is skipped, and a fulfillment with null tracking URLs is
+// copied to the seller order
+func TestRegressionForwardAndFulfill(t *testing.T) {
+	bridge := loadBridge(t, "http://api.invalid", "jobs.orders.forwardNew=true")
+	replayer := replay(t, "testdata/forward_and_fulfill.json")
+
+	summary := SyncOrders(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 2 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	for _, request := range replayer.Unmatched() {
+		t.Errorf("unexpected request: %s", request)
+	}
+	for _, interaction := range replayer.Unused() {
+		t.Errorf("request not sent: %s %s %s", interaction.Request.Method, interaction.Request.APIKey, interaction.Request.URL)
+	}
+
+	forwarded := sentBodies(t, replayer, "POST", "buyer")
+	if len(forwarded) != 1 || forwarded[0]["buyerReference"] != "order_def456" {
+		t.Fatalf("only order_def456 should be forwarded: %v", forwarded)
+	}
+	items := forwarded[0]["items"].([]interface{})
+	if len(items) != 1 || items[0].(map[string]interface{})["variantId"] != "000000000000000000000003" {
+		t.Errorf("the item should be the buyer account's variant JJ--1--2: %v", items)
+	}
+
+	fulfillments := sentBodies(t, replayer, "POST", "seller")
+	if len(fulfillments) != 1 {
+		t.Fatalf("%d fulfillments were sent, expected 1", len(fulfillments))
+	}
+	if fulfillments[0]["carrier"] != "ups" || fulfillments[0]["trackingCode"] != "1Z999AA10123456784" {
+		t.Errorf("unexpected fulfillment: %v", fulfillments[0])
+	}
+}
@@ -0,0 +1,85 @@
+package orders
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/holds"
+	"distribution-bridge/logger"
+	"distribution-bridge/money"
+	"fmt"
+	"strings"
+)
+
+// holdReasons :: Returns why the order should be held for review instead of being forwarded: the problems with its
+// shipping address, when undeliverable orders are held, and each of the bridge's hold rules it matches
+func holdReasons(bridge *env.Bridge, order Order, addressProblems []string) ([]string, error) {
+	reasons := []string{}
+	if bridge.HoldUndeliverableOrders() {
+		reasons = append(reasons, addressProblems...)
+	} else if len(addressProblems) > 0 {
+		logger.Info(fmt.Sprintf("Forwarding order [%s] with address problems :: %s", order.ID, strings.Join(addressProblems, ", ")))
+	}
+
+	if limit := bridge.GetHoldOrderValueOver(); limit > 0 {
+		value, err := orderValue(order)
+		if err != nil {
+			return nil, err
+		}
+		if value.Float() > limit {
+			reasons = append(reasons, fmt.Sprintf("order value %s is over %v", value, limit))
+		}
+	}
+
+	for _, country := range bridge.GetHoldCountries() {
+		if order.ShippingAddress.Country == country {
+			reasons = append(reasons, fmt.Sprintf("ships to %s", country))
+		}
+	}
+
+	flagged := bridge.GetHoldSKUs()
+	for _, item := range order.Items {
+		for _, sku := range flagged {
+			if strings.EqualFold(sku, item.Sku) || strings.EqualFold(sku, item.SellerVariantCode) {
+				reasons = append(reasons, fmt.Sprintf("%s is flagged for review", sku))
+				break
+			}
+		}
+	}
+
+	// Prices aren't forwarded, but an order in another currency than the seller account's is worth knowing about
+	if currency := bridge.GetSellerCurrency(); order.Currency != "" && currency != "" && !strings.EqualFold(order.Currency, currency) {
+		if bridge.HoldCurrencyMismatch() {
+			reasons = append(reasons, fmt.Sprintf("order is in %s but the seller account sells in %s", order.Currency, currency))
+		} else {
+			logger.Info(fmt.Sprintf("Order [%s] is in %s but the seller account sells in %s", order.ID, order.Currency, currency))
+		}
+	}
+
+	// Orders without a retailer can't be told apart, they aren't held
+	if bridge.HoldNewRetailers() && order.BuyerCompanyID != "" {
+		known, err := holds.KnownRetailer(bridge, order.BuyerCompanyID)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			reasons = append(reasons, fmt.Sprintf("first order from retailer %s", order.BuyerCompanyID))
+		}
+	}
+	return reasons, nil
+}
+
+// orderValue :: Returns the total price of the order's items that weren't cancelled, in the order's currency
+func orderValue(order Order) (money.Money, error) {
+	total := money.New(0, order.Currency)
+	for _, item := range order.Items {
+		if item.Cancelled {
+			continue
+		}
+		price := money.New(item.Price.Amount*int64(item.Quantity), item.Price.Currency)
+		var err error
+		total, err = total.Add(price)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,125 @@
+package orders
+
+import (
+	"context"
+	"distribution-bridge/notify"
+	"distribution-bridge/pool"
+	"distribution-bridge/sla"
+	"distribution-bridge/status"
+	"fmt"
+	"time"
+)
+
+// checkLateOrders :: Goes through every supplier order on the buyer account that hasn't shipped and alerts on the ones
+// past their ship-by deadline, once each. Returns an error if the orders could not be listed or the run was cancelled.
+func checkLateOrders(ctx context.Context, run *status.Run) error {
+	bridge := run.Bridge
+	now := time.Now()
+	open := map[string]bool{}
+	fetch := func(page int) ([]interface{}, error) {
+		// Deadlines pass without the orders changing, so every open order is checked, not only the updated ones
+		orders, err := getSellerNonShippedOrders(page, time.Time{}, bridge.GetBuyerAPIKey())
+		if err != nil {
+			run.Error(fmt.Sprintf("failed to get open supplier orders on page :: %d", page), err)
+			return nil, err
+		}
+		items := []interface{}{}
+		for _, order := range orders {
+			items = append(items, order)
+		}
+		return items, nil
+	}
+	process := func(item interface{}) error {
+		order := item.(Order)
+		open[order.ID] = true
+		return checkLateOrder(run, order, now)
+	}
+
+	// A single worker, checking an order doesn't call the API
+	err := pool.Run(ctx, pool.Options{Concurrency: 1}, fetch, process)
+	if err != nil {
+		return err
+	}
+	err = sla.Prune(bridge, open)
+	if err != nil {
+		run.Error("failed to prune the late orders", err)
+		return err
+	}
+	return nil
+}
+
+// checkLateOrder :: Records a supplier order past its ship-by deadline and sends an SLA breach alert the first time
+func checkLateOrder(run *status.Run, order Order, now time.Time) error {
+	if order.decodeErr != nil || order.Shipped || cancelled(order) {
+		return nil
+	}
+	deadline, ok := sla.Deadline(run.Bridge, order.SellerCompanyID, order.Created.Time, float64(order.ShipTime))
+	if !ok || !now.After(deadline) {
+		return nil
+	}
+	late := sla.LateOrder{
+		OrderID:   order.ID,
+		OrderCode: order.BuyerOrderCode,
+		Supplier:  order.SellerCompanyID,
+		Created:   order.Created.Time,
+		Deadline:  deadline,
+	}
+	isNew, err := sla.MarkLate(run.Bridge, late)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to record late order [%s]", order.ID), err)
+		return err
+	}
+	if !isNew {
+		return nil
+	}
+	run.Count("late")
+	notify.Send(run.Bridge, notify.Event{
+		Kind:    notify.EventSLABreach,
+		Key:     order.ID,
+		Subject: fmt.Sprintf("Order %s from supplier %s is past its ship-by deadline", order.BuyerOrderCode, order.SellerCompanyID),
+		Details: map[string]string{
+			"order":    order.ID,
+			"supplier": order.SellerCompanyID,
+			"created":  order.Created.Time.UTC().Format(time.RFC3339),
+			"deadline": deadline.UTC().Format(time.RFC3339),
+			"late":     now.Sub(deadline).Round(time.Minute).String(),
+		},
+	})
+	return nil
+}
+
+// recordShipment :: Adds a shipped supplier order to the supplier's on-time record when SLAs are tracked
+func recordShipment(run *status.Run, supplierOrder Order) {
+	if !run.Bridge.SLAEnabled() {
+		return
+	}
+	result, err := sla.RecordShipment(run.Bridge, sla.Shipment{
+		OrderID:       supplierOrder.ID,
+		Supplier:      supplierOrder.SellerCompanyID,
+		Created:       supplierOrder.Created.Time,
+		Shipped:       supplierOrder.ShippedDate.Time,
+		FillHours:     float64(supplierOrder.FillTime),
+		PromisedHours: float64(supplierOrder.ShipTime),
+	})
+	if err != nil {
+		// The order has shipped either way, only the record is off
+		run.Error(fmt.Sprintf("failed to record the shipment of order [%s]", supplierOrder.ID), err)
+		return
+	}
+	if result == sla.ResultLate {
+		run.Count("shipped_late")
+	}
+}
+
+// cancelled :: Returns true when every line of the order was cancelled
+func cancelled(order Order) bool {
+	if len(order.Items) == 0 {
+		return false
+	}
+	for _, item := range order.Items {
+		if !item.Cancelled {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package orders
+
+import (
+	"context"
+	"distribution-bridge/env/envtest"
+	"distribution-bridge/fakeapi"
+	"distribution-bridge/notify"
+	"distribution-bridge/sla"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readOutbox returns the events in a notification outbox
+func readOutbox(t *testing.T, path string) []notify.Event {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []notify.Event{}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []notify.Event{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event notify.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func supplierOrder(code string, supplier string, age time.Duration) fakeapi.Document {
+	return fakeapi.Document{
+		"buyerOrderCode":  code,
+		"sellerCompanyId": supplier,
+		"created":         time.Now().Add(-age).UTC().Format(time.RFC3339),
+		"items":           []interface{}{map[string]interface{}{"sellerVariantCode": "V-1", "sku": "SKU-1", "quantity": 2}},
+	}
+}
+
+func TestSyncOrdersAlertsOnLateOrders(t *testing.T) {
+	outbox := filepath.Join(envtest.TempDir(t), "outbox.jsonl")
+	api, bridge := newBridge(t, "sla.enabled=true", "sla.shipWithin=48h", "sla.suppliers=supplier_b=96h", "notify.file="+outbox)
+
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	late := api.AddOrder(buyerKey, supplierOrder("order_1", "supplier_a", 72*time.Hour))
+	api.AddOrder(buyerKey, supplierOrder("order_2", "supplier_a", time.Hour))
+	// The supplier's own SLA and ship time on the platform come before the default
+	api.AddOrder(buyerKey, supplierOrder("order_3", "supplier_b", 72*time.Hour))
+	promised := supplierOrder("order_4", "supplier_c", 72*time.Hour)
+	promised["shipTime"] = 100
+	api.AddOrder(buyerKey, promised)
+
+	for i := 0; i < 2; i++ {
+		summary := SyncOrders(context.Background(), bridge)
+		if !summary.OK() {
+			t.Fatalf("unexpected summary: %s", summary)
+		}
+	}
+	if alerts := readOutbox(t, outbox); len(alerts) != 1 || alerts[0].Kind != notify.EventSLABreach || alerts[0].Key != late["_id"] {
+		t.Fatalf("only order_1 should be alerted on, once: %+v", alerts)
+	}
+	report, _ := sla.GetReport(bridge)
+	if len(report.Late) != 1 || report.Late[0].Supplier != "supplier_a" {
+		t.Fatalf("unexpected late orders: %+v", report.Late)
+	}
+
+	// Shipping it late counts against the supplier
+	shipped := shippedBuyerOrder("order_1", "1Z999AA10123456784")
+	if err := api.UpdateOrder(buyerKey, late["_id"].(string), shipped); err != nil {
+		t.Fatal(err)
+	}
+	summary := SyncOrders(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	report, _ = sla.GetReport(bridge)
+	if len(report.Late) != 0 {
+		t.Errorf("the shipped order shouldn't be late anymore: %+v", report.Late)
+	}
+	if len(report.Suppliers) != 1 || report.Suppliers[0].Late != 1 || report.Suppliers[0].OnTime != 0 {
+		t.Errorf("unexpected supplier records: %+v", report.Suppliers)
+	}
+}
@@ -0,0 +1,219 @@
+package orders
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/metrics"
+	"distribution-bridge/store"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const splitsFile = "splits.json"
+
+// Split is a forwarded retailer order the platform split into a seller order per supplier. It is tracked until every
+// supplier has shipped and their fulfillments are on the seller order.
+type Split struct {
+	OrderID      string    `json:"orderId"` // Seller account order ID
+	OrderCode    string    `json:"orderCode"`
+	BuyerOrderID string    `json:"buyerOrderId"`
+	Routes       []Route   `json:"routes"`
+	Created      time.Time `json:"created"`
+	// SharedBy is the supplier order that shipped last, its sync shares the fulfillments of every supplier
+	SharedBy string `json:"sharedBy,omitempty"`
+}
+
+// Route is the part of a split order that goes to a single supplier
+type Route struct {
+	Supplier      string    `json:"supplier"`                // Supplier company ID
+	SellerOrderID string    `json:"sellerOrderId,omitempty"` // The supplier's order, when the platform returned it
+	Items         []string  `json:"items"`                   // Seller variant codes of the lines
+	Shipped       bool      `json:"shipped"`
+	ShippedDate   time.Time `json:"shippedDate,omitempty"`
+	// Fulfillments of the supplier, held until every supplier has shipped so the seller order isn't shipped early
+	Fulfillments []Fulfillment `json:"fulfillments,omitempty"`
+}
+
+// Shipped :: Returns true when every supplier the order was routed to has shipped
+func (s Split) Shipped() bool {
+	if len(s.Routes) == 0 {
+		return false
+	}
+	for _, route := range s.Routes {
+		if !route.Shipped {
+			return false
+		}
+	}
+	return true
+}
+
+// fulfillments :: Returns the fulfillments of every supplier, in the order of the routes
+func (s Split) fulfillments() []Fulfillment {
+	fulfillments := []Fulfillment{}
+	for _, route := range s.Routes {
+		fulfillments = append(fulfillments, route.Fulfillments...)
+	}
+	return fulfillments
+}
+
+// route :: Returns the index of the route a supplier's order is for, matched by its ID, then by supplier, then by its
+// lines. Returns -1 when it doesn't match any.
+func (s Split) route(supplierOrder Order) int {
+	for i, route := range s.Routes {
+		if route.SellerOrderID != "" && route.SellerOrderID == supplierOrder.ID {
+			return i
+		}
+	}
+	for i, route := range s.Routes {
+		if route.Supplier != "" && route.Supplier == supplierOrder.SellerCompanyID {
+			return i
+		}
+	}
+	for i, route := range s.Routes {
+		for _, item := range supplierOrder.Items {
+			for _, code := range route.Items {
+				if code == item.SellerVariantCode {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// splitRoutes :: Returns the suppliers the order's lines route to: the seller orders the platform created, or when the
+// response doesn't list them, the suppliers of the lines' variants
+func splitRoutes(order Order, sent BuyerOrder, created BuyerOrder) []Route {
+	codes := map[string]string{} // Retailer order item ID -> seller variant code
+	for _, item := range order.Items {
+		codes[item.ID] = item.SellerVariantCode
+	}
+	variantCodes := map[string]string{} // Buyer account variant ID -> seller variant code
+	for _, item := range sent.Items {
+		variantCodes[item.VariantID] = codes[item.BuyerReference]
+	}
+
+	routes := []Route{}
+	if len(created.SellerOrders) > 0 {
+		for _, sellerOrder := range created.SellerOrders {
+			route := Route{Supplier: sellerOrder.CompanyID, SellerOrderID: sellerOrder.ID, Items: []string{}}
+			for _, item := range sellerOrder.Items {
+				route.Items = append(route.Items, variantCodes[item.VariantID])
+			}
+			routes = append(routes, route)
+		}
+		return routes
+	}
+
+	bySupplier := map[string]int{}
+	for _, item := range sent.Items {
+		index, ok := bySupplier[item.Supplier]
+		if !ok {
+			index = len(routes)
+			bySupplier[item.Supplier] = index
+			routes = append(routes, Route{Supplier: item.Supplier, Items: []string{}})
+		}
+		routes[index].Items = append(routes[index].Items, codes[item.BuyerReference])
+	}
+	return routes
+}
+
+// describeRoutes :: Returns the suppliers and their lines for the logs, ex. "supplier_a [V-1, V-2], supplier_b [V-3]"
+func describeRoutes(routes []Route) string {
+	described := []string{}
+	for _, route := range routes {
+		described = append(described, fmt.Sprintf("%s [%s]", route.Supplier, strings.Join(route.Items, ", ")))
+	}
+	return strings.Join(described, ", ")
+}
+
+// trackSplit :: Keeps the split order until every supplier has shipped
+func trackSplit(bridge *env.Bridge, split Split) error {
+	return updateSplits(bridge, func(tracked map[string]*Split) (bool, error) {
+		split.Created = time.Now()
+		tracked[split.OrderID] = &split
+		return true, nil
+	})
+}
+
+// getSplit :: Returns the split of a seller account order, false when it isn't split or its fulfillments have been
+// shared with the seller account
+func getSplit(bridge *env.Bridge, orderID string) (Split, bool, error) {
+	list := []*Split{}
+	err := store.Read(bridge.GetStateDir(), splitsFile, &list)
+	if err != nil {
+		return Split{}, false, fmt.Errorf("failed to load the split orders of %s: %w", bridge.Name, err)
+	}
+	metrics.SplitOrders.Set(float64(len(list)), bridge.Name)
+	for _, split := range list {
+		if split.OrderID == orderID {
+			return *split, true, nil
+		}
+	}
+	return Split{}, false, nil
+}
+
+// markRouteShipped :: Records that a supplier has shipped its part of the order, with its fulfillments. The supplier
+// order that ships last shares the fulfillments.
+func markRouteShipped(bridge *env.Bridge, orderID string, index int, supplierOrder Order) (Split, error) {
+	var shipped Split
+	err := updateSplits(bridge, func(tracked map[string]*Split) (bool, error) {
+		split, ok := tracked[orderID]
+		if !ok || index < 0 || index >= len(split.Routes) {
+			return false, fmt.Errorf("error: order [%s] has no route %d", orderID, index)
+		}
+		split.Routes[index].Shipped = true
+		split.Routes[index].ShippedDate = time.Now()
+		split.Routes[index].Fulfillments = supplierOrder.Fulfillments
+		if split.Shipped() {
+			split.SharedBy = supplierOrder.ID
+		}
+		shipped = *split
+		return true, nil
+	})
+	return shipped, err
+}
+
+// finishSplit :: Stops tracking a split order once its fulfillments are on the seller order
+func finishSplit(bridge *env.Bridge, orderID string) error {
+	return updateSplits(bridge, func(tracked map[string]*Split) (bool, error) {
+		if _, ok := tracked[orderID]; !ok {
+			return false, nil
+		}
+		delete(tracked, orderID)
+		return true, nil
+	})
+}
+
+// updateSplits :: Reads the bridge's split orders, applies the change and saves them when the change returns true,
+// holding their lock throughout so a change made by another process is never overwritten
+func updateSplits(bridge *env.Bridge, change func(tracked map[string]*Split) (bool, error)) error {
+	list := []*Split{}
+	var changeErr error
+	err := store.Update(bridge.GetStateDir(), splitsFile, &list, func() (bool, error) {
+		tracked := map[string]*Split{}
+		for _, split := range list {
+			tracked[split.OrderID] = split
+		}
+		changed, err := change(tracked)
+		if err != nil {
+			changeErr = err
+			return false, err
+		}
+		list = []*Split{}
+		for _, split := range tracked {
+			list = append(list, split)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].OrderID < list[j].OrderID })
+		return changed, nil
+	})
+	if changeErr != nil {
+		return changeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save the split orders of %s: %w", bridge.Name, err)
+	}
+	metrics.SplitOrders.Set(float64(len(list)), bridge.Name)
+	return nil
+}
@@ -0,0 +1,176 @@
+package orders
+
+import (
+	"context"
+	"distribution-bridge/address"
+	"distribution-bridge/http"
+	"distribution-bridge/lenient"
+	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/products"
+	"distribution-bridge/status"
+	"distribution-bridge/stock"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reserveOrder :: Reserves the distributor's stock for a retailer order instead of forwarding it to the suppliers.
+// Lines that aren't in stock are backordered until a purchase order is received.
+func reserveOrder(run *status.Run, order Order) error {
+	lines := map[string]int{}
+	for _, item := range order.Items {
+		if item.Cancelled {
+			continue
+		}
+		lines[item.SellerVariantCode] += int(item.Quantity)
+	}
+	if len(lines) == 0 {
+		return status.Skip("nothing to reserve")
+	}
+	short, err := stock.Reserve(run.Bridge, order.ID, order.SellerOrderCode, lines)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to reserve stock for order [%s]", order.ID), err)
+		return err
+	}
+	if len(short) > 0 {
+		logger.Info(fmt.Sprintf("Order [%s] is backordered :: %s", order.ID, strings.Join(short, ", ")))
+		run.Count("backordered")
+	}
+	run.Count("reserved")
+	releaseOrder(run.Bridge, order)
+	return nil
+}
+
+// syncStockShipments :: Takes the stock reserved for retailer orders (seller account) off hand once they have shipped.
+// Returns an error if the orders could not be listed, too many failed or the run was cancelled.
+func syncStockShipments(ctx context.Context, run *status.Run) error {
+	// The shipped orders are listed the same way in either account
+	return sweepOrders(ctx, run, "stock_shipments", getBuyerShippedOrders, run.Bridge.GetSellerAPIKey(), func(order Order) error {
+		return run.Outcome(order.ID, shipFromStock(run, order))
+	})
+}
+
+// shipFromStock :: Takes the stock reserved for a shipped retailer order off hand
+func shipFromStock(run *status.Run, order Order) error {
+	if order.decodeErr != nil {
+		run.Error(fmt.Sprintf("failed to decode seller order [%s]", order.ID), order.decodeErr)
+		return order.decodeErr
+	}
+	_, err := stock.Ship(run.Bridge, order.ID)
+	if errors.Is(err, stock.ErrNotFound) {
+		return status.Skip("not reserved")
+	}
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to ship order [%s] from stock", order.ID), err)
+		return err
+	}
+	run.Count("shipped_from_stock")
+	return nil
+}
+
+// receivePurchaseOrder :: Adds what a supplier shipped for one of the distributor's purchase orders to the stock on
+// hand
+func receivePurchaseOrder(run *status.Run, purchaseOrder stock.PurchaseOrder, supplierOrder Order) error {
+	if !supplierOrder.Shipped {
+		return status.Skip("not shipped")
+	}
+	lines := map[string]int{}
+	for _, item := range supplierOrder.Items {
+		if item.Cancelled {
+			continue
+		}
+		lines[item.SellerVariantCode] += int(item.Quantity)
+	}
+	_, received, err := stock.Receive(run.Bridge, purchaseOrder.Code, supplierOrder.ID, lines)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to receive purchase order [%s]", purchaseOrder.Code), err)
+		return err
+	}
+	if !received {
+		return status.Skip("already received")
+	}
+	run.Count("received")
+	recordShipment(run, supplierOrder)
+	return nil
+}
+
+// replenish :: Places a purchase order with the suppliers for every variant at or below its reorder point. The
+// platform splits it between the suppliers, like a retailer order.
+func replenish(run *status.Run) error {
+	bridge := run.Bridge
+	lines, err := stock.Reorders(bridge)
+	if err != nil {
+		run.Error("failed to check the stock reorder points", err)
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	warehouse, problems := address.Normalize(bridge.GetWarehouseAddress())
+	if len(problems) > 0 {
+		err := &http.ClassError{Class: "invalid_warehouse", Err: errors.New("error: " + strings.Join(problems, ", "))}
+		run.Error("The warehouse address purchase orders are shipped to is incomplete", err)
+		return err
+	}
+
+	purchaseOrder, err := stock.OpenPurchaseOrder(bridge, lines)
+	if err != nil {
+		run.Error("failed to open a purchase order", err)
+		return err
+	}
+	err = run.Outcome(purchaseOrder.Code, placePurchaseOrder(run, purchaseOrder, warehouse))
+	if err != nil {
+		// Nothing was ordered, the stock is ordered again on the next run
+		cancelErr := stock.CancelPurchaseOrder(bridge, purchaseOrder.Code)
+		if cancelErr != nil {
+			logger.Error(fmt.Sprintf("failed to cancel purchase order [%s]", purchaseOrder.Code), cancelErr)
+		}
+		if run.OverBudget() {
+			return status.ErrOverBudget
+		}
+	}
+	return nil
+}
+
+// placePurchaseOrder :: Creates the purchase order as a buyer order on the buyer account, shipped to the warehouse
+func placePurchaseOrder(run *status.Run, purchaseOrder stock.PurchaseOrder, warehouse address.Address) error {
+	codes := []string{}
+	for code := range purchaseOrder.Lines {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	buyerItems := []BuyerItem{}
+	for _, code := range codes {
+		variantID, supplier, err := products.GetVariantBySellerVariantCode(run.Bridge.GetBuyerAPIKey(), code)
+		if err != nil {
+			run.Error(fmt.Sprintf("failed to find the variant %s for purchase order [%s]", code, purchaseOrder.Code), err)
+			return err
+		}
+		buyerItems = append(buyerItems, BuyerItem{
+			VariantID:      variantID,
+			BuyerReference: code,
+			Quantity:       lenient.Int(purchaseOrder.Lines[code]),
+			Supplier:       supplier,
+		})
+	}
+	created, err := postNewBuyerOrderToAPI(BuyerOrder{
+		BuyerReference: purchaseOrder.Code,
+		Address:        warehouse,
+		Items:          buyerItems,
+	}, run.Bridge.GetBuyerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("Failed to place purchase order [%s]", purchaseOrder.Code), err)
+		return err
+	}
+	logger.Info(fmt.Sprintf("Purchase order placed on the buyer account :: %s --> %s %v", purchaseOrder.Code, created.ID, purchaseOrder.Lines))
+	metrics.PurchaseOrders.Inc(run.Bridge.Name)
+	run.Count("purchase_ordered")
+	err = stock.PlacePurchaseOrder(run.Bridge, purchaseOrder.Code, created.ID)
+	if err != nil {
+		// The purchase order is open either way, the buyer order ID is only for reference
+		logger.Error(fmt.Sprintf("failed to record the buyer order of purchase order [%s]", purchaseOrder.Code), err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package orders
+
+import (
+	"context"
+	"distribution-bridge/env"
+	"distribution-bridge/fakeapi"
+	"distribution-bridge/stock"
+	"testing"
+)
+
+var warehouseSettings = []string{
+	"jobs.orders.mode=stock", "stock.warehouse.name=Receiving", "stock.warehouse.addressOne=1 Dock Rd",
+	"stock.warehouse.city=Waterloo", "stock.warehouse.state=ON", "stock.warehouse.country=CA", "stock.warehouse.zip=N2L 3G1",
+}
+
+func stockOf(t *testing.T, bridge *env.Bridge, code string) stock.Item {
+	items, err := stock.List(bridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if item.Code == code {
+			return item
+		}
+	}
+	t.Fatalf("%s isn't stocked", code)
+	return stock.Item{}
+}
+
+func TestSyncOrdersShipsFromStock(t *testing.T) {
+	api, bridge := newBridge(t, append(warehouseSettings, "stock.reorderPoint=2", "stock.reorderQuantity=10")...)
+	if _, err := stock.Set(bridge, "V-1", []byte(`{"onHand": 3}`)); err != nil {
+		t.Fatal(err)
+	}
+	order := api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+
+	// The order reserves stock, which drops to the reorder point and is ordered from the supplier
+	summary := SyncOrders(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 2 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	buyerOrders := api.BuyerOrders(buyerKey)
+	if len(buyerOrders) != 1 || buyerOrders[0]["buyerReference"] == "order_1" {
+		t.Fatalf("only a purchase order should be placed: %v", buyerOrders)
+	}
+	if city := buyerOrders[0]["address"].(map[string]interface{})["city"]; city != "Waterloo" {
+		t.Errorf("the purchase order should ship to the warehouse, not %v", city)
+	}
+	if item := stockOf(t, bridge, "V-1"); item.OnHand != 3 || item.Reserved != 2 || item.OnOrder != 10 {
+		t.Errorf("unexpected stock: %+v", item)
+	}
+
+	// Nothing changes until something ships
+	summary = SyncOrders(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 0 || len(api.BuyerOrders(buyerKey)) != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+
+	if err := api.UpdateOrder(sellerKey, order["_id"].(string), fakeapi.Document{"shipped": true}); err != nil {
+		t.Fatal(err)
+	}
+	purchaseOrder := api.Orders(buyerKey)[0]
+	if err := api.UpdateOrder(buyerKey, purchaseOrder["_id"].(string), fakeapi.Document{"shipped": true}); err != nil {
+		t.Fatal(err)
+	}
+	summary = SyncOrders(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 2 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if item := stockOf(t, bridge, "V-1"); item.OnHand != 11 || item.Reserved != 0 || item.OnOrder != 0 {
+		t.Errorf("unexpected stock: %+v", item)
+	}
+	purchaseOrders, _ := stock.PurchaseOrders(bridge)
+	if len(purchaseOrders) != 1 || purchaseOrders[0].Status != stock.StatusReceived {
+		t.Errorf("the purchase order should be received: %+v", purchaseOrders)
+	}
+}
+
+func TestSyncOrdersBackordersWithoutStock(t *testing.T) {
+	api, bridge := newBridge(t, append(warehouseSettings, "stock.reorderQuantity=5")...)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+
+	summary := SyncOrders(context.Background(), bridge)
+	if !summary.OK() {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	// 2 are backordered, enough is ordered to cover them and get above the reorder point
+	if item := stockOf(t, bridge, "V-1"); item.Available() != -2 || item.OnOrder != 5 {
+		t.Errorf("unexpected stock: %+v", item)
+	}
+	quantity := api.BuyerOrders(buyerKey)[0]["items"].([]interface{})[0].(map[string]interface{})["quantity"]
+	if quantity != float64(5) {
+		t.Errorf("quantity = %v, expected 5", quantity)
+	}
+}
+
+func TestSyncOrdersCancelsUnplacedPurchaseOrders(t *testing.T) {
+	api, bridge := newBridge(t, append(warehouseSettings, "stock.reorderQuantity=5")...)
+	api.AddOrder(sellerKey, sellerOrder("order_1", "V-1"))
+	api.FailNext("POST", "/buyer/orders", 500, 1)
+
+	summary := SyncOrders(context.Background(), bridge)
+	if summary.OK() {
+		t.Fatalf("the run should fail when the purchase order can't be placed: %s", summary)
+	}
+	if item := stockOf(t, bridge, "V-1"); item.OnOrder != 0 {
+		t.Errorf("the cancelled purchase order shouldn't be on order: %+v", item)
+	}
+
+	summary = SyncOrders(context.Background(), bridge)
+	if !summary.OK() || len(api.BuyerOrders(buyerKey)) != 1 {
+		t.Fatalf("the stock should be ordered on the next run: %s", summary)
+	}
+}
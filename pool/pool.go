@@ -0,0 +1,167 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrSkipPage is returned by a FetchFunc for a page with nothing to process when there may be more pages after it
+var ErrSkipPage = errors.New("pool: nothing to process on the page")
+
+// FetchFunc returns the items on a page. An empty page means there are no more items.
+type FetchFunc func(page int) ([]interface{}, error)
+
+// ProcessFunc handles a single item. Returning an error stops the run (ex. the error budget was exceeded) before the
+// item's page is done.
+type ProcessFunc func(item interface{}) error
+
+// PageDoneFunc is called, in page order, once every item on a page (and every page before it) has been processed.
+// Skipped pages are passed over.
+type PageDoneFunc func(page int, lastItem interface{})
+
+// Options configures a run
+type Options struct {
+	Concurrency int
+	StartPage   int
+	PageDone    PageDoneFunc
+}
+
+// Run pages through fetch and hands every item to process on up to Concurrency workers. The next page is fetched while
+// the current one is processed. Returns the first fetch or process error, or the context's error if it was cancelled;
+// items already being processed are always finished first.
+func Run(ctx context.Context, opts Options, fetch FetchFunc, process ProcessFunc) error {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		stopErr error
+	)
+	stop := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopErr == nil {
+			stopErr = err
+		}
+		cancel()
+	}
+
+	tracker := newPageTracker(opts.StartPage, opts.PageDone)
+	items := make(chan pageItem, concurrency)
+	go func() {
+		defer close(items)
+		for page := opts.StartPage; ; page++ {
+			if ctx.Err() != nil {
+				return
+			}
+			list, err := fetch(page)
+			if err == ErrSkipPage {
+				tracker.add(page, 0, nil)
+				continue
+			}
+			if err != nil {
+				stop(err)
+				return
+			}
+			if len(list) == 0 {
+				return
+			}
+			tracker.add(page, len(list), list[len(list)-1])
+			for _, item := range list {
+				select {
+				case items <- pageItem{page: page, item: item}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				if ctx.Err() != nil {
+					// Drain without processing
+					continue
+				}
+				err := process(item.item)
+				if err != nil {
+					// The page isn't done, so a resumed run processes the item again
+					stop(err)
+					continue
+				}
+				tracker.done(item.page)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if stopErr != nil {
+		return stopErr
+	}
+	// The parent context was cancelled (ex. shutting down)
+	return ctx.Err()
+}
+
+type pageItem struct {
+	page int
+	item interface{}
+}
+
+// pageTracker works out when pages are complete, so progress can be checkpointed
+type pageTracker struct {
+	mu        sync.Mutex
+	next      int
+	remaining map[int]int
+	last      map[int]interface{}
+	pageDone  PageDoneFunc
+}
+
+func newPageTracker(startPage int, pageDone PageDoneFunc) *pageTracker {
+	return &pageTracker{next: startPage, remaining: map[int]int{}, last: map[int]interface{}{}, pageDone: pageDone}
+}
+
+// add records a fetched page. A page without items (skipped) is done right away.
+func (t *pageTracker) add(page int, count int, lastItem interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining[page] = count
+	if count == 0 {
+		t.advance()
+		return
+	}
+	t.last[page] = lastItem
+}
+
+func (t *pageTracker) done(page int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining[page]--
+	t.advance()
+}
+
+// advance reports the pages that are done, in order. Caller must hold the lock.
+func (t *pageTracker) advance() {
+	for {
+		remaining, ok := t.remaining[t.next]
+		if !ok || remaining > 0 {
+			return
+		}
+		if lastItem, ok := t.last[t.next]; ok && t.pageDone != nil {
+			t.pageDone(t.next, lastItem)
+		}
+		delete(t.remaining, t.next)
+		delete(t.last, t.next)
+		t.next++
+	}
+}
@@ -0,0 +1,154 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pages returns a fetch func listing the pages, then an empty page
+func pages(list [][]interface{}) FetchFunc {
+	return func(page int) ([]interface{}, error) {
+		if page >= len(list) {
+			return []interface{}{}, nil
+		}
+		return list[page], nil
+	}
+}
+
+func TestRunCompletesPagesInOrder(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed = map[string]bool{}
+		inFlight  int
+		maxFlight int
+		done      []int
+	)
+	list := [][]interface{}{{"0a", "0b"}, {"1a", "1b", "1c"}, {"2a"}}
+	process := func(item interface{}) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxFlight {
+			maxFlight = inFlight
+		}
+		mu.Unlock()
+		// The first page finishes last
+		if item.(string)[0] == '0' {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		inFlight--
+		processed[item.(string)] = true
+		mu.Unlock()
+		return nil
+	}
+	pageDone := func(page int, lastItem interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		for p := 0; p <= page; p++ {
+			for _, item := range list[p] {
+				if !processed[item.(string)] {
+					t.Errorf("page %d done before %s was processed", page, item)
+				}
+			}
+		}
+		if lastItem != list[page][len(list[page])-1] {
+			t.Errorf("page %d done with %v as the last item", page, lastItem)
+		}
+		done = append(done, page)
+	}
+
+	err := Run(context.Background(), Options{Concurrency: 3, PageDone: pageDone}, pages(list), process)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int{0, 1, 2}, done); diff != "" {
+		t.Errorf("unexpected pages done (-want +got):\n%s", diff)
+	}
+	if len(processed) != 6 || maxFlight > 3 {
+		t.Errorf("processed %d items with up to %d at once", len(processed), maxFlight)
+	}
+}
+
+func TestRunStartsFromThePage(t *testing.T) {
+	processed := []interface{}{}
+	var mu sync.Mutex
+	err := Run(context.Background(), Options{Concurrency: 1, StartPage: 1}, pages([][]interface{}{{"0a"}, {"1a"}, {"2a"}}), func(item interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]interface{}{"1a", "2a"}, processed); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunStops(t *testing.T) {
+	errBudget := errors.New("error: over budget")
+	list := [][]interface{}{{"0a", "0b"}, {"1a"}, {"2a"}}
+
+	// A failed item stops the run, and the pages after it are never done
+	done := []int{}
+	err := Run(context.Background(), Options{Concurrency: 1, PageDone: func(page int, _ interface{}) { done = append(done, page) }}, pages(list), func(item interface{}) error {
+		if item == "1a" {
+			return errBudget
+		}
+		return nil
+	})
+	if err != errBudget || len(done) != 1 {
+		t.Errorf("got %v with pages %v done", err, done)
+	}
+
+	// So does a page that can't be fetched
+	errFetch := errors.New("error: api error :: 500")
+	err = Run(context.Background(), Options{Concurrency: 2}, func(page int) ([]interface{}, error) {
+		if page == 1 {
+			return nil, errFetch
+		}
+		return list[page], nil
+	}, func(interface{}) error { return nil })
+	if err != errFetch {
+		t.Errorf("got %v, expected the fetch error", err)
+	}
+
+	// And shutting down, once the items in progress are finished
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := 0
+	err = Run(ctx, Options{Concurrency: 1}, pages(list), func(interface{}) error {
+		cancel()
+		finished++
+		return nil
+	})
+	if err != context.Canceled || finished != 1 {
+		t.Errorf("got %v after %d items", err, finished)
+	}
+}
+
+func TestRunSkipsPages(t *testing.T) {
+	list := [][]interface{}{{"0a"}, nil, {"2a"}}
+	fetch := func(page int) ([]interface{}, error) {
+		if page == 1 {
+			return nil, ErrSkipPage
+		}
+		return pages(list)(page)
+	}
+	processed := 0
+	done := []int{}
+	err := Run(context.Background(), Options{Concurrency: 1, PageDone: func(page int, _ interface{}) { done = append(done, page) }}, fetch, func(interface{}) error {
+		processed++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int{0, 2}, done); diff != "" || processed != 2 {
+		t.Errorf("processed %d items, unexpected pages done (-want +got):\n%s", processed, diff)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build go1.18
+// +build go1.18
+
+package products
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"testing"
+)
+
+// Whatever the API sends, decoding a product doesn't panic, and a decoded product encodes and decodes back to itself
+func FuzzDecodeProduct(f *testing.F) {
+	f.Add([]byte(productJSON))
+	f.Add([]byte(`{"_id": "1", "variants": [{"retailPrice": "42.99", "weight": ""}], "images": null, "updated": ""}`))
+	f.Add([]byte(`{"_id": "1", "variants": [{"retailPrice": "$42.99"}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		product := decodeProduct(data)
+		if product.decodeErr != nil {
+			return
+		}
+		encoded, err := json.Marshal(product)
+		if err != nil {
+			t.Fatalf("%s decoded to a product that can't be encoded: %v", data, err)
+		}
+		again := decodeProduct(encoded)
+		if again.decodeErr != nil || !cmp.Equal(again, product, cmpopts.IgnoreUnexported(Product{}), cmpopts.EquateEmpty()) {
+			t.Fatalf("%s decoded differently once encoded: %v %s", data, again.decodeErr, cmp.Diff(product, again, cmpopts.IgnoreUnexported(Product{})))
+		}
+	})
+}
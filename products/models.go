@@ -1,60 +1,84 @@
 package products
 
-import "time"
+import (
+	"distribution-bridge/lenient"
+	"distribution-bridge/money"
+	"distribution-bridge/units"
+	"encoding/json"
+	"fmt"
+)
 
 // Created with: https://mholt.github.io/json-to-go/
 type Product struct {
-	ID              string     `json:"_id"`
-	Code            string     `json:"code"`
-	Active          bool       `json:"active"`
-	BodyHTML        string     `json:"bodyHtml"`
-	Images          []Images   `json:"images"`
-	Tags            []string   `json:"tags"`
-	Title           string     `json:"title"`
-	Vendor          string     `json:"vendor"`
-	Variants        []Variants `json:"variants"`
-	Options         []Options  `json:"options"`
-	DelistedUpdated time.Time  `json:"delistedUpdated"`
-	Created         time.Time  `json:"created"`
-	Updated         time.Time  `json:"updated"`
-	CompanyObjectID string     `json:"companyObjectId"`
-	Type            string     `json:"type"`
-	CompanyID       string     `json:"companyId"`
+	ID              string       `json:"_id"`
+	Code            string       `json:"code"`
+	Active          bool         `json:"active"`
+	BodyHTML        string       `json:"bodyHtml"`
+	Images          []Images     `json:"images"`
+	Tags            []string     `json:"tags"`
+	Title           string       `json:"title"`
+	Vendor          string       `json:"vendor"`
+	Variants        []Variants   `json:"variants"`
+	Options         []Options    `json:"options"`
+	DelistedUpdated lenient.Time `json:"delistedUpdated"`
+	Created         lenient.Time `json:"created"`
+	Updated         lenient.Time `json:"updated"`
+	CompanyObjectID string       `json:"companyObjectId"`
+	Type            string       `json:"type"`
+	CompanyID       string       `json:"companyId"`
+
+	// Set when the product was listed but couldn't be decoded, only the ID is known
+	decodeErr error
 }
 
 type Images struct {
 	ID         string        `json:"_id"`
 	Src        string        `json:"src"`
-	Position   int           `json:"position"`
+	Position   lenient.Int   `json:"position"`
 	VariantIds []interface{} `json:"variantIds"`
 }
 type Dimensions struct {
-	Length int    `json:"length"`
-	Width  int    `json:"width"`
-	Height int    `json:"height"`
-	Units  string `json:"units"`
+	Length lenient.Float    `json:"length"`
+	Width  lenient.Float    `json:"width"`
+	Height lenient.Float    `json:"height"`
+	Units  units.LengthUnit `json:"units"`
 }
 type Variants struct {
-	ID                string     `json:"_id"`
-	Title             string     `json:"title"`
-	RetailPrice       float64    `json:"retailPrice"`
-	InventoryQuantity int        `json:"inventory_quantity"`
-	SkipCount         bool       `json:"skipCount"`
-	Weight            int        `json:"weight"`
-	WeightUnits       string     `json:"weightUnits"`
-	Dimensions        Dimensions `json:"dimensions"`
-	Sku               string     `json:"sku"`
-	Barcode           string     `json:"barcode"`
-	BarcodeType       string     `json:"barcodeType"`
-	Code              string     `json:"code"`
-	VariantID         int        `json:"id"`
-	Option1           string     `json:"option1"`
-	Option2           string     `json:"option2"`
-	Option3           string     `json:"option3"`
+	ID                string           `json:"_id"`
+	Title             string           `json:"title"`
+	RetailPrice       money.Money      `json:"retailPrice"`
+	InventoryQuantity lenient.Int      `json:"inventory_quantity"`
+	SkipCount         bool             `json:"skipCount"`
+	Weight            lenient.Float    `json:"weight"`
+	WeightUnits       units.WeightUnit `json:"weightUnits"`
+	Dimensions        Dimensions       `json:"dimensions"`
+	Sku               string           `json:"sku"`
+	Barcode           string           `json:"barcode"`
+	BarcodeType       string           `json:"barcodeType"`
+	Code              string           `json:"code"`
+	VariantID         lenient.Int      `json:"id"`
+	Option1           string           `json:"option1"`
+	Option2           string           `json:"option2"`
+	Option3           string           `json:"option3"`
 }
 type Options struct {
-	ID       string `json:"_id"`
-	Name     string `json:"name"`
-	Position int    `json:"position"`
-	Type     string `json:"type"`
-}
\ No newline at end of file
+	ID       string      `json:"_id"`
+	Name     string      `json:"name"`
+	Position lenient.Int `json:"position"`
+	Type     string      `json:"type"`
+}
+
+// decodeProduct decodes a product from a page of products. A product that can't be decoded keeps its ID and the
+// error, so it fails on its own instead of failing the whole page.
+func decodeProduct(element json.RawMessage) Product {
+	var product Product
+	err := json.Unmarshal(element, &product)
+	if err == nil {
+		return product
+	}
+	var id struct {
+		ID string `json:"_id"`
+	}
+	json.Unmarshal(element, &id)
+	return Product{ID: id.ID, decodeErr: fmt.Errorf("failed to decode the product: %w", err)}
+}
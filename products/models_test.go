@@ -0,0 +1,85 @@
+package products
+
+import (
+	"distribution-bridge/lenient/lenienttest"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+const productJSON = `{
+  "_id": "000000000000000000000001",
+  "code": "JJ-1",
+  "active": true,
+  "bodyHtml": "7 chakra bracelet, in blue or black.",
+  "images": [{"_id": "000000000000000000000004", "src": "https://example.com/JJ-1.jpg", "position": 1, "variantIds": []}],
+  "tags": ["Beads"],
+  "title": "7 Shakra Bracelet",
+  "vendor": "Jack's Jewels",
+  "variants": [{
+    "_id": "000000000000000000000002",
+    "title": "7 Shakra Bracelet - 1",
+    "retailPrice": 42.99,
+    "inventory_quantity": 10,
+    "weight": 0.25,
+    "weightUnits": "kg",
+    "dimensions": {"length": 10.5, "width": 2, "height": 1, "units": "cm"},
+    "sku": "JJ-1.1",
+    "barcode": "1110906994787737",
+    "code": "JJ--1--1",
+    "id": 7
+  }],
+  "options": [{"_id": "000000000000000000000005", "name": "Color", "position": 1, "type": "color"}],
+  "delistedUpdated": "2021-03-01T00:00:00Z",
+  "created": "2021-04-01T12:30:00.123456789Z",
+  "updated": "2021-04-01T16:30:00-04:00",
+  "type": "Bracelet"
+}`
+
+// Numbers sent as strings and dates in other formats decode to the same product
+func TestProductDecodesVariations(t *testing.T) {
+	var want Product
+	if err := json.Unmarshal([]byte(productJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	property := func(seed int64) bool {
+		varied, err := lenienttest.Vary([]byte(productJSON), rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := decodeProduct(varied)
+		if got.decodeErr != nil || !cmp.Equal(got, want, cmpopts.IgnoreUnexported(Product{})) {
+			t.Logf("%s\n%v %s", varied, got.decodeErr, cmp.Diff(want, got, cmpopts.IgnoreUnexported(Product{})))
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// Empty and null values decode to zero values instead of failing the product
+func TestProductDecodesEmptyValues(t *testing.T) {
+	product := decodeProduct([]byte(`{
+		"_id": "1", "images": null, "options": null, "delistedUpdated": "", "created": null, "updated": "",
+		"variants": [{"retailPrice": "", "inventory_quantity": null, "weight": "", "id": "", "dimensions": {"length": null}}]
+	}`))
+	if product.decodeErr != nil {
+		t.Fatal(product.decodeErr)
+	}
+	if product.ID != "1" || !product.Updated.IsZero() || !product.Variants[0].RetailPrice.IsZero() {
+		t.Errorf("unexpected product: %+v", product)
+	}
+}
+
+// A product that can't be decoded keeps its ID, so it can fail on its own
+func TestDecodeProductInvalid(t *testing.T) {
+	product := decodeProduct([]byte(`{"_id": "1", "variants": [{"retailPrice": "$42.99"}]}`))
+	if product.decodeErr == nil || product.ID != "1" {
+		t.Errorf("unexpected product: %+v", product)
+	}
+}
@@ -1,103 +1,389 @@
 package products
 
 import (
+	"context"
+	"distribution-bridge/checkpoint"
 	"distribution-bridge/env"
 	"distribution-bridge/http"
+	"distribution-bridge/lenient"
 	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/money"
+	"distribution-bridge/notify"
+	"distribution-bridge/pool"
+	"distribution-bridge/retries"
+	"distribution-bridge/status"
+	"distribution-bridge/units"
+	"distribution-bridge/watermark"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"strings"
 	"time"
 )
 
+// Sync products from seller account to buyer account of the bridge. Products are processed concurrently, and a product
+// that fails doesn't stop the others unless more fail than the error budget allows.
+func SyncProducts(ctx context.Context, bridge *env.Bridge) status.Summary {
+	run := status.Start(bridge, "products")
+	// Only products updated since the watermark, unless a full sweep is due
+	sweep := checkpoint.Begin(bridge, "products", watermark.Since(bridge, "products", time.Now()))
+	since := sweep.Since()
+	newest := &watermark.Tracker{}
+	listing := &watermark.Listing{}
 
-
-// Sync products from seller account to buyer account.
-func SyncProducts() {
-	page := 0
-	allProductsFound := false
 	productCount := 0
 	// Fetch all products from seller accounts
-	for !allProductsFound {
-		products, err := getProductsFromAPI(page, env.GetBuyerAPIKey())
+	fetch := func(page int) ([]interface{}, error) {
+		products, err := getProductsFromAPI(page, bridge.GetBuyerAPIKey(), since)
 		if err != nil {
-			logger.Error(fmt.Sprintf("failed to get products on page %d", page), err)
-			return
+			run.Error(fmt.Sprintf("failed to get products on page %d", page), err)
+			return nil, err
 		}
 		productCount = productCount + len(products)
 		if len(products) == 0 {
 			logger.Info(fmt.Sprintf("All products have been found [%d]", productCount))
-			allProductsFound = true
-			continue
 		}
-
-		// For each product, it's consider to be new or exist on the buyer account
-		for _, product := range products {
-			sellerProduct, exists, err := getProductFromAPIUsingCode(product.Code, env.GetSellerAPIKey())
-			if err != nil {
-				logger.Error(fmt.Sprintf("failed to get product [%s]", product.ID), err)
-				return
+		updated := []time.Time{}
+		items := []interface{}{}
+		for i := range products {
+			updated = append(updated, products[i].Updated.Time)
+			// In case the API doesn't filter by updatedSince. Products without a date are kept.
+			if !products[i].Updated.IsZero() && products[i].Updated.Before(since) {
+				continue
 			}
+			items = append(items, products[i])
+		}
+		newestFirst := listing.NewestFirst(updated)
+		if len(products) > 0 && len(items) == 0 {
+			// The pages after this one can only be older when the products are listed newest first
+			if !newestFirst {
+				logger.Info(fmt.Sprintf("Products aren't listed newest first, checking the pages after %d [%d]", page, productCount))
+				return nil, pool.ErrSkipPage
+			}
+			logger.Info(fmt.Sprintf("Reached products older than the watermark on page %d [%d]", page, productCount))
+		}
+		return items, nil
+	}
 
-			// Apply PIM updates (This would be any configured overwrites that have been setup)
-			// Not built :: Ex. Loops through Google sheet and when product code = product.Code then updates with columns for corresponding data. Or PIM provider, we make an outbound call to them and they return the updated product
-
-			if exists {
-				logger.Info(fmt.Sprintf("Product [%s] exists and checking for updates.", product.Code))
-				// Check changes, then update
-				err := productsMatch(product, sellerProduct)
-				if err == nil {
-					logger.Info(fmt.Sprintf("Products match between %s and %s", product.ID, sellerProduct.ID))
-					continue
-				} else {
-					logger.Info(fmt.Sprintf("Products did not match between %s and %s b/c %+v", product.ID, sellerProduct.ID, err))
-					// Temp save seller product ID
-					sellerProductID := sellerProduct.ID
-					sellerProduct = product
-					sellerProduct.ID = sellerProductID
-					// Mark updated product as inactive
-					if env.ProductUpdatesToInActive() {
-						sellerProduct.Active = false
-					}
-					err = updateProductOnAPI(env.GetSellerAPIKey(), sellerProduct)
-					if err != nil {
-						logger.Error(fmt.Sprintf("failed to update the product on seller account (Existing) :: %s", sellerProduct.ID), err)
-					}
-				}
-			} else {
-				logger.Info(fmt.Sprintf("Product [%s] does not exist and creating new instance.", product.Code))
-				// Create new product on buyer account
-				productID, err := createProductOnAPI(product, env.GetSellerAPIKey())
-				if err != nil {
-					logger.Error(fmt.Sprintf("failed to create new product on seller account :: Seller Product ID [%s]", product.ID), err)
-					// Not supported but push error to the seller product
-					return
-				}
-				logger.Info(fmt.Sprintf("New product created on buyer account :: %s --> %s", product.ID, productID))
-
-				// Mark new product as inactive
-				if env.NewProductToInActive() {
-					product.Active = false
-					sellerProduct, _, err = getProductFromAPIUsingCode(product.Code, env.GetSellerAPIKey())
-					if err != nil {
-						logger.Error(fmt.Sprintf("failed to get product for seller [%s]", product.ID), err)
-						return
-					}
-
-					err := updateProductOnAPI(env.GetSellerAPIKey(), sellerProduct)
-					logger.Error(fmt.Sprintf("failed to mark product as inactive on seller account (New) :: %s", sellerProduct.ID), err)
-					// Not supported but push error to the buyer and seller product
-					return
-				}
+	// For each product, it's consider to be new or exist on the buyer account
+	process := func(item interface{}) error {
+		product := item.(Product)
+		err := run.Outcome(product.ID, syncProduct(run, product))
+		retries.Track(bridge, retries.KindProduct, product.ID, err)
+		if err != nil {
+			newest.Fail(product.Updated.Time)
+		} else {
+			newest.See(product.Updated.Time)
+		}
+		if err != nil && run.OverBudget() {
+			return status.ErrOverBudget
+		}
+		return nil
+	}
+
+	err := pool.Run(ctx, pool.Options{
+		Concurrency: bridge.GetSyncConcurrency(),
+		StartPage:   sweep.StartPage(),
+		PageDone: func(page int, lastItem interface{}) {
+			sweep.PageDone(page, lastItem.(Product).ID)
+		},
+	}, fetch, process)
+	sweep.Finish(err)
+	if err == nil {
+		watermark.Advance(bridge, "products", since, sweep.Started(), newest.Newest())
+	}
+	return run.FinishWith(err)
+}
+
+// SyncProduct syncs a single product (ex. when a webhook says it was updated) using its ID on the buyer account
+func SyncProduct(run *status.Run, productID string) error {
+	err := run.Outcome(productID, syncProductWithID(run, productID))
+	retries.Track(run.Bridge, retries.KindProduct, productID, err)
+	return err
+}
+
+func syncProductWithID(run *status.Run, productID string) error {
+	resp, err := http.GetSingleRequest(fmt.Sprintf("/products/%s", productID), run.Bridge.GetBuyerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to get product [%s]", productID), err)
+		return err
+	}
+	var product Product
+	err = json.Unmarshal(resp, &product)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to decode product [%s]", productID), err)
+		return err
+	}
+	return syncProduct(run, product)
+}
+
+// syncProduct creates the product on the seller account, or updates it if it already exists and has changed. Unchanged
+// products are skipped.
+func syncProduct(run *status.Run, product Product) error {
+	bridge := run.Bridge
+	if product.decodeErr != nil {
+		run.Error(fmt.Sprintf("failed to decode product [%s]", product.ID), product.decodeErr)
+		countResult(run, metrics.ProductFailed)
+		return product.decodeErr
+	}
+	if !productIncluded(product, bridge.GetProductTags()) {
+		return status.Skip("filtered by tags")
+	}
+	product, err := priceForSeller(product, bridge)
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to price product [%s] for the seller account", product.ID), err)
+		countResult(run, metrics.ProductFailed)
+		return err
+	}
+	product, err = normalizeUnits(product, bridge.GetSellerWeightUnit(), bridge.GetSellerLengthUnit())
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to convert the units of product [%s]", product.ID), err)
+		countResult(run, metrics.ProductFailed)
+		return &http.ClassError{Class: "invalid_units", Err: err}
+	}
+
+	sellerProduct, exists, err := getProductFromAPIUsingCode(product.Code, bridge.GetSellerAPIKey())
+	if err == nil {
+		sellerProduct, err = inCurrency(sellerProduct, bridge.GetSellerCurrency())
+	}
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to get product [%s]", product.ID), err)
+		countResult(run, metrics.ProductFailed)
+		return err
+	}
+
+	// Apply PIM updates (This would be any configured overwrites that have been setup)
+	// Not built :: Ex. Loops through Google sheet and when product code = product.Code then updates with columns for corresponding data. Or PIM provider, we make an outbound call to them and they return the updated product
+
+	if exists {
+		logger.Info(fmt.Sprintf("Product [%s] exists and checking for updates.", product.Code))
+		// Check changes, then update
+		err := productsMatch(product, sellerProduct)
+		if err == nil {
+			logger.Info(fmt.Sprintf("Products match between %s and %s", product.ID, sellerProduct.ID))
+			countResult(run, metrics.ProductUnchanged)
+			return status.Skip("unchanged")
+		}
+		logger.Info(fmt.Sprintf("Products did not match between %s and %s b/c %+v", product.ID, sellerProduct.ID, err))
+		// Temp save seller product ID
+		sellerProductID := sellerProduct.ID
+		sellerProduct = product
+		sellerProduct.ID = sellerProductID
+		// Mark updated product as inactive
+		if bridge.ProductUpdatesToInActive() {
+			sellerProduct.Active = false
+		}
+		err = updateProductOnAPI(bridge.GetSellerAPIKey(), sellerProduct)
+		if err != nil {
+			run.Error(fmt.Sprintf("failed to update the product on seller account (Existing) :: %s", sellerProduct.ID), err)
+			countResult(run, metrics.ProductFailed)
+			return err
+		}
+		countResult(run, metrics.ProductUpdated)
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("Product [%s] does not exist and creating new instance.", product.Code))
+	// Create new product on buyer account
+	productID, err := createProductOnAPI(product, bridge.GetSellerAPIKey())
+	if err != nil {
+		run.Error(fmt.Sprintf("failed to create new product on seller account :: Seller Product ID [%s]", product.ID), err)
+		countResult(run, metrics.ProductFailed)
+		notify.Send(bridge, notify.Event{
+			Kind:    notify.EventProductCreateFailed,
+			Key:     product.ID,
+			Subject: fmt.Sprintf("Product %s couldn't be created on the seller account", product.Code),
+			Details: map[string]string{"product": product.ID, "code": product.Code, "error": logger.Redact(err.Error())},
+		})
+		return err
+	}
+	logger.Info(fmt.Sprintf("New product created on buyer account :: %s --> %s", product.ID, productID))
+	countResult(run, metrics.ProductCreated)
+
+	// Mark new product as inactive
+	if bridge.NewProductToInActive() {
+		sellerProduct, exists, err = getProductFromAPIUsingCode(product.Code, bridge.GetSellerAPIKey())
+		if err != nil {
+			run.Error(fmt.Sprintf("failed to get product for seller [%s]", product.ID), err)
+			return err
+		}
+		if !exists {
+			logger.Info(fmt.Sprintf("Product [%s] was removed from the seller account before it was marked as inactive", product.Code))
+			return nil
+		}
+		sellerProduct.Active = false
+
+		err := updateProductOnAPI(bridge.GetSellerAPIKey(), sellerProduct)
+		if err != nil {
+			run.Error(fmt.Sprintf("failed to mark product as inactive on seller account (New) :: %s", sellerProduct.ID), err)
+			// Not supported but push error to the buyer and seller product
+			return err
+		}
+	}
+	return nil
+}
+
+// productIncluded is true when the product has any of the tags, or no tags are required
+func productIncluded(product Product, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, productTag := range product.Tags {
+			if strings.EqualFold(strings.TrimSpace(productTag), tag) {
+				return true
 			}
+		}
+	}
+	return false
+}
 
-			time.Sleep(time.Second * 1) // Max of 4 API calls in this block, so this is bad rate limiting
+// priceForSeller returns a copy of the buyer account's product with the retail prices converted to the seller
+// account's currency, when they differ, and the markup added
+func priceForSeller(product Product, bridge *env.Bridge) (Product, error) {
+	product, err := inCurrency(product, bridge.GetBuyerCurrency())
+	if err != nil {
+		return product, err
+	}
+	if bridge.GetSellerCurrency() != bridge.GetBuyerCurrency() {
+		rates, err := money.LoadRates(env.GetCurrencyRatesFile())
+		if err != nil {
+			return product, err
 		}
+		product, err = convertPrices(product, rates, bridge.GetSellerCurrency())
+		if err != nil {
+			return product, &http.ClassError{Class: "currency_mismatch", Err: err}
+		}
+	}
+	return applyPricing(product, bridge.GetRetailMarkupPercent()), nil
+}
 
-		page++
+// inCurrency returns a copy of the product with the currency of its account given to the retail prices
+func inCurrency(product Product, currency string) (Product, error) {
+	return mapPrices(product, func(price money.Money) (money.Money, error) {
+		return price.WithCurrency(currency)
+	})
+}
+
+// convertPrices returns a copy of the product with the retail prices converted to the currency
+func convertPrices(product Product, rates money.Rates, currency string) (Product, error) {
+	return mapPrices(product, func(price money.Money) (money.Money, error) {
+		return rates.Convert(price, currency)
+	})
+}
+
+// applyPricing returns a copy of the product with the markup added to each variant's retail price, rounded to the
+// currency's minor unit
+func applyPricing(product Product, markupPercent float64) Product {
+	if markupPercent == 0 {
+		return product
+	}
+	product, _ = mapPrices(product, func(price money.Money) (money.Money, error) {
+		return price.Markup(markupPercent), nil
+	})
+	return product
+}
+
+// mapPrices returns a copy of the product with each variant's retail price replaced
+func mapPrices(product Product, price func(money.Money) (money.Money, error)) (Product, error) {
+	if product.Variants == nil {
+		return product, nil
+	}
+	variants := make([]Variants, len(product.Variants))
+	for i, variant := range product.Variants {
+		retailPrice, err := price(variant.RetailPrice)
+		if err != nil {
+			return product, fmt.Errorf("variant [%s]: %w", variant.Code, err)
+		}
+		variant.RetailPrice = retailPrice
+		variants[i] = variant
 	}
+	product.Variants = variants
+	return product, nil
+}
+
+// normalizeUnits returns a copy of the product with each variant's weight and dimensions units validated and written
+// the standard way (ex. "lbs" as "lb"), then converted to the seller account's units when they are set. A unit that
+// isn't known is an error, unless there is no value for it to apply to.
+func normalizeUnits(product Product, weightUnit units.WeightUnit, lengthUnit units.LengthUnit) (Product, error) {
+	if product.Variants == nil {
+		return product, nil
+	}
+	variants := make([]Variants, len(product.Variants))
+	for i, variant := range product.Variants {
+		weight, err := variantWeight(variant, weightUnit)
+		if err != nil {
+			return product, fmt.Errorf("variant [%s]: %w", variant.Code, err)
+		}
+		dimensions, err := variantDimensions(variant, lengthUnit)
+		if err != nil {
+			return product, fmt.Errorf("variant [%s]: %w", variant.Code, err)
+		}
+		variant.Weight, variant.WeightUnits = lenient.Float(weight.Value), weight.Unit
+		variant.Dimensions = Dimensions{
+			Length: lenient.Float(dimensions.Length),
+			Width:  lenient.Float(dimensions.Width),
+			Height: lenient.Float(dimensions.Height),
+			Units:  dimensions.Unit,
+		}
+		variants[i] = variant
+	}
+	product.Variants = variants
+	return product, nil
+}
+
+// variantWeight returns the variant's weight in the unit, or in its own unit when the unit is empty
+func variantWeight(variant Variants, unit units.WeightUnit) (units.Weight, error) {
+	weight := units.Weight{Value: float64(variant.Weight), Unit: variant.WeightUnits}
+	if weight.Unit == "" && weight.Value == 0 {
+		return units.Weight{Unit: unit}, nil
+	}
+	parsed, err := units.ParseWeightUnit(string(weight.Unit))
+	if err != nil {
+		if weight.Value == 0 {
+			return units.Weight{Unit: unit}, nil
+		}
+		return weight, err
+	}
+	weight.Unit = parsed
+	if unit == "" {
+		return weight, nil
+	}
+	return weight.In(unit), nil
+}
+
+// variantDimensions returns the variant's dimensions in the unit, or in their own unit when the unit is empty
+func variantDimensions(variant Variants, unit units.LengthUnit) (units.Dimensions, error) {
+	dimensions := units.Dimensions{
+		Length: float64(variant.Dimensions.Length),
+		Width:  float64(variant.Dimensions.Width),
+		Height: float64(variant.Dimensions.Height),
+		Unit:   variant.Dimensions.Units,
+	}
+	if dimensions.Unit == "" && dimensions.IsZero() {
+		return units.Dimensions{Unit: unit}, nil
+	}
+	parsed, err := units.ParseLengthUnit(string(dimensions.Unit))
+	if err != nil {
+		if dimensions.IsZero() {
+			return units.Dimensions{Unit: unit}, nil
+		}
+		return dimensions, err
+	}
+	dimensions.Unit = parsed
+	if unit == "" {
+		return dimensions, nil
+	}
+	return dimensions.In(unit), nil
+}
+
+// countResult counts a product sync result for both the metrics and the job status
+func countResult(run *status.Run, result string) {
+	metrics.Products.Inc(run.Bridge.Name, result)
+	run.Count(result)
 }
 
 // productsMatch custom method for comparing two products. IDs will be completely different in both.
@@ -164,22 +450,24 @@ func productsMatch(product Product, productTwo Product) error {
 	}
 
 	// All other fields that should match
-	if !cmp.Equal(product, productTwo, cmpopts.IgnoreFields(Product{}, "ID", "Active", "Images", "Variants", "Options", "Created", "Updated", "CompanyObjectID","CompanyID")) {
+	if !cmp.Equal(product, productTwo, cmpopts.IgnoreFields(Product{}, "ID", "Active", "Images", "Variants", "Options", "Created", "Updated", "CompanyObjectID", "CompanyID"), cmpopts.IgnoreUnexported(Product{})) {
 		return errors.New("products do not match")
 	}
 
 	return nil
 }
 
-// getProductsFromAPI calls the get products endpoint
-func getProductsFromAPI(page int, apiKey string) ([]Product, error) {
-	resp, err := http.GetRequest("/products", page, apiKey)
+// getProductsFromAPI calls the get products endpoint, for products updated since the given time (zero for all)
+func getProductsFromAPI(page int, apiKey string, since time.Time) ([]Product, error) {
+	resp, err := http.GetRequest(http.WithUpdatedSince("/products", since), page, apiKey)
 	if err != nil {
 		return []Product{}, err
 	}
 
-	var response []Product
-	err = json.Unmarshal(resp, &response)
+	response := []Product{}
+	err = lenient.DecodeEach(resp, func(element json.RawMessage) {
+		response = append(response, decodeProduct(element))
+	})
 	if err != nil {
 		return []Product{}, err
 	}
@@ -193,7 +481,7 @@ func getProductFromAPIUsingCode(code string, apiKey string) (Product, bool, erro
 	}
 
 	// Capture 404
-	fmt.Printf("getProductFromAPI :: err :: %+v\n", err)
+	logger.Info(fmt.Sprintf("getProductFromAPI :: err :: %+v", err))
 
 	var response []Product
 	err = json.Unmarshal(resp, &response)
@@ -207,7 +495,7 @@ func getProductFromAPIUsingCode(code string, apiKey string) (Product, bool, erro
 }
 
 func createProductOnAPI(product Product, apiKey string) (string, error) {
-	fmt.Printf("product :: %+v", product)
+	logger.Info(fmt.Sprintf("product :: %+v", product))
 	jsonPayload, err := json.Marshal(product)
 	if err != nil {
 		return "", err
@@ -247,17 +535,27 @@ func updateProductOnAPI(apiKey string, product Product) error {
 
 // Buyer account calling a seller endpoint (This should be fixed)
 func GetIDOfVariantBySellerVariantCode(apiKey string, sellerVariantCode string) (string, error) {
+	variantID, _, err := GetVariantBySellerVariantCode(apiKey, sellerVariantCode)
+	return variantID, err
+}
+
+// GetVariantBySellerVariantCode returns the ID of the variant and the company ID of the supplier whose product it is,
+// which is who the platform routes order lines with the variant to
+func GetVariantBySellerVariantCode(apiKey string, sellerVariantCode string) (string, string, error) {
 	page := 0
 	found := false
 	for !found {
 		resp, err := http.GetRequest("/products", page, apiKey)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
-		var products []Product
-		err = json.Unmarshal(resp, &products)
+		// A product that can't be decoded has no variants, it doesn't stop the lookup
+		products := []Product{}
+		err = lenient.DecodeEach(resp, func(element json.RawMessage) {
+			products = append(products, decodeProduct(element))
+		})
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
 		if len(products) == 0 {
@@ -267,13 +565,12 @@ func GetIDOfVariantBySellerVariantCode(apiKey string, sellerVariantCode string)
 		for _, product := range products {
 			for _, variant := range product.Variants {
 				if variant.Code == sellerVariantCode {
-					return variant.ID, nil
+					return variant.ID, product.CompanyID, nil
 				}
 			}
 		}
 
 		page++
-		time.Sleep(time.Second * 1)
 	}
-	return "", errors.New(fmt.Sprintf("ID of variant not found using variantID/Code (%s)", sellerVariantCode))
-}
\ No newline at end of file
+	return "", "", errors.New(fmt.Sprintf("ID of variant not found using variantID/Code (%s)", sellerVariantCode))
+}
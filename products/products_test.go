@@ -0,0 +1,413 @@
+package products
+
+import (
+	"context"
+	"distribution-bridge/env"
+	"distribution-bridge/fakeapi"
+	"distribution-bridge/retries"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	sellerKey = "seller-key"
+	buyerKey  = "buyer-key"
+)
+
+// newBridge returns a fake API with a seller and a buyer account, and a bridge between them with its own state. The
+// settings are set for the bridge only, ex. "policies.newProductToInactive=false".
+func newBridge(t *testing.T, settings ...string) (*fakeapi.Server, *env.Bridge) {
+	api := fakeapi.New()
+	api.AddAccount("seller", sellerKey)
+	api.AddAccount("buyer", buyerKey)
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return api, loadBridge(t, srv.URL, settings...)
+}
+
+// loadBridge loads the config of a bridge with its own state that calls the API at the URL
+func loadBridge(t *testing.T, apiURL string, settings ...string) *env.Bridge {
+	dir, err := ioutil.TempDir("", "bridge-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	overrides := []string{"api.url=" + apiURL, "api.rateLimit=0", "state.dir=" + dir}
+	for _, setting := range append([]string{"api.sellerKey=" + sellerKey, "api.buyerKey=" + buyerKey}, settings...) {
+		overrides = append(overrides, "bridges.test."+setting)
+	}
+	err = env.Load("", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge, err := env.GetBridge("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bridge
+}
+
+func buyerProduct(code string, title string) fakeapi.Document {
+	return fakeapi.Document{
+		"code":   code,
+		"title":  title,
+		"active": true,
+		"tags":   []interface{}{"Beads"},
+		"images": []interface{}{map[string]interface{}{"src": "https://example.com/" + code + ".jpg", "position": 1}},
+		"variants": []interface{}{
+			map[string]interface{}{"code": code + "--1", "sku": code + ".1", "title": title + " - 1", "retailPrice": 42.99},
+		},
+	}
+}
+
+// productByCode returns the account's product with the code, failing the test unless there is exactly one
+func productByCode(t *testing.T, products []fakeapi.Document, code string) fakeapi.Document {
+	t.Helper()
+	found := []fakeapi.Document{}
+	for _, product := range products {
+		if product["code"] == code {
+			found = append(found, product)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %d products with the code %s, expected 1", len(found), code)
+	}
+	return found[0]
+}
+
+func countRequests(api *fakeapi.Server, method string, path string) int {
+	count := 0
+	for _, request := range api.Requests() {
+		if request.Method == method && request.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSyncProductsCreatesInactiveProducts(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+
+	sellerProducts := api.Products(sellerKey)
+	product := productByCode(t, sellerProducts, "JJ-1")
+	if product["title"] != "Bracelet" {
+		t.Errorf("title = %v, expected Bracelet", product["title"])
+	}
+	if product["active"] != false {
+		t.Errorf("new products should be inactive by default")
+	}
+	variants := product["variants"].([]interface{})
+	if len(variants) != 1 || variants[0].(map[string]interface{})["sku"] != "JJ-1.1" {
+		t.Errorf("unexpected variants: %v", variants)
+	}
+	if len(api.Products(buyerKey)) != 1 {
+		t.Errorf("the buyer account should be unchanged")
+	}
+}
+
+func TestSyncProductsNewProductRemovedBeforeMarkedInactive(t *testing.T) {
+	api := fakeapi.New()
+	api.AddAccount("seller", sellerKey)
+	api.AddAccount("buyer", buyerKey)
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+	// The seller removes the product as soon as it is created
+	created := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seller := r.Header.Get("Authorization") == sellerKey
+		if seller && created && r.Method == http.MethodGet && r.URL.Path == "/products" {
+			w.Write([]byte("[]"))
+			return
+		}
+		api.ServeHTTP(w, r)
+		created = created || (seller && r.Method == http.MethodPost && r.URL.Path == "/products")
+	}))
+	t.Cleanup(srv.Close)
+	bridge := loadBridge(t, srv.URL)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	for _, request := range api.Requests() {
+		if request.Method == http.MethodPut {
+			t.Errorf("expected no update of the removed product, got %+v", request)
+		}
+	}
+	if len(retries.List(bridge, false)) != 0 {
+		t.Errorf("expected nothing to retry, got %+v", retries.List(bridge, false))
+	}
+}
+
+func TestSyncProductsNewProductsActive(t *testing.T) {
+	api, bridge := newBridge(t, "policies.newProductToInactive=false")
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if productByCode(t, api.Products(sellerKey), "JJ-1")["active"] != true {
+		t.Errorf("the product should stay active")
+	}
+}
+
+func TestSyncProductsFiltersByTags(t *testing.T) {
+	api, bridge := newBridge(t, "filters.productTags=Charms,beads")
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+	rings := buyerProduct("JJ-2", "Ring")
+	rings["tags"] = []interface{}{"Rings"}
+	api.AddProduct(buyerKey, rings)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if products := api.Products(sellerKey); len(products) != 1 || products[0]["code"] != "JJ-1" {
+		t.Errorf("only the product tagged Beads should be synced: %v", products)
+	}
+}
+
+func TestSyncProductsUpdatesChangedProducts(t *testing.T) {
+	for _, inactive := range []bool{false, true} {
+		t.Run(fmt.Sprintf("productUpdatesToInactive=%t", inactive), func(t *testing.T) {
+			api, bridge := newBridge(t, fmt.Sprintf("policies.productUpdatesToInactive=%t", inactive))
+			api.AddProduct(buyerKey, buyerProduct("JJ-1", "New title"))
+			existing := buyerProduct("JJ-1", "Old title")
+			existing["active"] = true
+			existing = api.AddProduct(sellerKey, existing)
+
+			summary := SyncProducts(context.Background(), bridge)
+			if !summary.OK() || summary.Succeeded != 1 {
+				t.Fatalf("unexpected summary: %s", summary)
+			}
+			product := productByCode(t, api.Products(sellerKey), "JJ-1")
+			if product["_id"] != existing["_id"] {
+				t.Errorf("the existing product should be updated, not replaced")
+			}
+			if product["title"] != "New title" {
+				t.Errorf("title = %v, expected New title", product["title"])
+			}
+			if product["active"] != !inactive {
+				t.Errorf("active = %v, expected %t", product["active"], !inactive)
+			}
+		})
+	}
+}
+
+func TestSyncProductsSkipsUnchangedProducts(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+	SyncProducts(context.Background(), bridge)
+	puts := countRequests(api, "PUT", "/products/"+productByCode(t, api.Products(sellerKey), "JJ-1")["_id"].(string))
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Skipped != 1 || summary.Outcomes[0].Reason != "unchanged" {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	if countRequests(api, "POST", "/products") != 1 {
+		t.Errorf("the product should only be created once")
+	}
+	if countRequests(api, "PUT", "/products/"+productByCode(t, api.Products(sellerKey), "JJ-1")["_id"].(string)) != puts {
+		t.Errorf("an unchanged product should not be updated")
+	}
+}
+
+func TestSyncProductsConvertsCurrency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	ratesFile := filepath.Join(dir, "rates.json")
+	err = ioutil.WriteFile(ratesFile, []byte(`{"base": "USD", "rates": {"CAD": 1.25}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CURRENCY_RATES_FILE", ratesFile)
+	t.Cleanup(func() { os.Unsetenv("CURRENCY_RATES_FILE") })
+
+	api, bridge := newBridge(t, "pricing.buyerCurrency=USD", "pricing.sellerCurrency=CAD", "pricing.retailMarkupPercent=10")
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	// 42.99 USD is 53.74 CAD, plus 10%
+	variant := productByCode(t, api.Products(sellerKey), "JJ-1")["variants"].([]interface{})[0].(map[string]interface{})
+	if variant["retailPrice"] != 59.11 {
+		t.Errorf("retailPrice = %v, expected 59.11", variant["retailPrice"])
+	}
+
+	// The converted price is unchanged on the next run
+	summary = SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Skipped != 1 {
+		t.Errorf("unexpected summary: %s", summary)
+	}
+}
+
+func TestSyncProductsMarksUpWithoutACurrency(t *testing.T) {
+	api, bridge := newBridge(t, "pricing.retailMarkupPercent=15")
+	product := buyerProduct("JJ-1", "Bracelet")
+	product["variants"] = []interface{}{map[string]interface{}{"code": "JJ-1--1", "sku": "JJ-1.1", "retailPrice": 9.99}}
+	api.AddProduct(buyerKey, product)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	// 9.99 plus 15% is 11.4885, rounded to cents
+	variant := productByCode(t, api.Products(sellerKey), "JJ-1")["variants"].([]interface{})[0].(map[string]interface{})
+	if variant["retailPrice"] != 11.49 {
+		t.Errorf("retailPrice = %v, expected 11.49", variant["retailPrice"])
+	}
+}
+
+func TestSyncProductsConvertsUnits(t *testing.T) {
+	api, bridge := newBridge(t, "units.sellerWeight=kg", "units.sellerLength=cm")
+	product := buyerProduct("JJ-1", "Bracelet")
+	product["variants"] = []interface{}{map[string]interface{}{
+		"code": "JJ-1--1", "sku": "JJ-1.1", "retailPrice": 42.99, "weight": 2.5, "weightUnits": "lbs",
+		"dimensions": map[string]interface{}{"length": 10, "width": 4, "height": "0.5", "units": "Inches"},
+	}}
+	api.AddProduct(buyerKey, product)
+	// A unit the bridge doesn't know fails the product instead of syncing the wrong size
+	unknown := buyerProduct("JJ-2", "Necklace")
+	unknown["variants"] = []interface{}{map[string]interface{}{
+		"code": "JJ-2--1", "sku": "JJ-2.1", "retailPrice": 42.99, "weight": 3, "weightUnits": "stone",
+	}}
+	api.AddProduct(buyerKey, unknown)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if summary.OK() || summary.Succeeded != 1 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	variant := productByCode(t, api.Products(sellerKey), "JJ-1")["variants"].([]interface{})[0].(map[string]interface{})
+	if variant["weight"] != 1.133981 || variant["weightUnits"] != "kg" {
+		t.Errorf("weight = %v %v, expected 1.133981 kg", variant["weight"], variant["weightUnits"])
+	}
+	dimensions := variant["dimensions"].(map[string]interface{})
+	if dimensions["length"] != 25.4 || dimensions["width"] != 10.16 || dimensions["height"] != 1.27 || dimensions["units"] != "cm" {
+		t.Errorf("unexpected dimensions: %v", dimensions)
+	}
+	if len(api.Products(sellerKey)) != 1 {
+		t.Errorf("the product with an unknown unit should not be created")
+	}
+}
+
+func TestSyncProductsPagination(t *testing.T) {
+	for _, count := range []int{250, 251} {
+		t.Run(fmt.Sprintf("%d products", count), func(t *testing.T) {
+			api, bridge := newBridge(t, "policies.newProductToInactive=false")
+			for i := 0; i < count; i++ {
+				api.AddProduct(buyerKey, buyerProduct(fmt.Sprintf("P-%03d", i), "Product"))
+			}
+
+			summary := SyncProducts(context.Background(), bridge)
+			if !summary.OK() || summary.Succeeded != count {
+				t.Fatalf("unexpected summary: %s", summary)
+			}
+			sellerProducts := api.Products(sellerKey)
+			if len(sellerProducts) != count {
+				t.Fatalf("seller account has %d products, expected %d", len(sellerProducts), count)
+			}
+			for i := 0; i < count; i++ {
+				productByCode(t, sellerProducts, fmt.Sprintf("P-%03d", i))
+			}
+		})
+	}
+}
+
+func TestSyncProductsFailedProductIsRetried(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+	api.AddProduct(buyerKey, buyerProduct("JJ-2", "Necklace"))
+	// The products are synced concurrently, either one may be the first created
+	api.FailNext("POST", "/products", 500, 1)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if summary.OK() || summary.Failed != 1 || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	sellerProducts := api.Products(sellerKey)
+	if len(sellerProducts) != 1 {
+		t.Fatalf("the other product should still be created")
+	}
+	queued := retries.List(bridge, false)
+	if len(queued) != 1 || queued[0].Kind != retries.KindProduct {
+		t.Fatalf("unexpected retry queue: %+v", queued)
+	}
+	for _, product := range api.Products(buyerKey) {
+		if product["_id"] == queued[0].EntityID && product["code"] == sellerProducts[0]["code"] {
+			t.Errorf("the created product should not be retried: %+v", queued[0])
+		}
+	}
+}
+
+func TestSyncProductsOddPayloads(t *testing.T) {
+	api, bridge := newBridge(t, "policies.newProductToInactive=false")
+	// A price sent as a string and null collections are fine
+	odd := buyerProduct("JJ-1", "Bracelet")
+	odd["images"] = nil
+	odd["delistedUpdated"] = ""
+	odd["variants"] = []interface{}{map[string]interface{}{"code": "JJ-1--1", "sku": "JJ-1.1", "retailPrice": "42.99"}}
+	api.AddProduct(buyerKey, odd)
+	// A price that isn't a number only fails its own product
+	broken := buyerProduct("JJ-2", "Necklace")
+	broken["variants"] = []interface{}{map[string]interface{}{"code": "JJ-2--1", "sku": "JJ-2.1", "retailPrice": "$42.99"}}
+	broken = api.AddProduct(buyerKey, broken)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if summary.OK() || summary.Succeeded != 1 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	product := productByCode(t, api.Products(sellerKey), "JJ-1")
+	if price := product["variants"].([]interface{})[0].(map[string]interface{})["retailPrice"]; price != 42.99 {
+		t.Errorf("retailPrice = %v, expected 42.99", price)
+	}
+	if len(api.Products(sellerKey)) != 1 {
+		t.Errorf("the broken product should not be created")
+	}
+	if _, err := retries.Get(bridge, retries.EntryID(retries.KindProduct, broken["_id"].(string))); err != nil {
+		t.Errorf("the broken product should be queued for a retry: %v", err)
+	}
+}
+
+func TestSyncProductsListingFails(t *testing.T) {
+	api, bridge := newBridge(t)
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+	api.FailNext("GET", "/products", 500, 1)
+
+	summary := SyncProducts(context.Background(), bridge)
+	if summary.OK() {
+		t.Fatalf("the run should fail when the products can't be listed: %s", summary)
+	}
+	if len(api.Products(sellerKey)) != 0 {
+		t.Errorf("nothing should be created")
+	}
+}
+
+func TestSyncProductsInvalidAPIKey(t *testing.T) {
+	api, bridge := newBridge(t, "api.buyerKey=wrong")
+	api.AddProduct(buyerKey, buyerProduct("JJ-1", "Bracelet"))
+
+	summary := SyncProducts(context.Background(), bridge)
+	if summary.OK() {
+		t.Fatalf("the run should fail with an invalid API key: %s", summary)
+	}
+	if len(api.Products(sellerKey)) != 0 {
+		t.Errorf("nothing should be created")
+	}
+}
@@ -0,0 +1,76 @@
+package products
+
+import (
+	"context"
+	"distribution-bridge/http"
+	"encoding/json"
+	"testing"
+)
+
+// replay answers the API requests from a recorded fixture instead of the fake API
+func replay(t *testing.T, path string) *http.Replayer {
+	fixture, err := http.LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := http.NewReplayer(fixture, map[string]string{sellerKey: "seller", buyerKey: "buyer"})
+	http.SetTransport(replayer)
+	t.Cleanup(func() { http.SetTransport(nil) })
+	return replayer
+}
+
+// checkReplayed fails the test if a request wasn't recorded or a recorded request wasn't sent
+func checkReplayed(t *testing.T, replayer *http.Replayer) {
+	t.Helper()
+	for _, request := range replayer.Unmatched() {
+		t.Errorf("unexpected request: %s", request)
+	}
+	for _, interaction := range replayer.Unused() {
+		t.Errorf("request not sent: %s %s %s", interaction.Request.Method, interaction.Request.APIKey, interaction.Request.URL)
+	}
+}
+
+// sentBody decodes the body of the only request sent with the method and API key alias
+func sentBody(t *testing.T, replayer *http.Replayer, method string, apiKey string) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	for _, request := range replayer.Requests() {
+		if request.Method != method || request.APIKey != apiKey {
+			continue
+		}
+		if body != nil {
+			t.Fatalf("more than one %s request was sent with the %s key", method, apiKey)
+		}
+		if err := json.Unmarshal(request.Body, &body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if body == nil {
+		t.Fatalf("no %s request was sent with the %s key", method, apiKey)
+	}
+	return body
+}
+
+// The buyer account returns null images and options and fields the bridge doesn't know about
+func TestRegressionProductNullCollections(t *testing.T) {
+	bridge := loadBridge(t, "http://api.invalid")
+	replayer := replay(t, "testdata/product_null_collections.json")
+
+	summary := SyncProducts(context.Background(), bridge)
+	if !summary.OK() || summary.Succeeded != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	checkReplayed(t, replayer)
+
+	created := sentBody(t, replayer, "POST", "seller")
+	if created["code"] != "JJ-1" {
+		t.Errorf("unexpected product created: %v", created)
+	}
+	if variants, _ := created["variants"].([]interface{}); len(variants) != 2 {
+		t.Errorf("the product should be created with its 2 variants: %v", created["variants"])
+	}
+	updated := sentBody(t, replayer, "PUT", "seller")
+	if updated["active"] != false {
+		t.Errorf("the new product should be made inactive: %v", updated)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/orders"
+	"distribution-bridge/products"
+	"distribution-bridge/retries"
+	"distribution-bridge/status"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const retriesUsage = `Usage:
+  retries list [--dead]          List pending retries, or the dead letters
+  retries inspect <id>           Show a single entry
+  retries retry <id> | --dead    Retry an entry (or every dead letter) now
+  retries purge <id> | --dead | --all
+                                 Remove an entry, every dead letter, or everything`
+
+// processRetries syncs every entity queued for the bridge whose next retry time has passed
+func processRetries(ctx context.Context, bridge *env.Bridge) status.Summary {
+	due := retries.Due(bridge, time.Now())
+	if len(due) == 0 {
+		return status.Summary{Job: bridge.Label("retries")}
+	}
+	logger.Info(fmt.Sprintf("Retrying %d failed entities for %s", len(due), bridge.Name))
+	run := status.Start(bridge, "retries")
+	for _, entry := range due {
+		if ctx.Err() != nil {
+			return run.FinishWith(ctx.Err())
+		}
+		err := retryEntry(run, entry)
+		if err != nil && run.OverBudget() {
+			return run.FinishWith(status.ErrOverBudget)
+		}
+	}
+	// Failures are already back in the queue
+	return run.Finish(true)
+}
+
+// retryEntry runs the single entity sync for the entry. The sync updates the entry with the result.
+func retryEntry(run *status.Run, entry retries.Entry) error {
+	metrics.Retries.Inc(run.Bridge.Name, entry.Kind)
+	switch entry.Kind {
+	case retries.KindProduct:
+		return products.SyncProduct(run, entry.EntityID)
+	case retries.KindNewOrder:
+		return orders.SyncNewOrder(run, entry.EntityID)
+	case retries.KindOrderUpdate:
+		return orders.SyncOrderUpdate(run, entry.EntityID)
+	}
+	return fmt.Errorf("error: unknown retry kind %q", entry.Kind)
+}
+
+// retriesCommand lists, inspects, retries and purges entries in a bridge's retry queue. Returns the exit code.
+func retriesCommand(args []string, bridges []*env.Bridge) int {
+	if len(args) == 0 {
+		fmt.Println(retriesUsage)
+		return 2
+	}
+	if len(bridges) != 1 {
+		logger.Info("Several bridges are configured, choose one with --bridge <name>")
+		return 2
+	}
+	bridge := bridges[0]
+	arg := ""
+	if len(args) > 1 {
+		arg = args[1]
+	}
+
+	switch args[0] {
+	case "list":
+		printEntries(retries.List(bridge, arg == "--dead"))
+		return 0
+	case "inspect":
+		entry, err := retries.Get(bridge, arg)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to inspect %q", arg), err)
+			return 1
+		}
+		out, _ := json.MarshalIndent(entry, "", "  ")
+		fmt.Println(string(out))
+		return 0
+	case "retry":
+		requireEnvVariables(bridges)
+		entries := []retries.Entry{}
+		if arg == "--dead" {
+			entries = retries.List(bridge, true)
+		} else {
+			entry, err := retries.Get(bridge, arg)
+			if err != nil {
+				logger.Error(fmt.Sprintf("failed to retry %q", arg), err)
+				return 1
+			}
+			entries = append(entries, entry)
+		}
+
+		mu := bridgeMu(bridge)
+		mu.Lock()
+		defer mu.Unlock()
+		run := status.Start(bridge, "retries")
+		failed := 0
+		for _, entry := range entries {
+			err := retries.Reset(bridge, entry.ID)
+			if err == nil {
+				err = retryEntry(run, entry)
+			}
+			if err != nil {
+				failed++
+				continue
+			}
+			logger.Info(fmt.Sprintf("Retried %s", entry.ID))
+		}
+		run.Finish(failed == 0)
+		logger.Info(fmt.Sprintf("Retried %d entries, %d failed", len(entries), failed))
+		if failed > 0 {
+			return 1
+		}
+		return 0
+	case "purge":
+		switch arg {
+		case "--dead", "--all":
+			count, err := retries.PurgeAll(bridge, arg == "--dead")
+			if err != nil {
+				logger.Error("failed to purge the retry queue", err)
+				return 1
+			}
+			logger.Info(fmt.Sprintf("Purged %d entries", count))
+		default:
+			err := retries.Purge(bridge, arg)
+			if err != nil {
+				logger.Error(fmt.Sprintf("failed to purge %q", arg), err)
+				return 1
+			}
+			logger.Info(fmt.Sprintf("Purged %s", arg))
+		}
+		return 0
+	}
+	fmt.Println(retriesUsage)
+	return 2
+}
+
+func printEntries(entries []retries.Entry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tATTEMPTS\tLAST FAILED\tNEXT RETRY\tERROR")
+	for _, entry := range entries {
+		nextRetry := entry.NextRetry.Format(time.RFC3339)
+		if entry.Dead {
+			nextRetry = "dead"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", entry.ID, entry.Attempts, entry.LastFailed.Format(time.RFC3339), nextRetry, truncate(entry.Error, 80))
+	}
+	w.Flush()
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
@@ -0,0 +1,319 @@
+package retries
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/notify"
+	"distribution-bridge/store"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of entities that can be retried
+const (
+	KindProduct     = "product"      // Buyer account product ID
+	KindNewOrder    = "new_order"    // Seller account order ID
+	KindOrderUpdate = "order_update" // Buyer account order ID
+)
+
+// maxDelay caps the backoff between retries
+const maxDelay = time.Hour * 6
+
+const fileName = "retries.json"
+
+// indexes are the entry IDs of each queue file as of when it was last read, by path, so a successful sync doesn't read
+// the queue when nothing is queued for the entity
+var (
+	indexMu sync.Mutex
+	indexes = map[string]index{}
+)
+
+// index is the entry IDs of a queue file and the file they were read from
+type index struct {
+	file os.FileInfo
+	ids  map[string]bool
+}
+
+// ErrNotFound is returned when there is no entry with the ID
+var ErrNotFound = errors.New("error: retry entry not found")
+
+// Entry is a failed entity waiting to be retried, or dead lettered after too many attempts
+type Entry struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	EntityID    string    `json:"entityId"`
+	Error       string    `json:"error"`
+	Attempts    int       `json:"attempts"`
+	FirstFailed time.Time `json:"firstFailed"`
+	LastFailed  time.Time `json:"lastFailed"`
+	NextRetry   time.Time `json:"nextRetry"`
+	Dead        bool      `json:"dead"`
+}
+
+// queue is a bridge's retry queue, kept in the bridge's state directory. It is read again for every operation so a
+// change made by another process (ex. the retries command while serve is running) is never overwritten.
+type queue struct {
+	bridge  *env.Bridge
+	entries map[string]*Entry
+}
+
+func newQueue(bridge *env.Bridge, list []*Entry) *queue {
+	q := &queue{bridge: bridge, entries: map[string]*Entry{}}
+	for _, entry := range list {
+		q.entries[entry.ID] = entry
+	}
+	return q
+}
+
+// read returns the bridge's queue as it is on disk
+func read(bridge *env.Bridge) (*queue, error) {
+	// The file is checked before it is read, so a change made in between is read again by queued
+	path := store.Path(bridge.GetStateDir(), fileName)
+	file, statErr := os.Stat(path)
+	list := []*Entry{}
+	err := store.Read(bridge.GetStateDir(), fileName, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the retry queue of %s: %w", bridge.Name, err)
+	}
+	q := newQueue(bridge, list)
+	q.updateMetrics()
+	if statErr == nil {
+		ids := map[string]bool{}
+		for id := range q.entries {
+			ids[id] = true
+		}
+		indexMu.Lock()
+		indexes[path] = index{file: file, ids: ids}
+		indexMu.Unlock()
+	}
+	return q, nil
+}
+
+// queued is false when the bridge has no entry for the entity. The queue is only read when its file changed since it
+// was last read.
+func queued(bridge *env.Bridge, id string) bool {
+	path := store.Path(bridge.GetStateDir(), fileName)
+	file, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	indexMu.Lock()
+	cached, ok := indexes[path]
+	indexMu.Unlock()
+	if ok && os.SameFile(cached.file, file) && cached.file.ModTime().Equal(file.ModTime()) && cached.file.Size() == file.Size() {
+		return cached.ids[id]
+	}
+	q, err := read(bridge)
+	if err != nil {
+		return true
+	}
+	_, ok = q.entries[id]
+	return ok
+}
+
+// update reads the bridge's queue, applies the change and saves the queue when the change returns true, holding the
+// queue's lock throughout
+func update(bridge *env.Bridge, change func(q *queue) (bool, error)) error {
+	list := []*Entry{}
+	var q *queue
+	err := store.Update(bridge.GetStateDir(), fileName, &list, func() (bool, error) {
+		q = newQueue(bridge, list)
+		changed, err := change(q)
+		if changed && err == nil {
+			list = q.list()
+		}
+		return changed, err
+	})
+	if err == nil {
+		q.updateMetrics()
+	}
+	return err
+}
+
+// EntryID returns the ID of the entry for an entity
+func EntryID(kind string, entityID string) string {
+	return fmt.Sprintf("%s:%s", kind, entityID)
+}
+
+// Track records the result of syncing a bridge's entity: a failure is queued for retry, a success clears any queued
+// entry
+func Track(bridge *env.Bridge, kind string, entityID string, syncErr error) {
+	if entityID == "" || (syncErr == nil && !queued(bridge, EntryID(kind, entityID))) {
+		return
+	}
+	var dead Entry
+	deadLettered := false
+	err := update(bridge, func(q *queue) (bool, error) {
+		if syncErr == nil {
+			return q.resolve(kind, entityID), nil
+		}
+		dead, deadLettered = q.record(kind, entityID, syncErr, time.Now())
+		return true, nil
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to save the retry queue of %s", bridge.Name), err)
+		return
+	}
+
+	if deadLettered {
+		notify.Send(bridge, notify.Event{
+			Kind:    notify.EventDeadLetter,
+			Key:     dead.ID,
+			Subject: fmt.Sprintf("Gave up on %s %s after %d attempts", dead.Kind, dead.EntityID, dead.Attempts),
+			Details: map[string]string{"kind": dead.Kind, "id": dead.EntityID, "error": dead.Error},
+		})
+	}
+}
+
+// record queues a failure. Returns the entry and true when it was moved to the dead letter list.
+func (q *queue) record(kind string, entityID string, err error, now time.Time) (Entry, bool) {
+	id := EntryID(kind, entityID)
+	entry, ok := q.entries[id]
+	if !ok {
+		entry = &Entry{ID: id, Kind: kind, EntityID: entityID, FirstFailed: now}
+		q.entries[id] = entry
+	}
+	entry.Attempts++
+	entry.Error = logger.Redact(err.Error())
+	entry.LastFailed = now
+	entry.NextRetry = now.Add(backoff(q.bridge.GetRetryBaseDelay(), entry.Attempts))
+	deadLettered := false
+	if entry.Attempts >= q.bridge.GetRetryMaxAttempts() && !entry.Dead {
+		entry.Dead = true
+		deadLettered = true
+		logger.Info(fmt.Sprintf("Moved %s to the dead letter list after %d attempts", q.bridge.Label(id), entry.Attempts))
+	}
+	return *entry, deadLettered
+}
+
+// resolve clears a queued entry after a success. Returns false when there was none.
+func (q *queue) resolve(kind string, entityID string) bool {
+	id := EntryID(kind, entityID)
+	if _, ok := q.entries[id]; !ok {
+		return false
+	}
+	delete(q.entries, id)
+	logger.Info(fmt.Sprintf("Retry succeeded for %s", q.bridge.Label(id)))
+	return true
+}
+
+// backoff doubles the base delay with each attempt
+func backoff(delay time.Duration, attempts int) time.Duration {
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// Due returns the bridge's pending (not dead) entries whose next retry time has passed
+func Due(bridge *env.Bridge, now time.Time) []Entry {
+	due := []Entry{}
+	for _, entry := range List(bridge, false) {
+		if !entry.NextRetry.After(now) {
+			due = append(due, entry)
+		}
+	}
+	return due
+}
+
+// List returns the bridge's pending entries, or the dead letters, sorted by ID
+func List(bridge *env.Bridge, dead bool) []Entry {
+	q, err := read(bridge)
+	if err != nil {
+		logger.Error("failed to list the retry queue", err)
+		return []Entry{}
+	}
+	list := []Entry{}
+	for _, entry := range q.list() {
+		if entry.Dead == dead {
+			list = append(list, *entry)
+		}
+	}
+	return list
+}
+
+// Get returns a single entry of the bridge's
+func Get(bridge *env.Bridge, id string) (Entry, error) {
+	q, err := read(bridge)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, ok := q.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return *entry, nil
+}
+
+// Reset makes an entry (pending or dead) due for retry right away
+func Reset(bridge *env.Bridge, id string) error {
+	return update(bridge, func(q *queue) (bool, error) {
+		entry, ok := q.entries[id]
+		if !ok {
+			return false, ErrNotFound
+		}
+		entry.Dead = false
+		entry.NextRetry = time.Time{}
+		return true, nil
+	})
+}
+
+// Purge removes a single entry
+func Purge(bridge *env.Bridge, id string) error {
+	return update(bridge, func(q *queue) (bool, error) {
+		if _, ok := q.entries[id]; !ok {
+			return false, ErrNotFound
+		}
+		delete(q.entries, id)
+		return true, nil
+	})
+}
+
+// PurgeAll removes every dead letter, or every entry when dead is false. Returns the number removed.
+func PurgeAll(bridge *env.Bridge, deadOnly bool) (int, error) {
+	count := 0
+	err := update(bridge, func(q *queue) (bool, error) {
+		for id, entry := range q.entries {
+			if entry.Dead || !deadOnly {
+				delete(q.entries, id)
+				count++
+			}
+		}
+		return count > 0, nil
+	})
+	return count, err
+}
+
+// list returns the entries sorted by ID
+func (q *queue) list() []*Entry {
+	list := []*Entry{}
+	for _, entry := range q.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (q *queue) updateMetrics() {
+	pending, dead := 0, 0
+	for _, entry := range q.entries {
+		if entry.Dead {
+			dead++
+		} else {
+			pending++
+		}
+	}
+	metrics.RetryQueue.Set(float64(pending), q.bridge.Name, "pending")
+	metrics.RetryQueue.Set(float64(dead), q.bridge.Name, "dead")
+}
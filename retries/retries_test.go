@@ -0,0 +1,117 @@
+package retries
+
+import (
+	"distribution-bridge/env/envtest"
+	"distribution-bridge/store"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		delay    time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{delay: 5 * time.Minute, attempts: 1, want: 5 * time.Minute},
+		{delay: 5 * time.Minute, attempts: 2, want: 10 * time.Minute},
+		{delay: 5 * time.Minute, attempts: 4, want: 40 * time.Minute},
+		{delay: 5 * time.Minute, attempts: 7, want: 320 * time.Minute},
+		{delay: 5 * time.Minute, attempts: 8, want: maxDelay},
+		{delay: 5 * time.Minute, attempts: 1000, want: maxDelay},
+		{delay: 4 * time.Hour, attempts: 2, want: maxDelay},
+		{delay: 12 * time.Hour, attempts: 1, want: maxDelay},
+	}
+	for _, test := range tests {
+		if got := backoff(test.delay, test.attempts); got != test.want {
+			t.Errorf("backoff(%s, %d): got %s, expected %s", test.delay, test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestRecordAndResolve(t *testing.T) {
+	q := newQueue(envtest.NewBridge(t, "retries.maxAttempts=3", "retries.baseDelay=5m"), nil)
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	failure := errors.New("error: api error :: 500")
+
+	tests := []struct {
+		attempts     int
+		nextRetry    time.Duration
+		dead         bool
+		deadLettered bool
+	}{
+		{attempts: 1, nextRetry: 5 * time.Minute},
+		{attempts: 2, nextRetry: 10 * time.Minute},
+		// Dead lettered at the max attempts, and only once
+		{attempts: 3, nextRetry: 20 * time.Minute, dead: true, deadLettered: true},
+		{attempts: 4, nextRetry: 40 * time.Minute, dead: true},
+	}
+	for _, test := range tests {
+		entry, deadLettered := q.record(KindProduct, "product_1", failure, now)
+		if entry.Attempts != test.attempts || entry.Dead != test.dead || deadLettered != test.deadLettered {
+			t.Errorf("attempt %d: got %d attempts, dead %t, dead lettered %t", test.attempts, entry.Attempts, entry.Dead, deadLettered)
+		}
+		if !entry.NextRetry.Equal(now.Add(test.nextRetry)) || !entry.FirstFailed.Equal(now) {
+			t.Errorf("attempt %d: got the next retry at %s", test.attempts, entry.NextRetry)
+		}
+	}
+
+	if !q.resolve(KindProduct, "product_1") {
+		t.Error("expected the entry to be resolved")
+	}
+	if q.resolve(KindProduct, "product_1") || q.resolve(KindNewOrder, "product_1") {
+		t.Error("expected nothing to resolve")
+	}
+	if len(q.entries) != 0 {
+		t.Errorf("expected an empty queue, got %v", q.entries)
+	}
+}
+
+func TestChangesFromAnotherProcessAreKept(t *testing.T) {
+	bridge := envtest.NewBridge(t, "retries.maxAttempts=3", "retries.baseDelay=5m")
+	Track(bridge, KindProduct, "product_1", errors.New("error: timeout"))
+	Track(bridge, KindNewOrder, "order_1", errors.New("error: timeout"))
+
+	// The retries command purges an entry while the server keeps tracking results
+	if err := Purge(bridge, EntryID(KindProduct, "product_1")); err != nil {
+		t.Fatal(err)
+	}
+	Track(bridge, KindOrderUpdate, "order_2", errors.New("error: timeout"))
+	Track(bridge, KindNewOrder, "order_1", nil)
+
+	list := List(bridge, false)
+	if len(list) != 1 || list[0].ID != EntryID(KindOrderUpdate, "order_2") {
+		t.Errorf("unexpected entries: %+v", list)
+	}
+	if err := Reset(bridge, EntryID(KindProduct, "product_1")); err != ErrNotFound {
+		t.Errorf("expected the purged entry to be gone, got %v", err)
+	}
+}
+
+func TestTrackOnlyReadsTheQueueWhenItChanged(t *testing.T) {
+	bridge := envtest.NewBridge(t, "retries.maxAttempts=3", "retries.baseDelay=5m")
+
+	// Nothing is queued, the queue isn't even locked
+	Track(bridge, KindProduct, "product_1", nil)
+	if _, err := os.Stat(store.Path(bridge.GetStateDir(), fileName+".lock")); !os.IsNotExist(err) {
+		t.Errorf("expected the queue not to be locked, got %v", err)
+	}
+
+	Track(bridge, KindProduct, "product_1", errors.New("error: timeout"))
+	Track(bridge, KindProduct, "product_2", nil)
+	if queued(bridge, EntryID(KindProduct, "product_2")) || !queued(bridge, EntryID(KindProduct, "product_1")) {
+		t.Error("expected only product_1 to be queued")
+	}
+
+	// An entry queued by another process is seen once the file changes
+	entries := []*Entry{{ID: EntryID(KindNewOrder, "order_1"), Kind: KindNewOrder, EntityID: "order_1", Attempts: 1}}
+	if err := store.Save(bridge.GetStateDir(), fileName, entries); err != nil {
+		t.Fatal(err)
+	}
+	Track(bridge, KindNewOrder, "order_1", nil)
+	if list := List(bridge, false); len(list) != 0 {
+		t.Errorf("expected the entry to be resolved, got %+v", list)
+	}
+}
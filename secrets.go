@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"distribution-bridge/logger"
+	"distribution-bridge/secrets"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const secretsUsage = `Usage:
+  secrets list                   List the names of the secrets in the keyfile (SECRETS_KEYFILE)
+  secrets set <name>             Add or rotate a secret, the value is read from stdin
+  secrets rm <name>              Remove a secret
+
+Use a keyfile secret as a value with keyfile:<name>, ex. SELLER_API_KEY=keyfile:brand-a-seller`
+
+// secretsCommand manages the secrets in the encrypted keyfile. Returns the exit code.
+func secretsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(secretsUsage)
+		return 2
+	}
+	keyfile := secrets.DefaultKeyfile()
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	switch args[0] {
+	case "list":
+		names, err := keyfile.Names()
+		if err != nil {
+			logger.Error("failed to list the secrets", err)
+			return 1
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return 0
+	case "set":
+		if name == "" {
+			break
+		}
+		// Read from stdin so the secret isn't left in the shell history
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		value = strings.TrimSpace(value)
+		if value == "" {
+			logger.Error(fmt.Sprintf("failed to set %q", name), fmt.Errorf("error: no value on stdin: %v", err))
+			return 1
+		}
+		err = keyfile.Set(name, value)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to set %q", name), err)
+			return 1
+		}
+		fmt.Printf("Saved %s, running bridges pick it up within SECRETS_REFRESH_INTERVAL\n", name)
+		return 0
+	case "rm":
+		if name == "" {
+			break
+		}
+		err := keyfile.Delete(name)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to remove %q", name), err)
+			return 1
+		}
+		fmt.Printf("Removed %s\n", name)
+		return 0
+	}
+	fmt.Println(secretsUsage)
+	return 2
+}
@@ -0,0 +1,211 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"distribution-bridge/store"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// keyfileIterations is the PBKDF2 work factor used to turn the passphrase into the encryption key
+const keyfileIterations = 200000
+
+// ErrNoKeyfile is returned when a keyfile secret is used but SECRETS_KEYFILE is not set
+var ErrNoKeyfile = errors.New("error: no keyfile is configured (SECRETS_KEYFILE)")
+
+// Keyfile is a local file of named secrets, encrypted with AES-256-GCM using a key derived from a passphrase.
+// Secrets in it are referenced as keyfile:<name>.
+type Keyfile struct {
+	mu         sync.Mutex
+	path       string
+	passphrase func() (string, error)
+}
+
+// encryptedKeyfile is the keyfile as stored on disk
+type encryptedKeyfile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+var defaultKeyfile = &Keyfile{}
+
+// UseKeyfile sets the keyfile that keyfile:<name> references are read from, and how to get its passphrase
+func UseKeyfile(path string, passphrase func() (string, error)) {
+	defaultKeyfile.mu.Lock()
+	defer defaultKeyfile.mu.Unlock()
+	defaultKeyfile.path = path
+	defaultKeyfile.passphrase = passphrase
+}
+
+// DefaultKeyfile returns the keyfile set with UseKeyfile
+func DefaultKeyfile() *Keyfile {
+	return defaultKeyfile
+}
+
+// Get returns a secret from the keyfile
+func (k *Keyfile) Get(name string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entries, err := k.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := entries[name]
+	if !ok {
+		return "", fmt.Errorf("error: no secret named %q in the keyfile", name)
+	}
+	return secret, nil
+}
+
+// Names returns the names of the secrets in the keyfile, sorted
+func (k *Keyfile) Names() ([]string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entries, err := k.read()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Set adds or replaces (rotates) a secret in the keyfile, creating the keyfile if needed
+func (k *Keyfile) Set(name string, secret string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entries, err := k.read()
+	if err != nil {
+		return err
+	}
+	entries[name] = secret
+	return k.write(entries)
+}
+
+// Delete removes a secret from the keyfile
+func (k *Keyfile) Delete(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entries, err := k.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[name]; !ok {
+		return fmt.Errorf("error: no secret named %q in the keyfile", name)
+	}
+	delete(entries, name)
+	return k.write(entries)
+}
+
+// read decrypts the keyfile. A missing keyfile has no secrets. Caller must hold the lock.
+func (k *Keyfile) read() (map[string]string, error) {
+	if k.path == "" {
+		return nil, ErrNoKeyfile
+	}
+	var stored encryptedKeyfile
+	err := store.Load(filepath.Dir(k.path), filepath.Base(k.path), &stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the keyfile: %w", err)
+	}
+	entries := map[string]string{}
+	if stored.Version == 0 {
+		return entries, nil
+	}
+
+	gcm, err := k.cipher(stored.Salt, stored.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, stored.Nonce, stored.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("error: failed to decrypt the keyfile (wrong passphrase?)")
+	}
+	err = json.Unmarshal(plaintext, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the keyfile: %w", err)
+	}
+	return entries, nil
+}
+
+// write encrypts the secrets with a new salt and nonce. Caller must hold the lock.
+func (k *Keyfile) write(entries map[string]string) error {
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	stored := encryptedKeyfile{Version: 1, Iterations: keyfileIterations, Salt: make([]byte, 16)}
+	_, err = rand.Read(stored.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := k.cipher(stored.Salt, stored.Iterations)
+	if err != nil {
+		return err
+	}
+	stored.Nonce = make([]byte, gcm.NonceSize())
+	_, err = rand.Read(stored.Nonce)
+	if err != nil {
+		return err
+	}
+	stored.Ciphertext = gcm.Seal(nil, stored.Nonce, plaintext, nil)
+	return store.Save(filepath.Dir(k.path), filepath.Base(k.path), stored)
+}
+
+// cipher derives the key from the passphrase and salt. Caller must hold the lock.
+func (k *Keyfile) cipher(salt []byte, iterations int) (cipher.AEAD, error) {
+	if k.passphrase == nil {
+		return nil, errors.New("error: no keyfile passphrase is configured (SECRETS_PASSPHRASE)")
+	}
+	passphrase, err := k.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("error: no keyfile passphrase is configured (SECRETS_PASSPHRASE)")
+	}
+	block, err := aes.NewCipher(pbkdf2([]byte(passphrase), salt, iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key with PBKDF2-HMAC-SHA256 (RFC 8018)
+func pbkdf2(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := []byte{}
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		counter := make([]byte, 4)
+		binary.BigEndian.PutUint32(counter, block)
+		prf.Write(counter)
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(nil)
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
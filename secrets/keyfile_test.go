@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"distribution-bridge/store"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newKeyfile returns a keyfile in a temporary directory with the passphrase
+func newKeyfile(t *testing.T, passphrase string) *Keyfile {
+	dir, err := ioutil.TempDir("", "secrets-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return &Keyfile{path: filepath.Join(dir, "secrets.keyfile"), passphrase: func() (string, error) { return passphrase, nil }}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// The RFC 6070 inputs with HMAC-SHA256, and the RFC 7914 section 11 vectors
+	tests := []struct {
+		password   string
+		salt       string
+		iterations int
+		keyLen     int
+		want       string
+	}{
+		{password: "password", salt: "salt", iterations: 1, keyLen: 32, want: "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{password: "password", salt: "salt", iterations: 2, keyLen: 32, want: "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{password: "password", salt: "salt", iterations: 4096, keyLen: 32, want: "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{password: "passwordPASSWORDpassword", salt: "saltSALTsaltSALTsaltSALTsaltSALTsalt", iterations: 4096, keyLen: 40, want: "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+		{password: "pass\x00word", salt: "sa\x00lt", iterations: 4096, keyLen: 16, want: "89b69d0516f829893c696226650a8687"},
+		{password: "passwd", salt: "salt", iterations: 1, keyLen: 64, want: "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{password: "Password", salt: "NaCl", iterations: 80000, keyLen: 64, want: "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(test.password), []byte(test.salt), test.iterations, test.keyLen))
+		if got != test.want {
+			t.Errorf("pbkdf2(%q, %q, %d, %d): got %s, expected %s", test.password, test.salt, test.iterations, test.keyLen, got, test.want)
+		}
+	}
+}
+
+func TestKeyfileRoundTrip(t *testing.T) {
+	keyfile := newKeyfile(t, "correct horse")
+	if names, err := keyfile.Names(); err != nil || len(names) != 0 {
+		t.Fatalf("expected a missing keyfile to be empty, got %v, %v", names, err)
+	}
+	if err := keyfile.Set("seller", "seller-key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyfile.Set("buyer", "buyer-key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyfile.Set("seller", "rotated-key"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(keyfile.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "rotated-key") || strings.Contains(string(data), "buyer-key") {
+		t.Errorf("expected the secrets to be encrypted, got %s", data)
+	}
+	if secret, err := keyfile.Get("seller"); err != nil || secret != "rotated-key" {
+		t.Errorf("got %q, %v, expected the rotated secret", secret, err)
+	}
+	if err := keyfile.Delete("buyer"); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := keyfile.Names(); err != nil || len(names) != 1 || names[0] != "seller" {
+		t.Errorf("got %v, %v, expected only the seller secret", names, err)
+	}
+	if _, err := keyfile.Get("buyer"); err == nil {
+		t.Error("expected the deleted secret to be gone")
+	}
+}
+
+func TestKeyfileCantBeRead(t *testing.T) {
+	keyfile := newKeyfile(t, "correct horse")
+	if err := keyfile.Set("seller", "seller-key"); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := &Keyfile{path: keyfile.path, passphrase: func() (string, error) { return "battery staple", nil }}
+	if _, err := wrong.Get("seller"); err == nil {
+		t.Error("expected the wrong passphrase to fail")
+	}
+	if err := wrong.Set("buyer", "buyer-key"); err == nil {
+		t.Error("expected a write with the wrong passphrase to fail")
+	}
+
+	var stored encryptedKeyfile
+	dir, name := filepath.Dir(keyfile.path), filepath.Base(keyfile.path)
+	if err := store.Load(dir, name, &stored); err != nil {
+		t.Fatal(err)
+	}
+	stored.Ciphertext[0] ^= 1
+	if err := store.Save(dir, name, stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyfile.Get("seller"); err == nil {
+		t.Error("expected a tampered keyfile to fail")
+	}
+
+	if _, err := (&Keyfile{}).Get("seller"); err != ErrNoKeyfile {
+		t.Errorf("got %v, expected %v", err, ErrNoKeyfile)
+	}
+}
@@ -0,0 +1,29 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// fileProvider reads a secret from a file, ex. a Docker or Kubernetes secret mount (file:/run/secrets/seller_api_key)
+type fileProvider struct{}
+
+func (fileProvider) Get(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// envProvider reads a secret from another environment variable (env:VAULT_SELLER_KEY)
+type envProvider struct{}
+
+func (envProvider) Get(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("error: %s is not set", name)
+	}
+	return value, nil
+}
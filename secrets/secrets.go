@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"distribution-bridge/logger"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider looks up secrets for a reference scheme, ex. the file provider reads file:/run/secrets/seller_api_key.
+// Other backends (ex. a vault) implement Provider and Register it under their own scheme.
+type Provider interface {
+	// Get returns the current value of the secret with the name (the part of the reference after the scheme)
+	Get(name string) (string, error)
+}
+
+type cached struct {
+	value   string
+	fetched time.Time
+}
+
+var (
+	mu              sync.Mutex
+	providers       = map[string]Provider{}
+	cache           = map[string]cached{}
+	refreshInterval = time.Second * 30
+)
+
+func init() {
+	Register("file", fileProvider{})
+	Register("env", envProvider{})
+	Register("keyfile", defaultKeyfile)
+}
+
+// Register adds a provider for references starting with scheme:
+func Register(scheme string, provider Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = provider
+}
+
+// Schemes returns the registered reference schemes, sorted
+func Schemes() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	schemes := []string{}
+	for scheme := range providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// SetRefreshInterval sets how long a looked up secret is used before it is looked up again, so rotated secrets are
+// picked up without a restart
+func SetRefreshInterval(interval time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	refreshInterval = interval
+}
+
+// IsReference is true when the value is a reference to a registered provider (ex. file:/run/secrets/key) rather than
+// the secret itself
+func IsReference(value string) bool {
+	scheme, _, ok := split(value)
+	if !ok {
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok = providers[scheme]
+	return ok
+}
+
+// Resolve returns the secret a value refers to, or the value itself when it isn't a reference. Values are cached for
+// the refresh interval. When a refresh fails the last value is kept, so a rotation in progress doesn't break the sync.
+func Resolve(value string) string {
+	if !IsReference(value) {
+		return value
+	}
+	mu.Lock()
+	entry, ok := cache[value]
+	fresh := ok && time.Since(entry.fetched) < refreshInterval
+	mu.Unlock()
+	if fresh {
+		return entry.value
+	}
+
+	secret, err := Lookup(value)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to look up the secret %s", value), err)
+		return entry.value
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if ok && entry.value != secret {
+		logger.Info(fmt.Sprintf("Secret %s was rotated", value))
+	}
+	cache[value] = cached{value: secret, fetched: time.Now()}
+	return secret
+}
+
+// Lookup gets the current value of a reference from its provider, without the cache. The value is registered with the
+// logger so it is never logged.
+func Lookup(reference string) (string, error) {
+	scheme, name, ok := split(reference)
+	if !ok {
+		return "", fmt.Errorf("error: %q is not a secret reference (scheme:name)", reference)
+	}
+	mu.Lock()
+	provider, ok := providers[scheme]
+	mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("error: no secret provider for %q", scheme)
+	}
+	secret, err := provider.Get(name)
+	if err != nil {
+		return "", err
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("error: the secret %s is empty", reference)
+	}
+	logger.AddSecrets(secret)
+	return secret, nil
+}
+
+// split splits scheme:name
+func split(value string) (string, string, bool) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingProvider returns its value, or its error, and counts the lookups
+type countingProvider struct {
+	value   string
+	err     error
+	lookups int
+}
+
+func (p *countingProvider) Get(string) (string, error) {
+	p.lookups++
+	return p.value, p.err
+}
+
+func TestProviders(t *testing.T) {
+	keyfile := newKeyfile(t, "correct horse")
+	if err := keyfile.Set("seller", "keyfile-secret"); err != nil {
+		t.Fatal(err)
+	}
+	UseKeyfile(keyfile.path, keyfile.passphrase)
+	t.Cleanup(func() { UseKeyfile("", nil) })
+
+	dir := filepath.Dir(keyfile.path)
+	if err := ioutil.WriteFile(filepath.Join(dir, "seller_api_key"), []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty"), []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("SECRETS_TEST_SELLER_KEY", "env-secret")
+	t.Cleanup(func() { os.Unsetenv("SECRETS_TEST_SELLER_KEY") })
+
+	tests := []struct {
+		reference string
+		want      string
+		fails     bool
+	}{
+		{reference: "file:" + filepath.Join(dir, "seller_api_key"), want: "file-secret"},
+		{reference: "file:" + filepath.Join(dir, "missing"), fails: true},
+		{reference: "file:" + filepath.Join(dir, "empty"), fails: true},
+		{reference: "env:SECRETS_TEST_SELLER_KEY", want: "env-secret"},
+		{reference: "env:SECRETS_TEST_MISSING", fails: true},
+		{reference: "keyfile:seller", want: "keyfile-secret"},
+		{reference: "keyfile:buyer", fails: true},
+		{reference: "vault:seller", fails: true},
+		{reference: "sk_live_1234", fails: true},
+	}
+	for _, test := range tests {
+		got, err := Lookup(test.reference)
+		if test.fails && err == nil {
+			t.Errorf("%s: expected an error, got %q", test.reference, got)
+		}
+		if !test.fails && (err != nil || got != test.want) {
+			t.Errorf("%s: got %q, %v, expected %q", test.reference, got, err, test.want)
+		}
+	}
+
+	if IsReference("sk_live_1234") || IsReference("vault:seller") || !IsReference("env:SECRETS_TEST_SELLER_KEY") {
+		t.Error("expected only values of a registered scheme to be references")
+	}
+	if got := Resolve("sk_live_1234"); got != "sk_live_1234" {
+		t.Errorf("expected a value that isn't a reference to be used as is, got %q", got)
+	}
+}
+
+func TestResolveCachesUntilTheRefresh(t *testing.T) {
+	provider := &countingProvider{value: "seller-key-1"}
+	Register("counting", provider)
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(providers, "counting")
+		mu.Unlock()
+		SetRefreshInterval(30 * time.Second)
+	})
+
+	// Looked up once within the refresh interval
+	for i := 0; i < 3; i++ {
+		if got := Resolve("counting:seller"); got != "seller-key-1" {
+			t.Errorf("got %q, expected the first value", got)
+		}
+	}
+	provider.value = "seller-key-2"
+	if got := Resolve("counting:seller"); got != "seller-key-1" || provider.lookups != 1 {
+		t.Errorf("got %q after %d lookups, expected the cached value", got, provider.lookups)
+	}
+
+	// The rotated value is picked up after it, and kept when a refresh fails
+	SetRefreshInterval(0)
+	if got := Resolve("counting:seller"); got != "seller-key-2" {
+		t.Errorf("got %q, expected the rotated value", got)
+	}
+	provider.err = errors.New("error: vault is sealed")
+	if got := Resolve("counting:seller"); got != "seller-key-2" || provider.lookups != 3 {
+		t.Errorf("got %q after %d lookups, expected the last value", got, provider.lookups)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/http"
+	"distribution-bridge/logger"
+	"distribution-bridge/status"
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"sync"
+	"time"
+)
+
+// Check is the result of a single readiness check
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// authCacheTTL stops frequent readiness probes from hammering the API
+const authCacheTTL = time.Second * 30
+
+type authResult struct {
+	checked time.Time
+	checks  []Check
+}
+
+var (
+	authMu      sync.Mutex
+	authResults = map[string]authResult{}
+)
+
+// healthz reports that the process is alive
+func healthz(w nethttp.ResponseWriter, r *nethttp.Request) {
+	writeJSON(w, nethttp.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether the config is valid, every bridge's API keys authenticate and every job has succeeded
+// recently
+func readyz(w nethttp.ResponseWriter, r *nethttp.Request) {
+	checks := []Check{}
+	for _, bridge := range env.Bridges() {
+		configCheck := Check{Name: bridge.Label("config"), OK: bridge.Valid()}
+		checks = append(checks, configCheck)
+		if configCheck.OK {
+			checks = append(checks, apiKeyChecks(bridge)...)
+		}
+	}
+	checks = append(checks, jobChecks(time.Now())...)
+
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+	code := nethttp.StatusOK
+	if !ready {
+		code = nethttp.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]interface{}{"ready": ready, "checks": checks})
+}
+
+// statusHandler summarizes each sync job's last result, counts and last error
+func statusHandler(w nethttp.ResponseWriter, r *nethttp.Request) {
+	writeJSON(w, nethttp.StatusOK, map[string]interface{}{
+		"startedAt": status.StartedAt(),
+		"jobs":      status.All(),
+	})
+}
+
+// apiKeyChecks confirms both of the bridge's API keys authenticate, using the same client as the sync jobs
+func apiKeyChecks(bridge *env.Bridge) []Check {
+	authMu.Lock()
+	defer authMu.Unlock()
+	result, ok := authResults[bridge.Name]
+	if ok && time.Since(result.checked) < authCacheTTL {
+		return result.checks
+	}
+
+	result = authResult{checked: time.Now(), checks: []Check{
+		apiKeyCheck(bridge.Label("seller_api_key"), bridge.GetSellerAPIKey()),
+		apiKeyCheck(bridge.Label("buyer_api_key"), bridge.GetBuyerAPIKey()),
+	}}
+	authResults[bridge.Name] = result
+	return result.checks
+}
+
+func apiKeyCheck(name string, apiKey string) Check {
+	err := http.CheckAuth(apiKey)
+	if err != nil {
+		return Check{Name: name, OK: false, Detail: logger.Redact(err.Error())}
+	}
+	return Check{Name: name, OK: true}
+}
+
+// jobChecks confirms each scheduled job succeeded within its bridge's threshold. Jobs that haven't run yet get the
+// threshold from startup.
+func jobChecks(now time.Time) []Check {
+	checks := []Check{}
+	for _, job := range status.All() {
+		if !job.Scheduled {
+			continue
+		}
+		bridge, err := env.GetBridge(job.Bridge)
+		if err != nil {
+			continue
+		}
+		threshold := bridge.GetReadyMaxSyncAge()
+		check := Check{Name: bridge.Label(fmt.Sprintf("job_%s", job.Job)), OK: true}
+		if job.LastSuccess != nil {
+			age := now.Sub(*job.LastSuccess)
+			if age > threshold {
+				check.OK = false
+				check.Detail = fmt.Sprintf("last success %s ago (threshold %s)", age.Round(time.Second), threshold)
+			}
+		} else if now.Sub(status.StartedAt()) > threshold {
+			check.OK = false
+			check.Detail = fmt.Sprintf("no successful run since startup (threshold %s)", threshold)
+		} else {
+			check.Detail = "waiting for the first successful run"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func writeJSON(w nethttp.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
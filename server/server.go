@@ -0,0 +1,26 @@
+package server
+
+import (
+	"distribution-bridge/metrics"
+	"net/http"
+	"time"
+)
+
+// New returns the HTTP server used in serve mode. The handlers given (ex. webhook receivers) are served on their path.
+func New(addr string, handlers map[string]http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.DefaultRegistry.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz)
+	mux.HandleFunc("/status", statusHandler)
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+
+	return &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  time.Second * 10,
+		WriteTimeout: time.Second * 30,
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/sla"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const slaUsage = `Usage:
+  sla report [--json]            List the supplier orders past their ship-by deadline and each supplier's on-time record`
+
+// slaCommand prints a bridge's late supplier orders and supplier on-time records. Returns the exit code.
+func slaCommand(args []string, bridges []*env.Bridge) int {
+	if len(args) == 0 || args[0] != "report" || len(args) > 2 || (len(args) == 2 && args[1] != "--json") {
+		fmt.Println(slaUsage)
+		return 2
+	}
+	if len(bridges) != 1 {
+		logger.Info("Several bridges are configured, choose one with --bridge <name>")
+		return 2
+	}
+	report, err := sla.GetReport(bridges[0])
+	if err != nil {
+		logger.Error("failed to get the SLA report", err)
+		return 1
+	}
+	if len(args) == 2 {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+	printLateOrders(report.Late)
+	fmt.Println()
+	printSupplierRecords(report.Suppliers)
+	return 0
+}
+
+func printLateOrders(late []sla.LateOrder) {
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER\tCODE\tSUPPLIER\tCREATED\tDEADLINE\tLATE BY")
+	for _, order := range late {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", order.OrderID, order.OrderCode, order.Supplier, order.Created.Format(time.RFC3339),
+			order.Deadline.Format(time.RFC3339), now.Sub(order.Deadline).Round(time.Minute))
+	}
+	w.Flush()
+}
+
+func printSupplierRecords(suppliers []sla.Supplier) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SUPPLIER\tSHIPPED\tON TIME\tLATE\tON TIME %\tAVG HOURS\tLAST SHIPPED")
+	for _, supplier := range suppliers {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%.1f\t%s\n", supplier.Supplier, supplier.Shipped, supplier.OnTime, supplier.Late,
+			supplier.OnTimeRate()*100, supplier.AverageFillHours(), supplier.LastShipped.Format(time.RFC3339))
+	}
+	w.Flush()
+}
@@ -0,0 +1,275 @@
+// Package sla tracks how long the suppliers take to ship the distributor's orders: the orders past their ship-by
+// deadline that haven't shipped, and each supplier's on-time record for vendor reviews.
+package sla
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/metrics"
+	"distribution-bridge/store"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const fileName = "sla.json"
+
+// Shipment results
+const (
+	ResultOnTime = "on_time"
+	ResultLate   = "late"
+)
+
+// LateOrder is a supplier order (buyer account) that wasn't shipped by its deadline
+type LateOrder struct {
+	OrderID   string    `json:"orderId"` // Buyer account order ID
+	OrderCode string    `json:"orderCode"`
+	Supplier  string    `json:"supplier"` // Supplier company ID
+	Created   time.Time `json:"created"`
+	Deadline  time.Time `json:"deadline"`
+	Detected  time.Time `json:"detected"`
+}
+
+// Supplier is a supplier's on-time record
+type Supplier struct {
+	Supplier string `json:"supplier"`
+	Shipped  int    `json:"shipped"`
+	OnTime   int    `json:"onTime"`
+	Late     int    `json:"late"` // Shipped after the deadline
+	// FillHours is the total hours the shipped orders took, for the average
+	FillHours   float64   `json:"fillHours"`
+	LastShipped time.Time `json:"lastShipped"`
+}
+
+// OnTimeRate returns the share of the orders with a deadline that shipped by it, 0 when there are none
+func (s Supplier) OnTimeRate() float64 {
+	if s.OnTime+s.Late == 0 {
+		return 0
+	}
+	return float64(s.OnTime) / float64(s.OnTime+s.Late)
+}
+
+// AverageFillHours returns how long the supplier takes to ship an order on average
+func (s Supplier) AverageFillHours() float64 {
+	if s.Shipped == 0 {
+		return 0
+	}
+	return s.FillHours / float64(s.Shipped)
+}
+
+// Shipment is a supplier order that shipped
+type Shipment struct {
+	OrderID  string
+	Supplier string
+	Created  time.Time
+	Shipped  time.Time
+	// FillHours is how long the supplier took to ship according to the platform, 0 to use Shipped - Created
+	FillHours float64
+	// PromisedHours is the supplier's ship time on the platform, 0 when it has none
+	PromisedHours float64
+}
+
+// Report is a bridge's late orders, the most overdue first, and supplier records by supplier
+type Report struct {
+	Late      []LateOrder `json:"late"`
+	Suppliers []Supplier  `json:"suppliers"`
+}
+
+// state is a bridge's SLA tracking, kept in the bridge's state directory. It is read again for every operation so a
+// change made by another process (ex. the sla command while serve is running) is never overwritten.
+type state struct {
+	bridge    *env.Bridge
+	late      map[string]*LateOrder
+	suppliers map[string]*Supplier
+}
+
+// file is how the state is written to disk
+type file struct {
+	Late      []*LateOrder `json:"late"`
+	Suppliers []*Supplier  `json:"suppliers"`
+}
+
+func newState(bridge *env.Bridge, saved file) *state {
+	s := &state{bridge: bridge, late: map[string]*LateOrder{}, suppliers: map[string]*Supplier{}}
+	for _, order := range saved.Late {
+		s.late[order.OrderID] = order
+	}
+	for _, supplier := range saved.Suppliers {
+		s.suppliers[supplier.Supplier] = supplier
+	}
+	return s
+}
+
+// read returns the bridge's state as it is on disk
+func read(bridge *env.Bridge) (*state, error) {
+	saved := file{}
+	err := store.Read(bridge.GetStateDir(), fileName, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the SLA tracking of %s: %w", bridge.Name, err)
+	}
+	s := newState(bridge, saved)
+	metrics.LateOrders.Set(float64(len(s.late)), bridge.Name)
+	return s, nil
+}
+
+// update reads the bridge's state, applies the change and saves the state when the change returns true, holding the
+// state's lock throughout
+func update(bridge *env.Bridge, change func(s *state) bool) error {
+	saved := file{}
+	var s *state
+	err := store.Update(bridge.GetStateDir(), fileName, &saved, func() (bool, error) {
+		s = newState(bridge, saved)
+		changed := change(s)
+		if changed {
+			saved = s.file()
+		}
+		return changed, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the SLA tracking of %s: %w", bridge.Name, err)
+	}
+	metrics.LateOrders.Set(float64(len(s.late)), bridge.Name)
+	return nil
+}
+
+// Expected returns how long a supplier is expected to take to ship an order: the bridge's SLA for the supplier, then
+// the supplier's ship time on the platform (in hours), then the bridge's default. False when there is none.
+func Expected(bridge *env.Bridge, supplier string, promisedHours float64) (time.Duration, bool) {
+	if expected, ok := bridge.GetSupplierSLAs()[supplier]; ok {
+		return expected, true
+	}
+	if promisedHours > 0 {
+		return time.Duration(promisedHours * float64(time.Hour)), true
+	}
+	if expected := bridge.GetSLAShipWithin(); expected > 0 {
+		return expected, true
+	}
+	return 0, false
+}
+
+// Deadline returns when an order placed with the supplier should ship by. False when the supplier has no SLA.
+func Deadline(bridge *env.Bridge, supplier string, created time.Time, promisedHours float64) (time.Time, bool) {
+	expected, ok := Expected(bridge, supplier, promisedHours)
+	if !ok || created.IsZero() {
+		return time.Time{}, false
+	}
+	return created.Add(expected), true
+}
+
+// MarkLate records an order past its deadline. Returns true the first time, so it is only alerted on once.
+func MarkLate(bridge *env.Bridge, order LateOrder) (bool, error) {
+	isNew := false
+	err := update(bridge, func(s *state) bool {
+		if existing, ok := s.late[order.OrderID]; ok {
+			if existing.Deadline.Equal(order.Deadline) {
+				return false
+			}
+			// The deadline changes with the SLA settings
+			existing.Deadline = order.Deadline
+			return true
+		}
+		order.Detected = time.Now()
+		s.late[order.OrderID] = &order
+		isNew = true
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+	return isNew, nil
+}
+
+// Prune forgets the late orders that aren't open anymore (ex. cancelled), after a sweep of every open order
+func Prune(bridge *env.Bridge, open map[string]bool) error {
+	return update(bridge, func(s *state) bool {
+		pruned := false
+		for id := range s.late {
+			if !open[id] {
+				delete(s.late, id)
+				pruned = true
+			}
+		}
+		return pruned
+	})
+}
+
+// RecordShipment adds a shipped order to its supplier's record and stops tracking it as late. Returns ResultOnTime or
+// ResultLate, or empty when the supplier has no SLA.
+func RecordShipment(bridge *env.Bridge, shipment Shipment) (string, error) {
+	if shipment.Shipped.IsZero() {
+		shipment.Shipped = time.Now()
+	}
+	hours := shipment.FillHours
+	if hours <= 0 && !shipment.Created.IsZero() {
+		hours = shipment.Shipped.Sub(shipment.Created).Hours()
+	}
+	result := ""
+	if expected, ok := Expected(bridge, shipment.Supplier, shipment.PromisedHours); ok && (shipment.FillHours > 0 || !shipment.Created.IsZero()) {
+		result = ResultOnTime
+		if hours > expected.Hours() {
+			result = ResultLate
+		}
+	}
+
+	err := update(bridge, func(s *state) bool {
+		delete(s.late, shipment.OrderID)
+		supplier, ok := s.suppliers[shipment.Supplier]
+		if !ok {
+			supplier = &Supplier{Supplier: shipment.Supplier}
+			s.suppliers[shipment.Supplier] = supplier
+		}
+		supplier.Shipped++
+		supplier.FillHours += hours
+		supplier.LastShipped = shipment.Shipped
+		switch result {
+		case ResultOnTime:
+			supplier.OnTime++
+		case ResultLate:
+			supplier.Late++
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	if result != "" {
+		metrics.SupplierShipments.Inc(bridge.Name, shipment.Supplier, result)
+	}
+	return result, nil
+}
+
+// GetReport returns the bridge's late orders and supplier records
+func GetReport(bridge *env.Bridge) (Report, error) {
+	s, err := read(bridge)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{Late: []LateOrder{}, Suppliers: []Supplier{}}
+	for _, order := range s.late {
+		report.Late = append(report.Late, *order)
+	}
+	sort.Slice(report.Late, func(i, j int) bool {
+		if !report.Late[i].Deadline.Equal(report.Late[j].Deadline) {
+			return report.Late[i].Deadline.Before(report.Late[j].Deadline)
+		}
+		return report.Late[i].OrderID < report.Late[j].OrderID
+	})
+	for _, supplier := range s.suppliers {
+		report.Suppliers = append(report.Suppliers, *supplier)
+	}
+	sort.Slice(report.Suppliers, func(i, j int) bool { return report.Suppliers[i].Supplier < report.Suppliers[j].Supplier })
+	return report, nil
+}
+
+// file returns the state as it is written to disk, sorted
+func (s *state) file() file {
+	saved := file{Late: []*LateOrder{}, Suppliers: []*Supplier{}}
+	for _, order := range s.late {
+		saved.Late = append(saved.Late, order)
+	}
+	sort.Slice(saved.Late, func(i, j int) bool { return saved.Late[i].OrderID < saved.Late[j].OrderID })
+	for _, supplier := range s.suppliers {
+		saved.Suppliers = append(saved.Suppliers, supplier)
+	}
+	sort.Slice(saved.Suppliers, func(i, j int) bool { return saved.Suppliers[i].Supplier < saved.Suppliers[j].Supplier })
+	return saved
+}
@@ -0,0 +1,79 @@
+package sla
+
+import (
+	"distribution-bridge/env/envtest"
+	"distribution-bridge/store"
+	"testing"
+	"time"
+)
+
+func TestRecordShipment(t *testing.T) {
+	bridge := envtest.NewBridge(t, "sla.shipWithin=24h", "sla.suppliers=slow=72h")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		shipment Shipment
+		want     string
+	}{
+		{Shipment{OrderID: "1", Supplier: "fast", Created: created, Shipped: created.Add(20 * time.Hour)}, ResultOnTime},
+		{Shipment{OrderID: "2", Supplier: "fast", Created: created, Shipped: created.Add(30 * time.Hour)}, ResultLate},
+		// The platform's fill time is used over the dates
+		{Shipment{OrderID: "3", Supplier: "fast", Created: created, Shipped: created.Add(30 * time.Hour), FillHours: 10}, ResultOnTime},
+		{Shipment{OrderID: "4", Supplier: "slow", Created: created, Shipped: created.Add(30 * time.Hour)}, ResultOnTime},
+		{Shipment{OrderID: "5", Supplier: "promised", Created: created, Shipped: created.Add(30 * time.Hour), PromisedHours: 36}, ResultOnTime},
+	}
+	for _, test := range tests {
+		result, err := RecordShipment(bridge, test.shipment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.want {
+			t.Errorf("order %s: result = %q, expected %q", test.shipment.OrderID, result, test.want)
+		}
+	}
+
+	report, err := GetReport(bridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast := report.Suppliers[0]
+	if fast.Supplier != "fast" || fast.Shipped != 3 || fast.OnTime != 2 || fast.Late != 1 || fast.AverageFillHours() != 20 {
+		t.Errorf("unexpected record: %+v", fast)
+	}
+
+	// Records are kept on disk
+	saved := file{}
+	if err := store.Load(bridge.GetStateDir(), fileName, &saved); err != nil || len(saved.Suppliers) != 3 {
+		t.Errorf("unexpected records on disk: %+v (%v)", saved.Suppliers, err)
+	}
+}
+
+func TestChangesFromAnotherProcessAreKept(t *testing.T) {
+	bridge := envtest.NewBridge(t, "sla.shipWithin=24h")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, id := range []string{"1", "2"} {
+		if _, err := MarkLate(bridge, LateOrder{OrderID: id, Supplier: "fast", Created: created, Deadline: created.Add(24 * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another process records a shipment while serve keeps tracking late orders
+	saved := file{}
+	if err := store.Load(bridge.GetStateDir(), fileName, &saved); err != nil {
+		t.Fatal(err)
+	}
+	saved.Suppliers = append(saved.Suppliers, &Supplier{Supplier: "slow", Shipped: 1, Late: 1})
+	if err := store.Save(bridge.GetStateDir(), fileName, saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordShipment(bridge, Shipment{OrderID: "1", Supplier: "fast", Created: created, Shipped: created.Add(30 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := GetReport(bridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Late) != 1 || report.Late[0].OrderID != "2" || len(report.Suppliers) != 2 || report.Suppliers[1].Late != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,173 @@
+package status
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/http"
+	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/notify"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Results of a job run
+const (
+	ResultRunning   = "running"
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+)
+
+// JobStatus summarizes the last run of a bridge's sync job
+type JobStatus struct {
+	Bridge       string         `json:"bridge"`
+	Job          string         `json:"job"`
+	Scheduled    bool           `json:"scheduled"`
+	LastResult   string         `json:"lastResult,omitempty"`
+	LastStarted  *time.Time     `json:"lastStarted,omitempty"`
+	LastFinished *time.Time     `json:"lastFinished,omitempty"`
+	LastSuccess  *time.Time     `json:"lastSuccess,omitempty"`
+	Counts       map[string]int `json:"counts"`
+	Errors       int            `json:"errors"`
+	LastError    string         `json:"lastError,omitempty"`
+	LastErrorAt  *time.Time     `json:"lastErrorAt,omitempty"`
+	Summary      Summary        `json:"summary"`
+}
+
+var (
+	mu        sync.Mutex
+	jobs      = map[string]*JobStatus{}
+	startedAt = time.Now()
+)
+
+// StartedAt returns when the process started
+func StartedAt() time.Time {
+	return startedAt
+}
+
+// Register adds a bridge's job that runs on a schedule, so it shows up (and counts towards readiness) before its first
+// run
+func Register(bridge *env.Bridge, job string) {
+	mu.Lock()
+	defer mu.Unlock()
+	get(bridge.Name, job).Scheduled = true
+}
+
+// get returns the status for a bridge's job, creating it if needed. Caller must hold the lock.
+func get(bridge string, job string) *JobStatus {
+	key := bridge + "/" + job
+	jobStatus, ok := jobs[key]
+	if !ok {
+		jobStatus = &JobStatus{Bridge: bridge, Job: job, Counts: map[string]int{}}
+		jobs[key] = jobStatus
+	}
+	return jobStatus
+}
+
+// All returns a copy of every job's status, sorted by bridge and job name
+func All() []JobStatus {
+	mu.Lock()
+	defer mu.Unlock()
+	all := []JobStatus{}
+	for _, jobStatus := range jobs {
+		copied := *jobStatus
+		copied.Summary = jobStatus.Summary.copy()
+		copied.Counts = map[string]int{}
+		for key, count := range jobStatus.Counts {
+			copied.Counts[key] = count
+		}
+		all = append(all, copied)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Bridge != all[j].Bridge {
+			return all[i].Bridge < all[j].Bridge
+		}
+		return all[i].Job < all[j].Job
+	})
+	return all
+}
+
+// Run tracks a single run of a bridge's sync job. The entities in the run are synced using the bridge's accounts and
+// rules.
+type Run struct {
+	Bridge  *env.Bridge
+	job     string
+	started time.Time
+}
+
+// Start marks a bridge's job as running and resets its counts
+func Start(bridge *env.Bridge, job string) *Run {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	jobStatus := get(bridge.Name, job)
+	jobStatus.LastResult = ResultRunning
+	jobStatus.LastStarted = &now
+	jobStatus.Counts = map[string]int{}
+	jobStatus.Errors = 0
+	jobStatus.Summary = Summary{Job: bridge.Label(job)}
+	return &Run{Bridge: bridge, job: job, started: now}
+}
+
+// Count adds one to a named count (ex. created, updated) for the run
+func (r *Run) Count(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+	r.status().Counts[key]++
+}
+
+// Error logs an error, records it as the job's last error and counts it by class
+func (r *Run) Error(msg string, err error) {
+	logger.Error(msg, err)
+	class := http.ErrorClass(err)
+	metrics.Errors.Inc(r.Bridge.Name, class)
+	if class == "auth" {
+		notify.Send(r.Bridge, notify.Event{
+			Kind:    notify.EventAuthFailure,
+			Key:     r.job,
+			Subject: fmt.Sprintf("The API rejected an API key of %s", r.Bridge.Label(r.job)),
+			Details: map[string]string{"job": r.job, "error": logger.Redact(msg + " :: " + err.Error())},
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	jobStatus := r.status()
+	jobStatus.Errors++
+	jobStatus.LastError = logger.Redact(msg + " :: " + err.Error())
+	jobStatus.LastErrorAt = &now
+}
+
+// Finish records the result and duration of the run, logs the summary and returns it. A run that could not finish
+// (ex. a page failed to load) or was aborted is not a success, individual entities failing within the budget are.
+func (r *Run) Finish(finished bool) Summary {
+	mu.Lock()
+	now := time.Now()
+	jobStatus := r.status()
+	if !finished && !jobStatus.Summary.Cancelled {
+		// Make sure a run that didn't finish never looks OK
+		jobStatus.Summary.Aborted = true
+	}
+	summary := jobStatus.Summary.copy()
+	succeeded := finished && !summary.Aborted
+	jobStatus.LastFinished = &now
+	if succeeded {
+		jobStatus.LastResult = ResultSucceeded
+		jobStatus.LastSuccess = &now
+	} else {
+		jobStatus.LastResult = ResultFailed
+	}
+	mu.Unlock()
+
+	metrics.ObserveRun(r.Bridge.Name, r.job, r.started, succeeded)
+	logger.Info(summary.String())
+	notify.FlushDue(r.Bridge)
+	return summary
+}
+
+// status returns the run's job status. Caller must hold the lock.
+func (r *Run) status() *JobStatus {
+	return get(r.Bridge.Name, r.job)
+}
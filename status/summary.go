@@ -0,0 +1,163 @@
+package status
+
+import (
+	"context"
+	"distribution-bridge/logger"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Outcomes of a single entity in a run
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeSkipped   = "skipped"
+)
+
+// maxOutcomes caps how many failed/skipped entities a summary keeps the reasons for
+const maxOutcomes = 10000
+
+// maxPrintedOutcomes caps how many failed/skipped entities are logged with the summary
+const maxPrintedOutcomes = 100
+
+// Outcome is a failed or skipped entity and the reason
+type Outcome struct {
+	Entity string `json:"entity"`
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+}
+
+// Summary counts what happened to each entity in a run
+type Summary struct {
+	Job       string    `json:"job"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Aborted   bool      `json:"aborted"`
+	Cancelled bool      `json:"cancelled"`
+	Outcomes  []Outcome `json:"outcomes"`
+}
+
+// OK is true when the run finished and nothing failed
+func (s Summary) OK() bool {
+	return s.Failed == 0 && !s.Aborted && !s.Cancelled
+}
+
+// String formats the summary and the reasons for each failed or skipped entity
+func (s Summary) String() string {
+	str := fmt.Sprintf("%s :: %d succeeded, %d failed, %d skipped", s.Job, s.Succeeded, s.Failed, s.Skipped)
+	if s.Cancelled {
+		str += " (cancelled)"
+	} else if s.Aborted {
+		str += " (aborted)"
+	}
+	for i, outcome := range s.Outcomes {
+		if i == maxPrintedOutcomes {
+			str += fmt.Sprintf("\n  ... and %d more", len(s.Outcomes)-maxPrintedOutcomes)
+			break
+		}
+		str += fmt.Sprintf("\n  %s %s :: %s", outcome.Result, outcome.Entity, outcome.Reason)
+	}
+	return str
+}
+
+// copy returns a copy with the outcomes sorted (failures first, then by entity) so reports don't depend on which
+// worker finished first
+func (s Summary) copy() Summary {
+	s.Outcomes = append([]Outcome{}, s.Outcomes...)
+	sort.Slice(s.Outcomes, func(i, j int) bool {
+		if s.Outcomes[i].Result != s.Outcomes[j].Result {
+			return s.Outcomes[i].Result == OutcomeFailed
+		}
+		return s.Outcomes[i].Entity < s.Outcomes[j].Entity
+	})
+	return s
+}
+
+// SkipError marks an entity that was intentionally not synced (ex. unchanged), it is not a failure
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return e.Reason
+}
+
+// Skip returns an error that marks the entity as skipped
+func Skip(reason string) error {
+	return &SkipError{Reason: reason}
+}
+
+// Outcome records what happened to an entity: nil succeeded, a SkipError skipped, anything else failed.
+// Only failures are returned.
+func (r *Run) Outcome(entity string, err error) error {
+	var skipErr *SkipError
+	if err != nil && errors.As(err, &skipErr) {
+		r.add(entity, OutcomeSkipped, skipErr.Reason)
+		return nil
+	}
+	if err != nil {
+		r.add(entity, OutcomeFailed, logger.Redact(err.Error()))
+		return err
+	}
+	r.add(entity, OutcomeSucceeded, "")
+	return nil
+}
+
+func (r *Run) add(entity string, result string, reason string) {
+	mu.Lock()
+	defer mu.Unlock()
+	summary := &r.status().Summary
+	switch result {
+	case OutcomeSucceeded:
+		summary.Succeeded++
+		return
+	case OutcomeFailed:
+		summary.Failed++
+	case OutcomeSkipped:
+		summary.Skipped++
+	}
+	if len(summary.Outcomes) < maxOutcomes {
+		summary.Outcomes = append(summary.Outcomes, Outcome{Entity: entity, Result: result, Reason: reason})
+	}
+}
+
+// ErrOverBudget stops a run once more entities failed than the error budget allows
+var ErrOverBudget = errors.New("error: the error budget was exceeded")
+
+// OverBudget is true once the run has more failures than the error budget allows. The run is marked as aborted.
+func (r *Run) OverBudget() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	summary := &r.status().Summary
+	if summary.Failed > r.Bridge.GetSyncErrorBudget() {
+		summary.Aborted = true
+	}
+	return summary.Aborted
+}
+
+// Cancel marks the run as cancelled (ex. the process is shutting down)
+func (r *Run) Cancel() {
+	mu.Lock()
+	defer mu.Unlock()
+	r.status().Summary.Cancelled = true
+}
+
+// FinishWith finishes the run using the error that stopped it early, if any
+func (r *Run) FinishWith(err error) Summary {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		logger.Info(fmt.Sprintf("Cancelled %s, in progress entities were finished", r.Bridge.Label(r.job)))
+		r.Cancel()
+	} else if errors.Is(err, ErrOverBudget) {
+		logger.Info(fmt.Sprintf("Too many failures, aborting %s", r.Bridge.Label(r.job)))
+	}
+	return r.Finish(err == nil)
+}
+
+// Summary returns the summary of the run so far
+func (r *Run) Summary() Summary {
+	mu.Lock()
+	defer mu.Unlock()
+	return r.status().Summary.copy()
+}
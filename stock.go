@@ -0,0 +1,116 @@
+package main
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/stock"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const stockUsage = `Usage:
+  stock list                     List the distributor's stock of each variant
+  stock set <code> <field>=<value>...
+                                 Set a variant's stock by hand, ex. onHand=12 reorderPoint=4 reorderQuantity=24
+  stock orders                   List the purchase orders placed with the suppliers`
+
+// stockCommand lists and adjusts a bridge's stock and purchase orders in stock mode. Returns the exit code.
+func stockCommand(args []string, bridges []*env.Bridge) int {
+	if len(args) == 0 {
+		fmt.Println(stockUsage)
+		return 2
+	}
+	if len(bridges) != 1 {
+		logger.Info("Several bridges are configured, choose one with --bridge <name>")
+		return 2
+	}
+	bridge := bridges[0]
+
+	switch args[0] {
+	case "list":
+		items, err := stock.List(bridge)
+		if err != nil {
+			logger.Error("failed to list the stock", err)
+			return 1
+		}
+		printStock(bridge, items)
+		return 0
+	case "set":
+		if len(args) < 3 {
+			fmt.Println(stockUsage)
+			return 2
+		}
+		changes := map[string]int{}
+		for _, pair := range args[2:] {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				fmt.Println(stockUsage)
+				return 2
+			}
+			value, err := strconv.Atoi(parts[1])
+			if err != nil {
+				logger.Info(fmt.Sprintf("%s is not a whole number", pair))
+				return 2
+			}
+			changes[parts[0]] = value
+		}
+		data, _ := json.Marshal(changes)
+		item, err := stock.Set(bridge, args[1], data)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to set the stock of %q", args[1]), err)
+			return 1
+		}
+		printStock(bridge, []stock.Item{item})
+		return 0
+	case "orders":
+		purchaseOrders, err := stock.PurchaseOrders(bridge)
+		if err != nil {
+			logger.Error("failed to list the purchase orders", err)
+			return 1
+		}
+		printPurchaseOrders(purchaseOrders)
+		return 0
+	}
+	fmt.Println(stockUsage)
+	return 2
+}
+
+func printStock(bridge *env.Bridge, items []stock.Item) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tON HAND\tRESERVED\tAVAILABLE\tON ORDER\tREORDER POINT\tREORDER QUANTITY")
+	for _, item := range items {
+		point, quantity := bridge.GetReorderPoint(), bridge.GetReorderQuantity()
+		if item.ReorderPoint != nil {
+			point = *item.ReorderPoint
+		}
+		if item.ReorderQuantity != nil {
+			quantity = *item.ReorderQuantity
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", item.Code, item.OnHand, item.Reserved, item.Available(), item.OnOrder, point, quantity)
+	}
+	w.Flush()
+}
+
+func printPurchaseOrders(purchaseOrders []stock.PurchaseOrder) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tSTATUS\tCREATED\tLINES")
+	for _, purchaseOrder := range purchaseOrders {
+		codes := []string{}
+		for code := range purchaseOrder.Lines {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		lines := []string{}
+		for _, code := range codes {
+			lines = append(lines, fmt.Sprintf("%s %d/%d", code, purchaseOrder.Received[code], purchaseOrder.Lines[code]))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", purchaseOrder.Code, purchaseOrder.Status, purchaseOrder.Created.Format(time.RFC3339), truncate(strings.Join(lines, ", "), 80))
+	}
+	w.Flush()
+}
@@ -0,0 +1,485 @@
+// Package stock keeps the distributor's own inventory when retailer orders are shipped from stock instead of being
+// forwarded to the suppliers: what is on hand, reserved for retailer orders and on order from the suppliers, and the
+// purchase orders that replenish it.
+package stock
+
+import (
+	"bytes"
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/metrics"
+	"distribution-bridge/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const fileName = "stock.json"
+
+// Statuses of a purchase order
+const (
+	StatusOpen      = "open"      // Waiting for the suppliers to ship
+	StatusReceived  = "received"  // Every line was shipped by the suppliers
+	StatusCancelled = "cancelled" // Couldn't be placed with the suppliers
+)
+
+var (
+	// ErrNotFound is returned when there is no variant, reservation or purchase order with the code
+	ErrNotFound = errors.New("error: not found in stock")
+	// ErrInvalidChanges is returned when stock changes aren't a JSON object of stock fields
+	ErrInvalidChanges = errors.New("error: invalid stock changes")
+)
+
+// Item is the distributor's stock of a variant
+type Item struct {
+	Code     string `json:"code"` // Seller variant code
+	OnHand   int    `json:"onHand"`
+	Reserved int    `json:"reserved"` // For retailer orders that haven't shipped yet
+	OnOrder  int    `json:"onOrder"`  // On open purchase orders
+	// ReorderPoint and ReorderQuantity override the bridge's STOCK_REORDER_POINT and STOCK_REORDER_QUANTITY
+	ReorderPoint    *int      `json:"reorderPoint,omitempty"`
+	ReorderQuantity *int      `json:"reorderQuantity,omitempty"`
+	Updated         time.Time `json:"updated"`
+}
+
+// Available returns the stock that isn't reserved, negative when more is reserved than is on hand (backordered)
+func (i Item) Available() int {
+	return i.OnHand - i.Reserved
+}
+
+// Changes are the stock fields that can be set by hand, ex. after counting the warehouse
+type Changes struct {
+	OnHand          *int `json:"onHand"`
+	ReorderPoint    *int `json:"reorderPoint"`
+	ReorderQuantity *int `json:"reorderQuantity"`
+}
+
+// Reservation is the stock set aside for a retailer order until it ships
+type Reservation struct {
+	OrderID   string         `json:"orderId"` // Seller account order ID
+	OrderCode string         `json:"orderCode"`
+	Lines     map[string]int `json:"lines"` // Seller variant code -> quantity
+	Created   time.Time      `json:"created"`
+}
+
+// PurchaseOrder is stock ordered from the suppliers, placed as a buyer order in the buyer account
+type PurchaseOrder struct {
+	Code         string         `json:"code"` // The buyer order's reference
+	BuyerOrderID string         `json:"buyerOrderId,omitempty"`
+	Status       string         `json:"status"`
+	Lines        map[string]int `json:"lines"`    // Seller variant code -> quantity ordered
+	Received     map[string]int `json:"received"` // Seller variant code -> quantity shipped by the suppliers
+	// ReceivedFrom are the supplier orders already received, each supplier ships its part of the purchase order
+	ReceivedFrom []string  `json:"receivedFrom"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+}
+
+// state is a bridge's stock, kept in the bridge's state directory. It is read again for every operation so a change
+// made by another process (ex. the stock command while serve is running) is never overwritten.
+type state struct {
+	bridge         *env.Bridge
+	items          map[string]*Item
+	reservations   map[string]*Reservation
+	purchaseOrders map[string]*PurchaseOrder
+	lastNumber     int
+}
+
+// file is how the state is written to disk
+type file struct {
+	Items          []*Item          `json:"items"`
+	Reservations   []*Reservation   `json:"reservations"`
+	PurchaseOrders []*PurchaseOrder `json:"purchaseOrders"`
+	LastNumber     int              `json:"lastPurchaseOrderNumber"`
+}
+
+func newState(bridge *env.Bridge, saved file) *state {
+	s := &state{
+		bridge:         bridge,
+		items:          map[string]*Item{},
+		reservations:   map[string]*Reservation{},
+		purchaseOrders: map[string]*PurchaseOrder{},
+		lastNumber:     saved.LastNumber,
+	}
+	for _, item := range saved.Items {
+		s.items[item.Code] = item
+	}
+	for _, reservation := range saved.Reservations {
+		s.reservations[reservation.OrderID] = reservation
+	}
+	for _, order := range saved.PurchaseOrders {
+		s.purchaseOrders[order.Code] = order
+	}
+	return s
+}
+
+// read returns the bridge's stock as it is on disk
+func read(bridge *env.Bridge) (*state, error) {
+	saved := file{}
+	err := store.Read(bridge.GetStateDir(), fileName, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the stock of %s: %w", bridge.Name, err)
+	}
+	s := newState(bridge, saved)
+	s.updateMetrics()
+	return s, nil
+}
+
+// update reads the bridge's stock, applies the change and saves the stock when the change returns true, holding the
+// stock's lock throughout. An error from the change is returned as is and nothing is saved.
+func update(bridge *env.Bridge, change func(s *state) (bool, error)) error {
+	saved := file{}
+	var s *state
+	var changeErr error
+	err := store.Update(bridge.GetStateDir(), fileName, &saved, func() (bool, error) {
+		s = newState(bridge, saved)
+		changed, err := change(s)
+		if err != nil {
+			changeErr = err
+			return false, err
+		}
+		if changed {
+			saved = s.file()
+		}
+		return changed, nil
+	})
+	if changeErr != nil {
+		return changeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save the stock of %s: %w", bridge.Name, err)
+	}
+	s.updateMetrics()
+	return nil
+}
+
+// List returns the bridge's stock of every variant, by code
+func List(bridge *env.Bridge) ([]Item, error) {
+	s, err := read(bridge)
+	if err != nil {
+		return nil, err
+	}
+	list := []Item{}
+	for _, item := range s.items {
+		list = append(list, *item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list, nil
+}
+
+// Set changes the stock fields of a variant given as a JSON object (ex. {"onHand": 12, "reorderPoint": 4}), adding
+// the variant when it isn't stocked yet
+func Set(bridge *env.Bridge, code string, changes []byte) (Item, error) {
+	var parsed Changes
+	decoder := json.NewDecoder(bytes.NewReader(changes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&parsed)
+	if err != nil {
+		return Item{}, fmt.Errorf("%w: %v", ErrInvalidChanges, err)
+	}
+	for _, value := range []*int{parsed.OnHand, parsed.ReorderPoint, parsed.ReorderQuantity} {
+		if value != nil && *value < 0 {
+			return Item{}, fmt.Errorf("%w: %d is negative", ErrInvalidChanges, *value)
+		}
+	}
+
+	var changed Item
+	err = update(bridge, func(s *state) (bool, error) {
+		item := s.item(code)
+		if parsed.OnHand != nil {
+			item.OnHand = *parsed.OnHand
+		}
+		if parsed.ReorderPoint != nil {
+			item.ReorderPoint = parsed.ReorderPoint
+		}
+		if parsed.ReorderQuantity != nil {
+			item.ReorderQuantity = parsed.ReorderQuantity
+		}
+		item.Updated = time.Now()
+		changed = *item
+		return true, nil
+	})
+	return changed, err
+}
+
+// Reserve sets stock aside for a retailer order, ex. {"V-1": 2}. Stock is reserved even when there isn't enough on hand,
+// the order is backordered until more is received. Returns the codes that are short. An order is only reserved once.
+func Reserve(bridge *env.Bridge, orderID string, orderCode string, lines map[string]int) ([]string, error) {
+	var short []string
+	err := update(bridge, func(s *state) (bool, error) {
+		if _, ok := s.reservations[orderID]; ok {
+			return false, nil
+		}
+		now := time.Now()
+		short = []string{}
+		for code, quantity := range lines {
+			item := s.item(code)
+			item.Reserved += quantity
+			item.Updated = now
+			if item.Available() < 0 {
+				short = append(short, code)
+			}
+		}
+		sort.Strings(short)
+		s.reservations[orderID] = &Reservation{OrderID: orderID, OrderCode: orderCode, Lines: lines, Created: now}
+		logger.Info(fmt.Sprintf("Reserved stock for order %s :: %v", bridge.Label(orderID), lines))
+		return true, nil
+	})
+	return short, err
+}
+
+// Reserved is true when stock is reserved for the retailer order
+func Reserved(bridge *env.Bridge, orderID string) (bool, error) {
+	s, err := read(bridge)
+	if err != nil {
+		return false, err
+	}
+	_, ok := s.reservations[orderID]
+	return ok, nil
+}
+
+// Ship takes the stock reserved for a retailer order off hand once the order has shipped
+func Ship(bridge *env.Bridge, orderID string) (Reservation, error) {
+	var shipped Reservation
+	err := update(bridge, func(s *state) (bool, error) {
+		reservation, ok := s.reservations[orderID]
+		if !ok {
+			return false, ErrNotFound
+		}
+		now := time.Now()
+		for code, quantity := range reservation.Lines {
+			item := s.item(code)
+			item.Reserved -= quantity
+			item.OnHand -= quantity
+			if item.OnHand < 0 {
+				// Shipped more than the bridge knew about, the count was off
+				item.OnHand = 0
+			}
+			item.Updated = now
+		}
+		delete(s.reservations, orderID)
+		shipped = *reservation
+		return true, nil
+	})
+	return shipped, err
+}
+
+// Reorders returns how many of each variant to order from the suppliers: the reorder quantity of every variant whose
+// available and on order stock is at or below its reorder point, or more when that isn't enough to get above it
+func Reorders(bridge *env.Bridge) (map[string]int, error) {
+	s, err := read(bridge)
+	if err != nil {
+		return nil, err
+	}
+	lines := map[string]int{}
+	for code, item := range s.items {
+		point, quantity := bridge.GetReorderPoint(), bridge.GetReorderQuantity()
+		if item.ReorderPoint != nil {
+			point = *item.ReorderPoint
+		}
+		if item.ReorderQuantity != nil {
+			quantity = *item.ReorderQuantity
+		}
+		projected := item.Available() + item.OnOrder
+		if quantity <= 0 || projected > point {
+			continue
+		}
+		if shortfall := point - projected + 1; shortfall > quantity {
+			quantity = shortfall
+		}
+		lines[code] = quantity
+	}
+	return lines, nil
+}
+
+// OpenPurchaseOrder numbers a new purchase order for the lines and counts them as on order, before it is placed with
+// the suppliers
+func OpenPurchaseOrder(bridge *env.Bridge, lines map[string]int) (PurchaseOrder, error) {
+	var opened PurchaseOrder
+	err := update(bridge, func(s *state) (bool, error) {
+		s.lastNumber++
+		code := fmt.Sprintf("PO-%06d", s.lastNumber)
+		if bridge.Named() {
+			code = fmt.Sprintf("PO-%s-%06d", bridge.Name, s.lastNumber)
+		}
+		now := time.Now()
+		for itemCode, quantity := range lines {
+			item := s.item(itemCode)
+			item.OnOrder += quantity
+			item.Updated = now
+		}
+		order := &PurchaseOrder{
+			Code:         code,
+			Status:       StatusOpen,
+			Lines:        lines,
+			Received:     map[string]int{},
+			ReceivedFrom: []string{},
+			Created:      now,
+			Updated:      now,
+		}
+		s.purchaseOrders[code] = order
+		opened = *order
+		return true, nil
+	})
+	return opened, err
+}
+
+// PlacePurchaseOrder records the buyer order a purchase order was placed as
+func PlacePurchaseOrder(bridge *env.Bridge, code string, buyerOrderID string) error {
+	return changePurchaseOrder(bridge, code, func(s *state, order *PurchaseOrder) {
+		order.BuyerOrderID = buyerOrderID
+	})
+}
+
+// CancelPurchaseOrder takes a purchase order that couldn't be placed off order, so the stock is ordered again
+func CancelPurchaseOrder(bridge *env.Bridge, code string) error {
+	return changePurchaseOrder(bridge, code, func(s *state, order *PurchaseOrder) {
+		for itemCode, quantity := range order.Lines {
+			item := s.item(itemCode)
+			item.OnOrder -= quantity - order.Received[itemCode]
+			if item.OnOrder < 0 {
+				item.OnOrder = 0
+			}
+		}
+		order.Status = StatusCancelled
+	})
+}
+
+// Receive adds the lines a supplier shipped for a purchase order to the stock on hand. Each supplier order is only
+// received once. Returns false when it was already received.
+func Receive(bridge *env.Bridge, code string, supplierOrderID string, lines map[string]int) (PurchaseOrder, bool, error) {
+	var received PurchaseOrder
+	isNew := false
+	err := update(bridge, func(s *state) (bool, error) {
+		order, ok := s.purchaseOrders[code]
+		if !ok {
+			return false, ErrNotFound
+		}
+		received = *order
+		for _, id := range order.ReceivedFrom {
+			if id == supplierOrderID {
+				return false, nil
+			}
+		}
+		now := time.Now()
+		for itemCode, quantity := range lines {
+			item := s.item(itemCode)
+			item.OnHand += quantity
+			// Only what is still expected on the purchase order was on order
+			expected := order.Lines[itemCode] - order.Received[itemCode]
+			if expected > quantity {
+				expected = quantity
+			}
+			if expected > 0 {
+				item.OnOrder -= expected
+			}
+			if item.OnOrder < 0 {
+				item.OnOrder = 0
+			}
+			item.Updated = now
+			order.Received[itemCode] += quantity
+		}
+		order.ReceivedFrom = append(order.ReceivedFrom, supplierOrderID)
+		order.Status = StatusReceived
+		for itemCode, quantity := range order.Lines {
+			if order.Received[itemCode] < quantity {
+				order.Status = StatusOpen
+			}
+		}
+		order.Updated = now
+		logger.Info(fmt.Sprintf("Received purchase order %s from %s :: %v", bridge.Label(code), supplierOrderID, lines))
+		received = *order
+		isNew = true
+		return true, nil
+	})
+	if err != nil {
+		return PurchaseOrder{}, false, err
+	}
+	return received, isNew, nil
+}
+
+// GetPurchaseOrder returns one of the bridge's purchase orders
+func GetPurchaseOrder(bridge *env.Bridge, code string) (PurchaseOrder, error) {
+	s, err := read(bridge)
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	order, ok := s.purchaseOrders[code]
+	if !ok {
+		return PurchaseOrder{}, ErrNotFound
+	}
+	return *order, nil
+}
+
+// PurchaseOrders returns the bridge's purchase orders, oldest first
+func PurchaseOrders(bridge *env.Bridge) ([]PurchaseOrder, error) {
+	s, err := read(bridge)
+	if err != nil {
+		return nil, err
+	}
+	list := []PurchaseOrder{}
+	for _, order := range s.purchaseOrders {
+		list = append(list, *order)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list, nil
+}
+
+func changePurchaseOrder(bridge *env.Bridge, code string, change func(s *state, order *PurchaseOrder)) error {
+	return update(bridge, func(s *state) (bool, error) {
+		order, ok := s.purchaseOrders[code]
+		if !ok {
+			return false, ErrNotFound
+		}
+		change(s, order)
+		order.Updated = time.Now()
+		return true, nil
+	})
+}
+
+// item returns the stock of a variant, adding it when it isn't stocked yet
+func (s *state) item(code string) *Item {
+	item, ok := s.items[code]
+	if !ok {
+		item = &Item{Code: code}
+		s.items[code] = item
+	}
+	return item
+}
+
+// file returns the stock as it is written to disk, sorted
+func (s *state) file() file {
+	saved := file{Items: []*Item{}, Reservations: []*Reservation{}, PurchaseOrders: []*PurchaseOrder{}, LastNumber: s.lastNumber}
+	for _, item := range s.items {
+		saved.Items = append(saved.Items, item)
+	}
+	sort.Slice(saved.Items, func(i, j int) bool { return saved.Items[i].Code < saved.Items[j].Code })
+	for _, reservation := range s.reservations {
+		saved.Reservations = append(saved.Reservations, reservation)
+	}
+	sort.Slice(saved.Reservations, func(i, j int) bool { return saved.Reservations[i].OrderID < saved.Reservations[j].OrderID })
+	for _, order := range s.purchaseOrders {
+		saved.PurchaseOrders = append(saved.PurchaseOrders, order)
+	}
+	sort.Slice(saved.PurchaseOrders, func(i, j int) bool { return saved.PurchaseOrders[i].Code < saved.PurchaseOrders[j].Code })
+	return saved
+}
+
+func (s *state) updateMetrics() {
+	backordered := 0
+	for _, item := range s.items {
+		if item.Available() < 0 {
+			backordered++
+		}
+	}
+	open := 0
+	for _, order := range s.purchaseOrders {
+		if order.Status == StatusOpen {
+			open++
+		}
+	}
+	metrics.BackorderedVariants.Set(float64(backordered), s.bridge.Name)
+	metrics.OpenPurchaseOrders.Set(float64(open), s.bridge.Name)
+}
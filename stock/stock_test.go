@@ -0,0 +1,102 @@
+package stock
+
+import (
+	"distribution-bridge/env/envtest"
+	"distribution-bridge/store"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestReorders(t *testing.T) {
+	bridge := envtest.NewBridge(t, "stock.reorderPoint=5", "stock.reorderQuantity=10")
+	for code, changes := range map[string]string{
+		"above":    `{"onHand": 6}`,
+		"at":       `{"onHand": 5}`,
+		"own":      `{"onHand": 1, "reorderPoint": 0, "reorderQuantity": 3}`,
+		"never":    `{"onHand": 0, "reorderQuantity": 0}`,
+		"shortage": `{"onHand": 0}`,
+	} {
+		if _, err := Set(bridge, code, []byte(changes)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 20 backordered, ordering 10 isn't enough to get above the reorder point
+	if _, err := Reserve(bridge, "order_1", "order_1", map[string]int{"shortage": 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := Reorders(bridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]int{"at": 10, "shortage": 26}, lines); diff != "" {
+		t.Errorf("unexpected reorders: %s", diff)
+	}
+	if _, err := Set(bridge, "at", []byte(`{"onHand": -1}`)); err == nil {
+		t.Errorf("negative stock should be rejected")
+	}
+}
+
+func TestReceive(t *testing.T) {
+	bridge := envtest.NewBridge(t)
+	order, err := OpenPurchaseOrder(bridge, map[string]int{"V-1": 4, "V-2": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each supplier ships its part, once
+	for i := 0; i < 2; i++ {
+		order, _, err = Receive(bridge, order.Code, "supplier_order_1", map[string]int{"V-1": 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if order.Status != StatusOpen {
+		t.Errorf("status = %q, expected open until every line is received", order.Status)
+	}
+	order, received, err := Receive(bridge, order.Code, "supplier_order_2", map[string]int{"V-2": 2})
+	if err != nil || !received || order.Status != StatusReceived {
+		t.Fatalf("unexpected purchase order: %+v (%v)", order, err)
+	}
+
+	items, _ := List(bridge)
+	want := []Item{{Code: "V-1", OnHand: 4}, {Code: "V-2", OnHand: 2}}
+	if diff := cmp.Diff(want, items, cmp.Comparer(func(a, b Item) bool {
+		return a.Code == b.Code && a.OnHand == b.OnHand && a.Reserved == b.Reserved && a.OnOrder == b.OnOrder
+	})); diff != "" {
+		t.Errorf("unexpected stock: %s", diff)
+	}
+	if _, _, err := Receive(bridge, "PO-404", "supplier_order_3", nil); err != ErrNotFound {
+		t.Errorf("unknown purchase order: got %v", err)
+	}
+}
+
+func TestChangesFromAnotherProcessAreKept(t *testing.T) {
+	bridge := envtest.NewBridge(t)
+	if _, err := Set(bridge, "V-1", []byte(`{"onHand": 5}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stock command counts the warehouse while serve keeps reserving and ordering stock
+	saved := file{}
+	if err := store.Load(bridge.GetStateDir(), fileName, &saved); err != nil {
+		t.Fatal(err)
+	}
+	saved.Items[0].OnHand = 8
+	saved.LastNumber = 41
+	if err := store.Save(bridge.GetStateDir(), fileName, saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reserve(bridge, "order_1", "order_1", map[string]int{"V-1": 2}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := OpenPurchaseOrder(bridge, map[string]int{"V-1": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, _ := List(bridge)
+	if len(items) != 1 || items[0].OnHand != 8 || items[0].Reserved != 2 || items[0].OnOrder != 3 || order.Code != "PO-000042" {
+		t.Errorf("unexpected stock %+v and purchase order %s", items, order.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	locksMu sync.Mutex
+	locks   = map[string]*sync.Mutex{} // Lock file -> lock within this process
+)
+
+// Read reads a JSON state file into v under the file's lock
+func Read(dir string, name string, v interface{}) error {
+	unlock, err := lock(dir, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return Load(dir, name, v)
+}
+
+// Update reads a JSON state file into v, calls update and writes v back, all under the file's lock. The lock is held
+// across processes (ex. the CLI while serve is running) so neither overwrites a change made by the other. update returns
+// false when it changed nothing, which skips the write, and an error leaves the file as it was.
+func Update(dir string, name string, v interface{}, update func() (bool, error)) error {
+	unlock, err := lock(dir, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	err = Load(dir, name, v)
+	if err != nil {
+		return err
+	}
+	changed, err := update()
+	if err != nil || !changed {
+		return err
+	}
+	return Save(dir, name, v)
+}
+
+// lock takes the lock of a state file, first within this process then on the file's lock file for other processes
+func lock(dir string, name string) (func(), error) {
+	path := Path(dir, name) + ".lock"
+	locksMu.Lock()
+	mu, ok := locks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		locks[path] = mu
+	}
+	locksMu.Unlock()
+	mu.Lock()
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	err = lockFile(file)
+	if err != nil {
+		file.Close()
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+		mu.Unlock()
+	}, nil
+}
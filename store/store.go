@@ -0,0 +1,55 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Path returns the location of a state file inside a state directory (ex. a bridge's)
+func Path(dir string, name string) string {
+	return filepath.Join(dir, name)
+}
+
+// Load reads a JSON state file into v. A missing file leaves v untouched and is not an error.
+func Load(dir string, name string, v interface{}) error {
+	data, err := ioutil.ReadFile(Path(dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save writes v to a JSON state file. The file is replaced atomically so a crash never leaves it half written.
+func Save(dir string, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := Path(dir, name)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
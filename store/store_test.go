@@ -0,0 +1,49 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestUpdateKeepsEveryChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count := 0
+			err := Update(dir, "count.json", &count, func() (bool, error) {
+				count++
+				return true, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	if err := Read(dir, "count.json", &count); err != nil || count != 20 {
+		t.Errorf("got %d (%v), expected 20", count, err)
+	}
+	// Nothing changed, nothing written
+	err = Update(dir, "count.json", &count, func() (bool, error) {
+		count = 0
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(dir, "count.json", &count); err != nil || count != 20 {
+		t.Errorf("got %d (%v), expected 20", count, err)
+	}
+}
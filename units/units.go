@@ -0,0 +1,141 @@
+// Package units has weight and length types that know their unit, so product weights and dimensions can be validated
+// and converted to the units an account prefers.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// WeightUnit is a unit of weight, as the API names it
+type WeightUnit string
+
+// LengthUnit is a unit of length, as the API names it
+type LengthUnit string
+
+const (
+	Grams     WeightUnit = "g"
+	Kilograms WeightUnit = "kg"
+	Ounces    WeightUnit = "oz"
+	Pounds    WeightUnit = "lb"
+
+	Millimeters LengthUnit = "mm"
+	Centimeters LengthUnit = "cm"
+	Meters      LengthUnit = "m"
+	Inches      LengthUnit = "in"
+	Feet        LengthUnit = "ft"
+)
+
+// precision is how many decimal places converted values keep, enough for a gram in kilograms or a millimeter in
+// meters without the float noise of the conversion (ex. 0.45359237000000004)
+const precision = 6
+
+// grams and millimeters are the size of each unit in the smallest unit
+var (
+	grams = map[WeightUnit]float64{
+		Grams:     1,
+		Kilograms: 1000,
+		Ounces:    28.349523125,
+		Pounds:    453.59237,
+	}
+	millimeters = map[LengthUnit]float64{
+		Millimeters: 1,
+		Centimeters: 10,
+		Meters:      1000,
+		Inches:      25.4,
+		Feet:        304.8,
+	}
+)
+
+// aliases are the other ways units have been seen written, lowercased
+var (
+	weightAliases = map[string]WeightUnit{
+		"gram": Grams, "grams": Grams, "gr": Grams,
+		"kilogram": Kilograms, "kilograms": Kilograms, "kgs": Kilograms, "kilo": Kilograms, "kilos": Kilograms,
+		"ounce": Ounces, "ounces": Ounces,
+		"pound": Pounds, "pounds": Pounds, "lbs": Pounds,
+	}
+	lengthAliases = map[string]LengthUnit{
+		"millimeter": Millimeters, "millimeters": Millimeters, "millimetre": Millimeters, "millimetres": Millimeters,
+		"centimeter": Centimeters, "centimeters": Centimeters, "centimetre": Centimeters, "centimetres": Centimeters,
+		"meter": Meters, "meters": Meters, "metre": Meters, "metres": Meters,
+		"inch": Inches, "inches": Inches,
+		"foot": Feet, "feet": Feet,
+	}
+)
+
+// ParseWeightUnit returns the unit for a unit name or alias (ex. "KG", "lbs")
+func ParseWeightUnit(name string) (WeightUnit, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := grams[WeightUnit(name)]; ok {
+		return WeightUnit(name), nil
+	}
+	if unit, ok := weightAliases[name]; ok {
+		return unit, nil
+	}
+	return "", fmt.Errorf("%q is not a weight unit (g, kg, oz or lb)", name)
+}
+
+// ParseLengthUnit returns the unit for a unit name or alias (ex. "CM", "inches")
+func ParseLengthUnit(name string) (LengthUnit, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := millimeters[LengthUnit(name)]; ok {
+		return LengthUnit(name), nil
+	}
+	if unit, ok := lengthAliases[name]; ok {
+		return unit, nil
+	}
+	return "", fmt.Errorf("%q is not a length unit (mm, cm, m, in or ft)", name)
+}
+
+// Weight is a weight in a unit
+type Weight struct {
+	Value float64
+	Unit  WeightUnit
+}
+
+// In returns the weight converted to the unit
+func (w Weight) In(unit WeightUnit) Weight {
+	if w.Unit == unit {
+		return w
+	}
+	return Weight{Value: round(w.Value * grams[w.Unit] / grams[unit]), Unit: unit}
+}
+
+func (w Weight) String() string {
+	return fmt.Sprintf("%v %s", w.Value, w.Unit)
+}
+
+// Dimensions are a length, width and height in a unit
+type Dimensions struct {
+	Length float64
+	Width  float64
+	Height float64
+	Unit   LengthUnit
+}
+
+// In returns the dimensions converted to the unit
+func (d Dimensions) In(unit LengthUnit) Dimensions {
+	if d.Unit == unit {
+		return d
+	}
+	convert := func(value float64) float64 {
+		return round(value * millimeters[d.Unit] / millimeters[unit])
+	}
+	return Dimensions{Length: convert(d.Length), Width: convert(d.Width), Height: convert(d.Height), Unit: unit}
+}
+
+// IsZero is true when no dimension is set, so the unit doesn't matter
+func (d Dimensions) IsZero() bool {
+	return d.Length == 0 && d.Width == 0 && d.Height == 0
+}
+
+func round(value float64) float64 {
+	scaled := value * math.Pow10(precision)
+	if math.IsInf(scaled, 0) {
+		// Too large to have decimal places
+		return value
+	}
+	return math.Round(scaled) / math.Pow10(precision)
+}
@@ -0,0 +1,72 @@
+package units
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+func TestParse(t *testing.T) {
+	weights := map[string]WeightUnit{"kg": Kilograms, " KG ": Kilograms, "lbs": Pounds, "Ounces": Ounces, "g": Grams}
+	for name, want := range weights {
+		if got, err := ParseWeightUnit(name); err != nil || got != want {
+			t.Errorf("%q: got %q (%v), expected %q", name, got, err, want)
+		}
+	}
+	lengths := map[string]LengthUnit{"cm": Centimeters, "Inches": Inches, "metres": Meters, "FT": Feet}
+	for name, want := range lengths {
+		if got, err := ParseLengthUnit(name); err != nil || got != want {
+			t.Errorf("%q: got %q (%v), expected %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "stone", "cm"} {
+		if _, err := ParseWeightUnit(name); err == nil {
+			t.Errorf("%q should not be a weight unit", name)
+		}
+	}
+	for _, name := range []string{"", "kg", "yard"} {
+		if _, err := ParseLengthUnit(name); err == nil {
+			t.Errorf("%q should not be a length unit", name)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	weights := []struct {
+		from Weight
+		to   WeightUnit
+		want Weight
+	}{
+		{from: Weight{1, Pounds}, to: Kilograms, want: Weight{0.453592, Kilograms}},
+		{from: Weight{250, Grams}, to: Kilograms, want: Weight{0.25, Kilograms}},
+		{from: Weight{1, Kilograms}, to: Ounces, want: Weight{35.273962, Ounces}},
+		{from: Weight{16, Ounces}, to: Pounds, want: Weight{1, Pounds}},
+		{from: Weight{1.5, Grams}, to: Grams, want: Weight{1.5, Grams}},
+	}
+	for _, test := range weights {
+		if got := test.from.In(test.to); got != test.want {
+			t.Errorf("%v in %s: got %v, expected %v", test.from, test.to, got, test.want)
+		}
+	}
+
+	got := Dimensions{Length: 10, Width: 4, Height: 0.5, Unit: Inches}.In(Centimeters)
+	want := Dimensions{Length: 25.4, Width: 10.16, Height: 1.27, Unit: Centimeters}
+	if got != want {
+		t.Errorf("got %v, expected %v", got, want)
+	}
+}
+
+// A weight converted to another unit and back is within the precision kept
+func TestConvertProperties(t *testing.T) {
+	units := []WeightUnit{Grams, Kilograms, Ounces, Pounds}
+	property := func(value float64, from uint8, to uint8) bool {
+		value = math.Mod(value, 1e6)
+		weight := Weight{Value: round(value), Unit: units[int(from)%len(units)]}
+		back := weight.In(units[int(to)%len(units)]).In(weight.Unit)
+		// Kilograms keep 6 decimal places, so a round trip through them is within a milligram, plus the final rounding
+		return math.Abs(back.Value-weight.Value) <= 1e-3/grams[weight.Unit]+1e-6
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
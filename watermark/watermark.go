@@ -0,0 +1,131 @@
+package watermark
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/store"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const fileName = "watermarks.json"
+
+// Watermark is the newest Updated time a job has fully synced, and when it last did a full sweep
+type Watermark struct {
+	Job           string    `json:"job"`
+	Updated       time.Time `json:"updated"`
+	LastFullSweep time.Time `json:"lastFullSweep"`
+}
+
+var mu sync.Mutex
+
+// Since returns the Updated time a new sweep of the job should start from: the watermark minus the overlap. Returns the
+// zero time when a full sweep is due (there is no watermark yet or the last full sweep is older than the interval).
+func Since(bridge *env.Bridge, job string, now time.Time) time.Time {
+	mu.Lock()
+	defer mu.Unlock()
+	watermark, ok := load(bridge)[job]
+	if !ok || watermark.Updated.IsZero() {
+		logger.Info(fmt.Sprintf("No watermark for %s, doing a full sweep", bridge.Label(job)))
+		return time.Time{}
+	}
+	if now.Sub(watermark.LastFullSweep) >= bridge.GetFullSweepInterval() {
+		logger.Info(fmt.Sprintf("Last full sweep of %s was at %s, doing a full sweep", bridge.Label(job), watermark.LastFullSweep.Format(time.RFC3339)))
+		return time.Time{}
+	}
+	since := watermark.Updated.Add(-bridge.GetWatermarkOverlap())
+	logger.Info(fmt.Sprintf("Syncing %s updated since %s", bridge.Label(job), since.Format(time.RFC3339)))
+	return since
+}
+
+// Advance records a completed sweep: the watermark moves up to the newest Updated time seen, and a sweep without a
+// since time counts as a full sweep
+func Advance(bridge *env.Bridge, job string, since time.Time, started time.Time, newest time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	watermarks := load(bridge)
+	watermark := watermarks[job]
+	watermark.Job = job
+	if newest.After(watermark.Updated) {
+		watermark.Updated = newest
+	}
+	if since.IsZero() {
+		watermark.LastFullSweep = started
+	}
+	watermarks[job] = watermark
+
+	err := store.Save(bridge.GetStateDir(), fileName, watermarks)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to save the watermark for %s", bridge.Label(job)), err)
+	}
+}
+
+// Tracker follows the newest Updated time synced during a sweep, and the oldest of the entities that failed
+type Tracker struct {
+	mu           sync.Mutex
+	newest       time.Time
+	oldestFailed time.Time
+}
+
+// See records the Updated time of an entity that was synced
+func (t *Tracker) See(updated time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if updated.After(t.newest) {
+		t.newest = updated
+	}
+}
+
+// Fail records the Updated time of an entity that failed. Entities without one are in every sweep anyway.
+func (t *Tracker) Fail(updated time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !updated.IsZero() && (t.oldestFailed.IsZero() || updated.Before(t.oldestFailed)) {
+		t.oldestFailed = updated
+	}
+}
+
+// Newest returns the newest Updated time synced, but never the time of an entity that failed or a later one, so the
+// next sweep includes it
+func (t *Tracker) Newest() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.oldestFailed.IsZero() && !t.newest.Before(t.oldestFailed) {
+		return t.oldestFailed.Add(-time.Nanosecond)
+	}
+	return t.newest
+}
+
+// Listing checks that a sweep's pages are listed newest first, which stopping at the first page older than the
+// watermark relies on. Pages are checked in order.
+type Listing struct {
+	last     time.Time
+	unsorted bool
+}
+
+// NewestFirst records the Updated times of a page in the listed order. Returns false once the listing turned out not
+// to be newest first. Entities without an Updated time are ignored.
+func (l *Listing) NewestFirst(updated []time.Time) bool {
+	for _, u := range updated {
+		if u.IsZero() {
+			continue
+		}
+		if !l.last.IsZero() && u.After(l.last) {
+			l.unsorted = true
+		}
+		l.last = u
+	}
+	return !l.unsorted
+}
+
+// load reads the bridge's watermarks. Caller must hold the lock.
+func load(bridge *env.Bridge) map[string]Watermark {
+	watermarks := map[string]Watermark{}
+	err := store.Load(bridge.GetStateDir(), fileName, &watermarks)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to load the watermarks of %s", bridge.Name), err)
+		return map[string]Watermark{}
+	}
+	return watermarks
+}
@@ -0,0 +1,92 @@
+package watermark
+
+import (
+	"distribution-bridge/env/envtest"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+func TestTrackerDoesNotPassFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		synced []time.Time
+		failed []time.Time
+		want   time.Time
+	}{
+		{name: "nothing synced", want: time.Time{}},
+		{name: "all synced", synced: []time.Time{at(1), at(5), at(3)}, want: at(5)},
+		{name: "older failure", synced: []time.Time{at(1), at(5)}, failed: []time.Time{at(3), at(2)}, want: at(2).Add(-time.Nanosecond)},
+		{name: "newest failed", synced: []time.Time{at(1), at(3)}, failed: []time.Time{at(5)}, want: at(3)},
+		{name: "same time failed", synced: []time.Time{at(3)}, failed: []time.Time{at(3)}, want: at(3).Add(-time.Nanosecond)},
+		{name: "failure without a date", synced: []time.Time{at(3)}, failed: []time.Time{{}}, want: at(3)},
+	}
+	for _, test := range tests {
+		tracker := &Tracker{}
+		for _, updated := range test.synced {
+			tracker.See(updated)
+		}
+		for _, updated := range test.failed {
+			tracker.Fail(updated)
+		}
+		if got := tracker.Newest(); !got.Equal(test.want) {
+			t.Errorf("%s: got %s, expected %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestListingNewestFirst(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages [][]time.Time
+		want  bool
+	}{
+		{name: "newest first", pages: [][]time.Time{{at(9), at(8)}, {at(8), at(5)}, {at(1)}}, want: true},
+		{name: "without dates", pages: [][]time.Time{{at(9), {}, at(8)}, {{}, at(2)}}, want: true},
+		{name: "oldest first on a page", pages: [][]time.Time{{at(1), at(2)}}, want: false},
+		{name: "oldest first across pages", pages: [][]time.Time{{at(5), at(4)}, {at(6)}, {at(3)}}, want: false},
+	}
+	for _, test := range tests {
+		listing := &Listing{}
+		got := true
+		for _, page := range test.pages {
+			got = listing.NewestFirst(page)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %t, expected %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSinceAndAdvance(t *testing.T) {
+	bridge := envtest.NewBridge(t, "jobs.watermarkOverlap=10m", "jobs.fullSweepInterval=24h")
+	now := at(60)
+
+	if since := Since(bridge, "products", now); !since.IsZero() {
+		t.Fatalf("expected a full sweep without a watermark, got %s", since)
+	}
+	// A product updated at 0:30 failed during the full sweep
+	tracker := &Tracker{}
+	tracker.See(at(20))
+	tracker.See(at(40))
+	tracker.Fail(at(30))
+	Advance(bridge, "products", time.Time{}, now, tracker.Newest())
+
+	since := Since(bridge, "products", now.Add(time.Minute))
+	if want := at(30).Add(-time.Nanosecond - 10*time.Minute); !since.Equal(want) {
+		t.Errorf("got %s, expected %s", since, want)
+	}
+	// The watermark never moves back, and a full sweep is due after the interval
+	Advance(bridge, "products", since, now, at(10))
+	if got := Since(bridge, "products", now.Add(time.Minute)); !got.Equal(since) {
+		t.Errorf("got %s, expected %s", got, since)
+	}
+	if got := Since(bridge, "products", now.Add(24*time.Hour)); !got.IsZero() {
+		t.Errorf("expected a full sweep after the interval, got %s", got)
+	}
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"distribution-bridge/env"
+	"distribution-bridge/logger"
+	"distribution-bridge/orders"
+	"distribution-bridge/products"
+	"distribution-bridge/status"
+	"fmt"
+)
+
+// Dispatch runs the targeted single entity sync for an event sent to a bridge
+//   - order.created: forwards the retailer order (seller account) to the supplier (buyer account), or reserves stock for
+//     it in stock mode
+//   - order.updated, fulfillment.created: copies fulfillments from the supplier order (buyer account) to the retailer order
+//   - product.updated: syncs the product from the buyer account to the seller account
+func Dispatch(bridge *env.Bridge, event Event) error {
+	run := status.Start(bridge, "webhooks")
+	err := dispatch(run, event)
+	run.Finish(err == nil)
+	return err
+}
+
+func dispatch(run *status.Run, event Event) error {
+	switch event.Type {
+	case OrderCreated:
+		// The same jobs as the scheduled sync: new orders are handled by the orders job, which needs drop shipping
+		if !run.Bridge.DropShippingEnabled() || (!run.Bridge.ForwardNewOrders() && !run.Bridge.StockModeEnabled()) {
+			logger.Info(fmt.Sprintf("Forwarding new orders is disabled, ignoring %s", event.ID))
+			return nil
+		}
+		if event.Data.ID == "" {
+			return errMissingEntity
+		}
+		return orders.SyncNewOrder(run, event.Data.ID)
+	case OrderUpdated, FulfillmentCreated:
+		if !run.Bridge.DropShippingEnabled() {
+			return nil
+		}
+		// Fulfillment events carry the fulfillment ID, so use the order ID
+		orderID := event.Data.ID
+		if event.Type == FulfillmentCreated {
+			orderID = event.Data.OrderID
+		}
+		if orderID == "" {
+			return errMissingEntity
+		}
+		return orders.SyncOrderUpdate(run, orderID)
+	case ProductUpdated:
+		if !run.Bridge.ProductSyncEnabled() {
+			return nil
+		}
+		if event.Data.ID == "" {
+			return errMissingEntity
+		}
+		return products.SyncProduct(run, event.Data.ID)
+	}
+	return nil
+}
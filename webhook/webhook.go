@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"distribution-bridge/logger"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader holds the hex HMAC-SHA256 of the raw body using the shared secret, ex. sha256=3f2a...
+const SignatureHeader = "X-Convictional-Signature"
+
+// Event types the bridge acts on
+const (
+	OrderCreated       = "order.created"
+	OrderUpdated       = "order.updated"
+	FulfillmentCreated = "fulfillment.created"
+	ProductUpdated     = "product.updated"
+)
+
+// maxBodySize caps the size of a webhook body
+const maxBodySize = 1 << 20
+
+// dedupeWindow is how long an event ID is remembered
+const dedupeWindow = time.Hour * 24
+
+// Event is a Convictional style webhook event
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		ID      string `json:"id"`
+		OrderID string `json:"orderId"`
+	} `json:"data"`
+}
+
+// Receiver verifies, dedupes and queues webhook events, then handles them one at a time
+type Receiver struct {
+	secret func() string
+	handle func(Event) error
+	queue  chan Event
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewReceiver creates a receiver that calls handle for each new event. The secret is read for every event so a
+// rotated secret is used without a restart.
+func NewReceiver(secret func() string, queueSize int, handle func(Event) error) *Receiver {
+	return &Receiver{
+		secret: secret,
+		handle: handle,
+		queue:  make(chan Event, queueSize),
+		seen:   map[string]time.Time{},
+	}
+}
+
+// ServeHTTP accepts an event, responding 202 once it is queued
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !r.validSignature(body, req.Header.Get(SignatureHeader)) {
+		logger.Info("Rejected webhook with an invalid signature")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event Event
+	err = json.Unmarshal(body, &event)
+	if err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	if event.ID == "" || event.Type == "" {
+		http.Error(w, "event id and type are required", http.StatusBadRequest)
+		return
+	}
+	if !handled(event.Type) {
+		// Acknowledge so the sender doesn't keep retrying events we don't use
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if !r.markSeen(event.ID, time.Now()) {
+		logger.Info(fmt.Sprintf("Ignoring duplicate webhook event :: %s", event.ID))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	select {
+	case r.queue <- event:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		// Forget the event so the sender's retry is accepted
+		r.forget(event.ID)
+		http.Error(w, "queue is full", http.StatusServiceUnavailable)
+	}
+}
+
+// Run handles queued events until stop is closed
+func (r *Receiver) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case event := <-r.queue:
+			logger.Info(fmt.Sprintf("Handling webhook event %s :: %s", event.ID, event.Type))
+			err := r.handle(event)
+			if err != nil {
+				logger.Error(fmt.Sprintf("failed to handle webhook event %s, the next sync will pick it up", event.ID), err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// validSignature is true when the header holds the HMAC of the body. Nothing is valid without a secret, ex. when its
+// provider can't read it.
+func (r *Receiver) validSignature(body []byte, header string) bool {
+	secret := r.secret()
+	if secret == "" {
+		return false
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// markSeen records the event ID, returning false if it was already seen within the dedupe window
+func (r *Receiver) markSeen(eventID string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, seenAt := range r.seen {
+		if now.Sub(seenAt) > dedupeWindow {
+			delete(r.seen, id)
+		}
+	}
+	if _, ok := r.seen[eventID]; ok {
+		return false
+	}
+	r.seen[eventID] = now
+	return true
+}
+
+func (r *Receiver) forget(eventID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.seen, eventID)
+}
+
+func handled(eventType string) bool {
+	switch eventType {
+	case OrderCreated, OrderUpdated, FulfillmentCreated, ProductUpdated:
+		return true
+	}
+	return false
+}
+
+// errMissingEntity is returned for events without the ID of the entity to sync
+var errMissingEntity = errors.New("error: webhook event is missing the entity ID")
@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"distribution-bridge/env"
+	"distribution-bridge/status"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const secret = "webhook-secret"
+
+// post sends the body signed with the key to the receiver and returns the response code
+func post(receiver *Receiver, key string, body string) int {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func event(id string) string {
+	return fmt.Sprintf(`{"id": %q, "type": "order.updated", "data": {"id": "order_1"}}`, id)
+}
+
+func TestSignature(t *testing.T) {
+	currentSecret := secret
+	receiver := NewReceiver(func() string { return currentSecret }, 10, nil)
+
+	tests := []struct {
+		name   string
+		secret string
+		key    string
+		want   int
+	}{
+		{name: "signed with the secret", secret: secret, key: secret, want: http.StatusAccepted},
+		{name: "signed with another key", secret: secret, key: "other", want: http.StatusUnauthorized},
+		{name: "rotated secret", secret: "rotated", key: "rotated", want: http.StatusAccepted},
+		{name: "empty secret", secret: "", key: "", want: http.StatusUnauthorized},
+	}
+	for i, test := range tests {
+		currentSecret = test.secret
+		if code := post(receiver, test.key, event(fmt.Sprintf("evt_%d", i))); code != test.want {
+			t.Errorf("%s: got %d, expected %d", test.name, code, test.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(event("evt_unsigned")))
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("an unsigned event got %d, expected %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestDedupeAndQueue(t *testing.T) {
+	// Not running, so queued events wait
+	receiver := NewReceiver(func() string { return secret }, 1, nil)
+
+	if code := post(receiver, secret, event("evt_1")); code != http.StatusAccepted {
+		t.Fatalf("got %d, expected %d", code, http.StatusAccepted)
+	}
+	if code := post(receiver, secret, event("evt_1")); code != http.StatusOK {
+		t.Errorf("a duplicate got %d, expected %d", code, http.StatusOK)
+	}
+	// The queue holds a single event, the sender has to retry the next one
+	if code := post(receiver, secret, event("evt_2")); code != http.StatusServiceUnavailable {
+		t.Errorf("an event with a full queue got %d, expected %d", code, http.StatusServiceUnavailable)
+	}
+
+	// Once the queued event is taken, the retry of the rejected event is accepted and not a duplicate
+	if queued := <-receiver.queue; queued.ID != "evt_1" {
+		t.Errorf("expected evt_1 to be queued, got %s", queued.ID)
+	}
+	if code := post(receiver, secret, event("evt_2")); code != http.StatusAccepted {
+		t.Errorf("the retry of a rejected event got %d, expected %d", code, http.StatusAccepted)
+	}
+	if len(receiver.queue) != 1 {
+		t.Errorf("expected the retried event to be queued")
+	}
+	if code := post(receiver, secret, `{"id": "evt_3", "type": "customer.created"}`); code != http.StatusNoContent {
+		t.Errorf("an unhandled type got %d, expected %d", code, http.StatusNoContent)
+	}
+}
+
+func TestDispatchFollowsTheScheduledJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// Nothing listens at the API URL, so an event that isn't ignored fails
+	tests := []struct {
+		name     string
+		settings []string
+		event    Event
+		ignored  bool
+	}{
+		{name: "new order without drop shipping", settings: []string{"jobs.orders.enabled=false", "jobs.orders.forwardNew=true"}, event: Event{Type: OrderCreated}, ignored: true},
+		{name: "new order without forwarding", settings: []string{"jobs.orders.enabled=true", "jobs.orders.forwardNew=false"}, event: Event{Type: OrderCreated}, ignored: true},
+		{name: "new order", settings: []string{"jobs.orders.enabled=true", "jobs.orders.forwardNew=true"}, event: Event{Type: OrderCreated}},
+		{name: "order update without drop shipping", settings: []string{"jobs.orders.enabled=false"}, event: Event{Type: OrderUpdated}, ignored: true},
+		{name: "product without product sync", settings: []string{"jobs.products.enabled=false"}, event: Event{Type: ProductUpdated}, ignored: true},
+	}
+	for _, test := range tests {
+		test.event.ID = "evt_1"
+		test.event.Data.ID = "entity_1"
+		err := env.Load("", append([]string{"api.url=http://127.0.0.1:1", "api.rateLimit=0", "state.dir=" + dir}, test.settings...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dispatch(status.Start(env.Bridges()[0], "webhooks"), test.event)
+		if test.ignored && err != nil {
+			t.Errorf("%s: expected the event to be ignored, got %v", test.name, err)
+		}
+		if !test.ignored && err == nil {
+			t.Errorf("%s: expected the event to be handled", test.name)
+		}
+	}
+}